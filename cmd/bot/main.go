@@ -1,14 +1,28 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"os"
 
 	"telegram-reminder/internal/bot"
 	"telegram-reminder/internal/config"
 	"telegram-reminder/internal/logger"
+
+	openai "github.com/sashabaranov/go-openai"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validate(os.Args[2:]))
+		case "run-task":
+			os.Exit(runTask(os.Args[2:]))
+		}
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		logger.L.Error("config load", "err", err)
@@ -19,3 +33,96 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// validate checks a tasks file and prints every problem as file:line: text.
+// It needs no tokens. The file defaults to TASKS_FILE or tasks.yml.
+func validate(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: bot validate [tasks.yml]")
+		return 2
+	}
+	fn := os.Getenv("TASKS_FILE")
+	if len(args) == 1 {
+		fn = args[0]
+	}
+	if fn == "" {
+		fn = "tasks.yml"
+	}
+
+	problems, err := bot.ValidateTasksFile(fn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fn, err)
+		return 1
+	}
+	for _, p := range problems {
+		if p.Line > 0 {
+			fmt.Printf("%s:%d: %s\n", fn, p.Line, p)
+		} else {
+			fmt.Printf("%s: %s\n", fn, p)
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(problems))
+		return 1
+	}
+	fmt.Printf("%s: OK\n", fn)
+	return 0
+}
+
+// runTask previews a task or digest: it renders the prompt the way a
+// scheduled run would and prints the model's answer. Nothing is sent to
+// Telegram. With -prompt-only no OpenAI key is needed.
+func runTask(args []string) int {
+	fs := flag.NewFlagSet("run-task", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: bot run-task [-prompt-only] [-o file] <task>")
+		fs.PrintDefaults()
+	}
+	promptOnly := fs.Bool("prompt-only", false, "print the rendered prompt without calling the model")
+	out := fs.String("o", "", "write the result to `file` instead of stdout")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		if err == nil {
+			fs.Usage()
+		}
+		return 2
+	}
+	name := fs.Arg(0)
+
+	if err := bot.LoadForPreview(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var client bot.ChatCompleter
+	if !*promptOnly {
+		key := os.Getenv(config.EnvOpenAIKey)
+		if key == "" {
+			fmt.Fprintf(os.Stderr, "%s is not set; use -prompt-only to skip the model\n", config.EnvOpenAIKey)
+			return 1
+		}
+		oaCfg := openai.DefaultConfig(key)
+		oaCfg.HTTPClient = logger.NewHTTPClient(bot.OpenAITimeout)
+		client = openai.NewClientWithConfig(oaCfg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bot.OpenAITimeout)
+	defer cancel()
+	p, err := bot.PreviewTask(ctx, client, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	result := p.Output
+	if *promptOnly {
+		result = p.Prompt
+	}
+	if *out == "" {
+		fmt.Println(result)
+		return 0
+	}
+	if err := os.WriteFile(*out, []byte(result+"\n"), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s (%s) written to %s\n", name, p.Model, *out)
+	return 0
+}
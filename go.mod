@@ -4,13 +4,15 @@ go 1.24.3
 
 require (
 	github.com/go-co-op/gocron v1.37.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sashabaranov/go-openai v1.40.5
+	go.etcd.io/bbolt v1.4.3
 	gopkg.in/telebot.v3 v3.3.8
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/google/uuid v1.4.0 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
 )
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// AccessPolicy controls who may run commands that call OpenAI.
+type AccessPolicy string
+
+const (
+	// AccessOpen lets anyone use AI commands.
+	AccessOpen AccessPolicy = "open"
+	// AccessWhitelist allows only active chats from the registry.
+	AccessWhitelist AccessPolicy = "whitelist"
+	// AccessWhitelistAdmins allows active chats plus private chats of admins.
+	AccessWhitelistAdmins AccessPolicy = "whitelist_admins"
+)
+
+// RefusalInterval is the minimum time between two refusals sent to one chat.
+const RefusalInterval = 10 * time.Minute
+
+var (
+	accessMu     sync.Mutex
+	accessPolicy = AccessOpen
+	lastRefusal  = map[int64]time.Time{}
+)
+
+// SetAccessPolicy sets the policy applied by AccessMiddleware.
+func SetAccessPolicy(p AccessPolicy) {
+	accessMu.Lock()
+	accessPolicy = p
+	lastRefusal = map[int64]time.Time{}
+	accessMu.Unlock()
+}
+
+func currentAccessPolicy() AccessPolicy {
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	return accessPolicy
+}
+
+// isChatActive reports whether the chat is registered and active.
+func isChatActive(id int64) bool {
+	chat, ok, err := currentChatStore().GetChat(id)
+	if err != nil {
+		logger.L.Error("load chat", "chat_id", id, "err", err)
+		return false
+	}
+	return ok && chat.Active
+}
+
+// HasAccess reports whether a user in a chat may run AI commands under the
+// current policy.
+func HasAccess(chat *tb.Chat, sender *tb.User) bool {
+	policy := currentAccessPolicy()
+	if policy == AccessOpen {
+		return true
+	}
+	if chat == nil {
+		return false
+	}
+	if isChatActive(chat.ID) {
+		return true
+	}
+	if policy == AccessWhitelistAdmins && chat.Type == tb.ChatPrivate && sender != nil && IsAdmin(sender.ID) {
+		return true
+	}
+	return false
+}
+
+// shouldRefuse reports whether a refusal may be sent to the chat now and
+// records the attempt.
+func shouldRefuse(chatID int64, now time.Time) bool {
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	if last, ok := lastRefusal[chatID]; ok && now.Sub(last) < RefusalInterval {
+		return false
+	}
+	lastRefusal[chatID] = now
+	return true
+}
+
+// AccessMiddleware guards handlers that call OpenAI. Chats without access get
+// a polite refusal at most once per RefusalInterval; further attempts are
+// dropped silently.
+func AccessMiddleware() tb.MiddlewareFunc {
+	return func(next tb.HandlerFunc) tb.HandlerFunc {
+		return func(c tb.Context) error {
+			if currentAccessPolicy() == AccessOpen {
+				return next(c)
+			}
+			if HasAccess(c.Chat(), c.Sender()) {
+				return next(c)
+			}
+
+			var chatID, userID int64
+			if c.Chat() != nil {
+				chatID = c.Chat().ID
+			}
+			if c.Sender() != nil {
+				userID = c.Sender().ID
+			}
+			cmd := ""
+			if c.Message() != nil {
+				cmd, _ = parseCommand(c.Message().Text)
+			}
+			logger.GetSecurityLogger().SecurityEvent("access_denied", userID, map[string]interface{}{
+				"chat_id": chatID,
+				"command": cmd,
+				"policy":  string(currentAccessPolicy()),
+			})
+
+			if !shouldRefuse(chatID, time.Now()) {
+				return nil
+			}
+			return c.Send("🙏 Извините, этот бот доступен только подключённым чатам.\nПопросите администратора добавить чат командой /start.")
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// Role is the privilege level of a Telegram user.
+type Role int
+
+const (
+	// RoleUser is any user that can message the bot.
+	RoleUser Role = iota
+	// RoleAdmin can manage chats, tasks and the global model.
+	RoleAdmin
+	// RoleOwner is a bootstrap admin from ADMIN_IDS; owners can promote admins.
+	RoleOwner
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleOwner:
+		return "owner"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "user"
+	}
+}
+
+// commandPermissions maps privileged commands to the minimum role required.
+// Commands not listed are available to everyone.
+var commandPermissions = map[string]Role{
+	"/whitelist":  RoleAdmin,
+	"/groups":     RoleAdmin,
+	"/stats":      RoleAdmin,
+	"/remove":     RoleAdmin,
+	"/model":      RoleAdmin, // only when switching the model, see requiredRole
+	"/promote":    RoleOwner,
+	"/demote":     RoleOwner,
+	"/reload":     RoleAdmin,
+	"/addtask":    RoleAdmin,
+	"/edittask":   RoleAdmin,
+	"/settime":    RoleAdmin,
+	"/pausetask":  RoleAdmin,
+	"/resumetask": RoleAdmin,
+	"/deltask":    RoleAdmin,
+	"/history":    RoleAdmin,
+	"/lastrun":    RoleAdmin,
+	"/preview":    RoleAdmin,
+}
+
+// adminFile is the on-disk layout of promoted admins.
+type adminFile struct {
+	Admins []int64 `json:"admins"`
+}
+
+var (
+	adminMu    sync.RWMutex
+	owners     = map[int64]bool{}
+	admins     = map[int64]bool{}
+	adminsPath string // empty keeps promoted admins in memory only
+)
+
+// SetBootstrapAdmins sets the owners configured through ADMIN_IDS.
+func SetBootstrapAdmins(ids []int64) {
+	adminMu.Lock()
+	owners = make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		owners[id] = true
+	}
+	adminMu.Unlock()
+}
+
+// LoadAdmins reads promoted admins from path and persists later promotions
+// there. A missing file yields no promoted admins.
+func LoadAdmins(path string) error {
+	var af adminFile
+	if err := loadJSONFile(path, &af); err != nil {
+		return fmt.Errorf("load admins %s: %w", path, err)
+	}
+	adminMu.Lock()
+	adminsPath = path
+	admins = make(map[int64]bool, len(af.Admins))
+	for _, id := range af.Admins {
+		admins[id] = true
+	}
+	adminMu.Unlock()
+	return nil
+}
+
+// ResetAdmins clears owners and promoted admins. Used in tests.
+func ResetAdmins() {
+	adminMu.Lock()
+	owners = map[int64]bool{}
+	admins = map[int64]bool{}
+	adminsPath = ""
+	adminMu.Unlock()
+}
+
+// RoleOf returns the role of a Telegram user.
+func RoleOf(userID int64) Role {
+	adminMu.RLock()
+	defer adminMu.RUnlock()
+	switch {
+	case owners[userID]:
+		return RoleOwner
+	case admins[userID]:
+		return RoleAdmin
+	default:
+		return RoleUser
+	}
+}
+
+// IsAdmin reports whether the user is an admin or owner.
+func IsAdmin(userID int64) bool {
+	return RoleOf(userID) >= RoleAdmin
+}
+
+// AdminIDs returns all owners and promoted admins in ascending order.
+func AdminIDs() []int64 {
+	adminMu.RLock()
+	seen := map[int64]bool{}
+	for id := range owners {
+		seen[id] = true
+	}
+	for id := range admins {
+		seen[id] = true
+	}
+	adminMu.RUnlock()
+	ids := make([]int64, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// notifyAdmins sends a private message to every admin and owner.
+func notifyAdmins(b *tb.Bot, text string, opts ...interface{}) {
+	ids := AdminIDs()
+	if len(ids) == 0 {
+		logger.L.Warn("no admins to notify", "text", text)
+		return
+	}
+	for _, id := range ids {
+		if _, err := b.Send(&tb.User{ID: id}, text, opts...); err != nil {
+			logger.L.Error("notify admin", "admin_id", id, "err", err)
+		}
+	}
+}
+
+// saveAdminsLocked persists promoted admins. The caller must hold adminMu.
+func saveAdminsLocked() error {
+	if adminsPath == "" {
+		return nil
+	}
+	af := adminFile{Admins: make([]int64, 0, len(admins))}
+	for id := range admins {
+		af.Admins = append(af.Admins, id)
+	}
+	sort.Slice(af.Admins, func(i, j int) bool { return af.Admins[i] < af.Admins[j] })
+	return saveJSONFile(adminsPath, af)
+}
+
+// PromoteAdmin grants the admin role to a user.
+func PromoteAdmin(userID int64) error {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	if admins[userID] {
+		return nil
+	}
+	admins[userID] = true
+	if err := saveAdminsLocked(); err != nil {
+		delete(admins, userID)
+		return err
+	}
+	return nil
+}
+
+// DemoteAdmin revokes the admin role. Owners cannot be demoted.
+func DemoteAdmin(userID int64) error {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	if owners[userID] {
+		return fmt.Errorf("user %d is a bootstrap admin", userID)
+	}
+	if !admins[userID] {
+		return nil
+	}
+	delete(admins, userID)
+	if err := saveAdminsLocked(); err != nil {
+		admins[userID] = true
+		return err
+	}
+	return nil
+}
+
+// parseCommand extracts the command name from a message text, dropping the
+// optional @botname suffix. It returns "" for non-command messages.
+func parseCommand(text string) (cmd, payload string) {
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	// Telebot ends the command at any whitespace, so "/model\no3" must parse
+	// the same as "/model o3".
+	cmd = text
+	if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+		cmd, payload = text[:i], strings.TrimSpace(text[i:])
+	}
+	if i := strings.Index(cmd, "@"); i >= 0 {
+		cmd = cmd[:i]
+	}
+	return cmd, payload
+}
+
+// requiredRole returns the minimum role needed to run the command.
+func requiredRole(cmd, payload string) Role {
+	role, ok := commandPermissions[cmd]
+	if !ok {
+		return RoleUser
+	}
+	if cmd == "/model" && payload == "" {
+		// Showing the current model is harmless; switching it is not.
+		return RoleUser
+	}
+	return role
+}
+
+// PermissionMiddleware rejects privileged commands from users without the
+// required role and reports each denial as a security event.
+func PermissionMiddleware() tb.MiddlewareFunc {
+	return func(next tb.HandlerFunc) tb.HandlerFunc {
+		return func(c tb.Context) error {
+			if c.Callback() != nil || c.Message() == nil {
+				return next(c)
+			}
+			cmd, payload := parseCommand(c.Message().Text)
+			need := requiredRole(cmd, payload)
+			if need == RoleUser {
+				return next(c)
+			}
+
+			var userID int64
+			if c.Sender() != nil {
+				userID = c.Sender().ID
+			}
+			if RoleOf(userID) >= need {
+				return next(c)
+			}
+
+			var chatID int64
+			if c.Chat() != nil {
+				chatID = c.Chat().ID
+			}
+			logger.GetSecurityLogger().SecurityEvent("permission_denied", userID, map[string]interface{}{
+				"command":  cmd,
+				"chat_id":  chatID,
+				"required": need.String(),
+			})
+			return c.Send("⛔ Недостаточно прав для этой команды")
+		}
+	}
+}
+
+func parseUserIDPayload(c tb.Context) (int64, bool) {
+	payload := sanitizeInput(c.Message().Payload)
+	if payload == "" && c.Message().ReplyTo != nil && c.Message().ReplyTo.Sender != nil {
+		return c.Message().ReplyTo.Sender.ID, true
+	}
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+func handlePromote(c tb.Context) error {
+	logger.L.Debug("command promote", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	id, ok := parseUserIDPayload(c)
+	if !ok {
+		return c.Send("Usage: /promote <user_id> (или ответом на сообщение пользователя)")
+	}
+	if err := PromoteAdmin(id); err != nil {
+		logger.L.Error("promote admin", "user_id", id, "err", err)
+		return c.Send("❌ Не удалось назначить администратора")
+	}
+	logger.GetSecurityLogger().SecurityEvent("admin_promoted", c.Sender().ID, map[string]interface{}{
+		"target_user_id": id,
+	})
+	return c.Send(fmt.Sprintf("✅ %d теперь администратор", id))
+}
+
+func handleDemote(c tb.Context) error {
+	logger.L.Debug("command demote", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	id, ok := parseUserIDPayload(c)
+	if !ok {
+		return c.Send("Usage: /demote <user_id>")
+	}
+	if err := DemoteAdmin(id); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	logger.GetSecurityLogger().SecurityEvent("admin_demoted", c.Sender().ID, map[string]interface{}{
+		"target_user_id": id,
+	})
+	return c.Send(fmt.Sprintf("✅ %d больше не администратор", id))
+}
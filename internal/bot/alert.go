@@ -0,0 +1,410 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	"github.com/go-co-op/gocron"
+	tb "gopkg.in/telebot.v3"
+)
+
+// MaxAlertsPerChat limits the /alert thresholds of one chat.
+const MaxAlertsPerChat = 20
+
+// alertsTag is the scheduler tag of the alert poller.
+const alertsTag = "alerts"
+
+// Alert notifies a chat once a BLOCKCHAIN_API value crosses a threshold.
+// After firing it stays disarmed until the value moves back past the
+// threshold by the hysteresis margin, so a value hovering around the
+// threshold does not spam the chat.
+type Alert struct {
+	ID        int64     `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	UserID    int64     `json:"user_id,omitempty"`
+	Metric    string    `json:"metric"` // btc, hashrate or tx
+	Op        string    `json:"op"`     // ">" or "<"
+	Threshold float64   `json:"threshold"`
+	Armed     bool      `json:"armed"`
+	Fired     time.Time `json:"fired,omitempty"` // last notification
+	Created   time.Time `json:"created"`
+}
+
+// alertFile is the on-disk layout of the alert store.
+type alertFile struct {
+	NextID int64   `json:"next_id"`
+	Alerts []Alert `json:"alerts"`
+}
+
+// alertMetric is a BlockchainStats value alerts can watch.
+type alertMetric struct {
+	title  string
+	value  func(BlockchainStats) float64
+	format func(float64) string
+}
+
+var alertMetrics = map[string]alertMetric{
+	"btc": {
+		title:  "BTC",
+		value:  func(st BlockchainStats) float64 { return st.MarketPriceUSD },
+		format: func(v float64) string { return fmt.Sprintf("$%.2f", v) },
+	},
+	"hashrate": {
+		title:  "Hash rate",
+		value:  func(st BlockchainStats) float64 { return st.HashRate },
+		format: func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	},
+	"tx": {
+		title:  "Transactions",
+		value:  func(st BlockchainStats) float64 { return float64(st.NTx) },
+		format: func(v float64) string { return fmt.Sprintf("%.0f", v) },
+	},
+}
+
+// alertMetricAliases maps accepted metric names to the keys of alertMetrics.
+var alertMetricAliases = map[string]string{
+	"btc": "btc", "price": "btc", "цена": "btc",
+	"hashrate": "hashrate", "hash_rate": "hashrate", "хешрейт": "hashrate",
+	"tx": "tx", "n_tx": "tx", "транзакции": "tx",
+}
+
+var (
+	alertMu         sync.Mutex
+	alerts          = map[int64]Alert{}
+	nextAlert       = int64(1)
+	alertsPath      string // empty keeps alerts in memory only
+	alertHysteresis = 1.0  // percent of the threshold
+)
+
+// SetAlertHysteresis sets how far, in percent of the threshold, a value has to
+// move back before a fired alert is armed again.
+func SetAlertHysteresis(percent float64) {
+	alertMu.Lock()
+	alertHysteresis = percent
+	alertMu.Unlock()
+}
+
+// LoadAlerts reads alerts from path and persists later changes there. A
+// missing file yields an empty store.
+func LoadAlerts(path string) error {
+	var af alertFile
+	if err := loadJSONFile(path, &af); err != nil {
+		return fmt.Errorf("load alerts %s: %w", path, err)
+	}
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	alerts = map[int64]Alert{}
+	nextAlert = max(af.NextID, 1)
+	for _, a := range af.Alerts {
+		alerts[a.ID] = a
+		nextAlert = max(nextAlert, a.ID+1)
+	}
+	alertsPath = path
+	return nil
+}
+
+// ResetAlerts forgets all alerts. Used in tests.
+func ResetAlerts() {
+	alertMu.Lock()
+	alerts = map[int64]Alert{}
+	nextAlert = 1
+	alertsPath = ""
+	alertHysteresis = 1.0
+	alertMu.Unlock()
+}
+
+// saveAlertsLocked writes the store to disk. alertMu must be held.
+func saveAlertsLocked() error {
+	if alertsPath == "" {
+		return nil
+	}
+	af := alertFile{NextID: nextAlert, Alerts: make([]Alert, 0, len(alerts))}
+	for _, a := range alerts {
+		af.Alerts = append(af.Alerts, a)
+	}
+	sortAlerts(af.Alerts)
+	return saveJSONFile(alertsPath, af)
+}
+
+// sortAlerts orders alerts by ID.
+func sortAlerts(as []Alert) {
+	sort.Slice(as, func(i, j int) bool { return as[i].ID < as[j].ID })
+}
+
+var alertRe = regexp.MustCompile(`^(\S+?)\s*([<>])\s*(\S+)$`)
+
+// ParseAlert parses "btc > 70000", "hashrate < 6e11" or "tx>400k" into an
+// alert without a chat. Values accept the suffixes k, m, b (or g) and t.
+func ParseAlert(s string) (Alert, error) {
+	m := alertRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Alert{}, fmt.Errorf("не понял условие: например «btc > 70000» или «hashrate < 6e11»")
+	}
+	metric, ok := alertMetricAliases[strings.ToLower(m[1])]
+	if !ok {
+		return Alert{}, fmt.Errorf("неизвестный показатель %q, доступны btc, hashrate, tx", m[1])
+	}
+	v, err := parseAlertValue(m[3])
+	if err != nil {
+		return Alert{}, err
+	}
+	return Alert{Metric: metric, Op: m[2], Threshold: v}, nil
+}
+
+// parseAlertValue parses a positive threshold such as "70000", "$70,000",
+// "70k" or "6.5e11".
+func parseAlertValue(s string) (float64, error) {
+	v := strings.NewReplacer("$", "", ",", "", "_", "").Replace(strings.ToLower(s))
+	mult := 1.0
+	if n := len(v); n > 1 {
+		switch v[n-1] {
+		case 'k':
+			mult = 1e3
+		case 'm':
+			mult = 1e6
+		case 'b', 'g':
+			mult = 1e9
+		case 't':
+			mult = 1e12
+		}
+		if mult != 1 {
+			v = v[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("неверное значение %q", s)
+	}
+	return f * mult, nil
+}
+
+// AddAlert stores an alert parsed by ParseAlert for a chat. New alerts are
+// armed: if the condition already holds, the next check notifies the chat.
+func AddAlert(chatID, userID int64, a Alert) (Alert, error) {
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	count := 0
+	for _, old := range alerts {
+		if old.ChatID == chatID {
+			count++
+		}
+	}
+	if count >= MaxAlertsPerChat {
+		return Alert{}, fmt.Errorf("в чате уже %d алертов, удалите лишние через /unalert", count)
+	}
+	a.ID, a.ChatID, a.UserID = nextAlert, chatID, userID
+	a.Armed, a.Created = true, time.Now()
+	nextAlert++
+	alerts[a.ID] = a
+	if err := saveAlertsLocked(); err != nil {
+		return a, fmt.Errorf("save alerts: %w", err)
+	}
+	return a, nil
+}
+
+// migrateAlerts moves the alerts of a chat to its new ID.
+func migrateAlerts(oldID, newID int64) error {
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	moved := false
+	for id, a := range alerts {
+		if a.ChatID == oldID {
+			a.ChatID = newID
+			alerts[id] = a
+			moved = true
+		}
+	}
+	if !moved {
+		return nil
+	}
+	return saveAlertsLocked()
+}
+
+// ChatAlerts returns the alerts of a chat ordered by ID.
+func ChatAlerts(chatID int64) []Alert {
+	alertMu.Lock()
+	var out []Alert
+	for _, a := range alerts {
+		if a.ChatID == chatID {
+			out = append(out, a)
+		}
+	}
+	alertMu.Unlock()
+	sortAlerts(out)
+	return out
+}
+
+// RemoveAlert deletes an alert of a chat.
+func RemoveAlert(chatID, id int64) error {
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	a, ok := alerts[id]
+	if !ok || a.ChatID != chatID {
+		return fmt.Errorf("нет алерта #%d", id)
+	}
+	delete(alerts, id)
+	return saveAlertsLocked()
+}
+
+// evaluate returns whether the alert fires at value v and its new armed state.
+// hysteresis is in percent of the threshold.
+func (a Alert) evaluate(v, hysteresis float64) (fire, armed bool) {
+	margin := a.Threshold * hysteresis / 100
+	if a.Op == ">" {
+		if a.Armed {
+			return v > a.Threshold, v <= a.Threshold
+		}
+		return false, v <= a.Threshold-margin
+	}
+	if a.Armed {
+		return v < a.Threshold, v >= a.Threshold
+	}
+	return false, v >= a.Threshold+margin
+}
+
+// describe renders the condition of the alert, e.g. "BTC > $70000.00".
+func (a Alert) describe() string {
+	m := alertMetrics[a.Metric]
+	return fmt.Sprintf("%s %s %s", m.title, a.Op, m.format(a.Threshold))
+}
+
+// CheckAlerts fetches apiURL once and notifies the chats whose alerts fire.
+// Nothing is fetched while no alert is set.
+func CheckAlerts(ctx context.Context, b *tb.Bot, apiURL string) error {
+	alertMu.Lock()
+	empty := len(alerts) == 0
+	alertMu.Unlock()
+	if empty {
+		return nil
+	}
+	st, err := FetchBlockchainStats(ctx, apiURL)
+	if err != nil {
+		return err
+	}
+
+	type notice struct {
+		chatID int64
+		text   string
+	}
+	var notices []notice
+	alertMu.Lock()
+	changed := false
+	for id, a := range alerts {
+		m, ok := alertMetrics[a.Metric]
+		if !ok {
+			continue
+		}
+		v := m.value(st)
+		fire, armed := a.evaluate(v, alertHysteresis)
+		if !fire && armed == a.Armed {
+			continue
+		}
+		a.Armed = armed
+		if fire {
+			a.Fired = time.Now()
+			notices = append(notices, notice{a.ChatID, fmt.Sprintf("🚨 Алерт #%d: %s сейчас %s (условие %s)", a.ID, m.title, m.format(v), html.EscapeString(a.describe()))})
+		}
+		alerts[id] = a
+		changed = true
+	}
+	if changed {
+		if err := saveAlertsLocked(); err != nil {
+			logger.L.Error("save alerts", "err", err)
+		}
+	}
+	alertMu.Unlock()
+
+	for _, n := range notices {
+		if b == nil {
+			continue
+		}
+		if err := deliverToChat(b, n.chatID, n.text); err != nil {
+			DefaultErrorHandler.HandleTelegramError(err, n.chatID)
+			logger.L.Warn("send alert", "chat_id", n.chatID, "err", err)
+		}
+	}
+	if len(notices) > 0 {
+		logger.L.Info("alerts fired", "count", len(notices))
+	}
+	return nil
+}
+
+// StartAlerts polls apiURL every interval on s and sends fired alerts with b.
+// A zero interval disables the poller.
+func StartAlerts(s *gocron.Scheduler, b *tb.Bot, apiURL string, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	_, err := s.Every(interval).Tag(alertsTag).Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), BlockchainTimeout)
+		defer cancel()
+		if err := CheckAlerts(ctx, b, apiURL); err != nil {
+			logger.L.Warn("check alerts", "err", err)
+		}
+	})
+	return err
+}
+
+func handleAlert(c tb.Context) error {
+	logger.L.Debug("command alert", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	payload := sanitizeInput(c.Message().Payload)
+	if err := validatePayload(payload); err != nil || payload == "" {
+		return c.Send("Usage: /alert <btc|hashrate|tx> <>|<> <значение>\nНапример: /alert btc > 70000, /alert hashrate < 6e11")
+	}
+	a, err := ParseAlert(payload)
+	if err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	var userID int64
+	if c.Sender() != nil {
+		userID = c.Sender().ID
+	}
+	a, err = AddAlert(c.Chat().ID, userID, a)
+	if err != nil {
+		logger.L.Error("add alert", "chat", c.Chat().ID, "err", err)
+		return c.Send("❌ " + err.Error())
+	}
+	return c.Send(fmt.Sprintf("🚨 Алерт #%d: сообщу, когда %s", a.ID, a.describe()))
+}
+
+func handleAlerts(c tb.Context) error {
+	logger.L.Debug("command alerts", "chat", c.Chat().ID)
+	list := ChatAlerts(c.Chat().ID)
+	if len(list) == 0 {
+		return c.Send("🔕 Алертов нет. Добавить: /alert btc > 70000")
+	}
+	loc := chatLocation(c.Chat().ID)
+	lines := make([]string, 0, len(list))
+	for _, a := range list {
+		state := "ждёт"
+		if !a.Armed {
+			state = "сработал " + a.Fired.In(loc).Format("02.01 15:04")
+		}
+		lines = append(lines, fmt.Sprintf("#%d %s — %s", a.ID, html.EscapeString(a.describe()), state))
+	}
+	return replyLong(c, "🚨 Алерты:\n"+strings.Join(lines, "\n")+"\n\nУдалить: /unalert <id>")
+}
+
+func handleUnalert(c tb.Context) error {
+	logger.L.Debug("command unalert", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	payload := strings.TrimPrefix(sanitizeInput(c.Message().Payload), "#")
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return c.Send("Usage: /unalert <id>")
+	}
+	if err := RemoveAlert(c.Chat().ID, id); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	return c.Send(fmt.Sprintf("🗑 Алерт #%d удалён", id))
+}
@@ -3,10 +3,13 @@ package bot
 import (
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"telegram-reminder/internal/config"
+	"telegram-reminder/internal/domain"
 	"telegram-reminder/internal/logger"
+	"telegram-reminder/internal/prompt"
 
 	"github.com/go-co-op/gocron"
 	openai "github.com/sashabaranov/go-openai"
@@ -45,7 +48,7 @@ func New(cfg config.Config) (*Bot, error) {
 	oaCfg.HTTPClient = logger.NewHTTPClient(OpenAITimeout)
 	client := openai.NewClientWithConfig(oaCfg)
 
-	tz, err := time.LoadLocation("Europe/Moscow")
+	tz, err := time.LoadLocation(cfg.Timezone)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load timezone: %w", err)
 	}
@@ -61,15 +64,133 @@ func New(cfg config.Config) (*Bot, error) {
 	return b, nil
 }
 
+// botCommand is a built-in command registered by Start.
+type botCommand struct {
+	name    string
+	handler func(b *Bot) tb.HandlerFunc
+	// access puts the command behind AccessMiddleware.
+	access bool
+}
+
+// static adapts a handler that needs nothing from the bot.
+func static(h tb.HandlerFunc) func(*Bot) tb.HandlerFunc {
+	return func(*Bot) tb.HandlerFunc { return h }
+}
+
+// botCommands lists the built-in commands. Digest commands are registered
+// separately from the digest configs. It is a function rather than a variable
+// because /addtask checks new task names against it.
+func botCommands() []botCommand {
+	return []botCommand{
+		{name: "/ping", handler: static(handlePing)},
+		{name: "/start", handler: static(handleStart)},
+		{name: "/whitelist", handler: static(handleWhitelist)},
+		{name: "/groups", handler: static(handleGroups)},
+		{name: "/stats", handler: static(handleStats)},
+		{name: "/remove", handler: static(handleRemove)},
+		{name: "/subscribe", handler: static(handleSubscribe)},
+		{name: "/unsubscribe", handler: static(handleUnsubscribe)},
+		{name: "/subscriptions", handler: static(handleSubscriptions)},
+		{name: "/tz", handler: static(handleTimezone)},
+		{name: "/mytime", handler: static(handleMyTime)},
+		{name: "/remind", handler: static(handleRemind), access: true},
+		{name: "/reminders", handler: static(handleReminders), access: true},
+		{name: "/unremind", handler: static(handleUnremind), access: true},
+		{name: "/alert", handler: static(handleAlert), access: true},
+		{name: "/alerts", handler: static(handleAlerts), access: true},
+		{name: "/unalert", handler: static(handleUnalert), access: true},
+		{name: "/promote", handler: static(handlePromote)},
+		{name: "/demote", handler: static(handleDemote)},
+		{name: "/tasks", handler: static(handleTasks)},
+		{name: "/reload", handler: static(handleReload)},
+		{name: "/addtask", handler: static(handleAddTask)},
+		{name: "/edittask", handler: static(handleEditTask)},
+		{name: "/settime", handler: static(handleSetTime)},
+		{name: "/pausetask", handler: static(handlePauseTask)},
+		{name: "/resumetask", handler: static(handleResumeTask)},
+		{name: "/deltask", handler: static(handleDeleteTask)},
+		{name: "/history", handler: static(handleHistory)},
+		{name: "/lastrun", handler: static(handleLastRun)},
+		{name: "/preview", handler: func(b *Bot) tb.HandlerFunc { return handlePreview(b.Client) }},
+		{name: "/task", handler: func(b *Bot) tb.HandlerFunc { return handleTask(b.Client) }, access: true},
+		{name: "/model", handler: static(handleModel())},
+		{name: "/lunch", handler: func(b *Bot) tb.HandlerFunc { return handleLunch(b.Client) }, access: true},
+		{name: "/brief", handler: func(b *Bot) tb.HandlerFunc { return handleBrief(b.Client) }, access: true},
+		{name: "/blockchain", handler: func(b *Bot) tb.HandlerFunc { return handleBlockchain(b.Config.BlockchainAPI) }},
+		{name: "/chat", handler: func(b *Bot) tb.HandlerFunc { return handleChat(b.Client) }, access: true},
+		{name: "/search", handler: static(handleSearch()), access: true},
+		{name: "/webdoc", handler: static(handleWebDoc())},
+	}
+}
+
+// builtinCommandNames returns the names of all registered built-in commands,
+// digest commands included, without the leading slash.
+func builtinCommandNames() []string {
+	cmds := botCommands()
+	names := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		names = append(names, strings.TrimPrefix(c.name, "/"))
+	}
+	for _, cfg := range domain.GetDigestConfigs() {
+		names = append(names, cfg.CommandName)
+	}
+	return names
+}
+
 // Start registers handlers, schedules tasks and starts the bot.
 func (b *Bot) Start() error {
 	logger.L.Info("authorized", "user", b.TeleBot.Me.Username)
 
+	lock, err := OpenRunLock(b.Config.RunLock, b.Config.RunLockFile)
+	if err != nil {
+		return fmt.Errorf("open run lock: %w", err)
+	}
+	defer func() {
+		if err := lock.Close(); err != nil {
+			logger.L.Error("close run lock", "err", err)
+		}
+	}()
+	// A standby replica waits here without polling Telegram or scheduling
+	// anything until the leader exits.
+	if err := waitForLeadership(lock); err != nil {
+		return fmt.Errorf("run lock: %w", err)
+	}
+	SetRunLock(lock)
+
 	if b.Config.LogChatID != 0 {
 		logger.EnableTelegramLogging(b.Config.TelegramToken, b.Config.LogChatID, slog.LevelError)
 	}
 
 	b.TeleBot.Use(logger.TelebotMiddleware())
+	b.TeleBot.Use(PermissionMiddleware())
+
+	SetAccessPolicy(AccessPolicy(b.Config.AccessPolicy))
+	SetApprovalRequired(b.Config.RequireApproval)
+	SetJobLimits(b.Config.MaxConcurrentJobs, b.Config.JobLimitMode)
+	prompt.SetDefaultLocation(b.Scheduler.Location())
+	prompt.SetEnvAllowlist(b.Config.PromptEnvAllowlist)
+	SetBootstrapAdmins(b.Config.AdminIDs)
+	if err := LoadAdmins(b.Config.AdminsFile); err != nil {
+		return err
+	}
+	if len(b.Config.AdminIDs) == 0 {
+		logger.L.Warn("ADMIN_IDS is empty; privileged commands are disabled")
+	}
+
+	store, err := OpenChatStore(b.Config.ChatStore, b.Config.WhitelistFile, b.Config.ChatDBFile)
+	if err != nil {
+		return fmt.Errorf("open chat store: %w", err)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			logger.L.Error("close chat store", "err", err)
+		}
+	}()
+	if err := SetChatStore(store); err != nil {
+		return fmt.Errorf("init chat store: %w", err)
+	}
+	logger.L.Info("chat store ready", "kind", b.Config.ChatStore)
+	SetDefaultSubscriptions(b.Config.DefaultSubscriptions)
 
 	if b.Config.ChatID != 0 {
 		if err := AddIDToWhitelist(b.Config.ChatID); err != nil {
@@ -77,8 +198,39 @@ func (b *Bot) Start() error {
 		}
 	}
 
+	if err := LoadTaskOverlay(b.Config.TasksOverlayFile); err != nil {
+		return err
+	}
+	if err := LoadRunState(b.Config.RunStateFile); err != nil {
+		return err
+	}
+	if err := LoadHistory(b.Config.HistoryFile, b.Config.HistoryLimit); err != nil {
+		return err
+	}
+	if err := LoadReminders(b.Config.RemindersFile); err != nil {
+		return err
+	}
+	if err := LoadAlerts(b.Config.AlertsFile); err != nil {
+		return err
+	}
+	SetAlertHysteresis(b.Config.AlertHysteresis)
+	SetSMTPConfig(SMTPConfig{
+		Host:     b.Config.SMTPHost,
+		Port:     b.Config.SMTPPort,
+		Username: b.Config.SMTPUsername,
+		Password: b.Config.SMTPPassword,
+		From:     b.Config.SMTPFrom,
+	})
 	ScheduleDailyMessages(b.Scheduler, b.Client, b.TeleBot, b.Config.ChatID)
+	StartReminders(b.Scheduler, b.TeleBot)
+	if err := StartAlerts(b.Scheduler, b.TeleBot, b.Config.BlockchainAPI, b.Config.AlertInterval); err != nil {
+		logger.L.Error("start alerts", "err", err)
+	}
+	CatchUpMissedRuns(b.Config.CatchUpWindow)
 	RegisterTaskCommands(b.TeleBot, b.Client)
+	if err := WatchTasksFile(b.Scheduler, b.TeleBot, b.Config.TasksReloadInterval); err != nil {
+		logger.L.Error("watch tasks file", "err", err)
+	}
 
 	b.Scheduler.StartAsync()
 
@@ -88,17 +240,22 @@ func (b *Bot) Start() error {
 	msg := fmt.Sprintf("Billion Roadmap %s\n\n%s", Version, cmds)
 	SendStartupMessage(b.TeleBot, b.Config.ChatID, msg)
 
-	b.TeleBot.Handle("/ping", handlePing)
-	b.TeleBot.Handle("/start", handleStart)
-	b.TeleBot.Handle("/whitelist", handleWhitelist)
-	b.TeleBot.Handle("/groups", handleGroups)
-	b.TeleBot.Handle("/stats", handleStats)
-	b.TeleBot.Handle("/remove", handleRemove)
-	b.TeleBot.Handle("/tasks", handleTasks)
-	b.TeleBot.Handle("/task", handleTask(b.Client))
-	b.TeleBot.Handle("/model", handleModel())
-	b.TeleBot.Handle("/lunch", handleLunch(b.Client))
-	b.TeleBot.Handle("/brief", handleBrief(b.Client))
+	for _, cmd := range botCommands() {
+		if cmd.access {
+			b.TeleBot.Handle(cmd.name, cmd.handler(b), AccessMiddleware())
+		} else {
+			b.TeleBot.Handle(cmd.name, cmd.handler(b))
+		}
+	}
+	b.TeleBot.Handle(&btnApproveChat, handleChatDecision(true))
+	b.TeleBot.Handle(&btnRejectChat, handleChatDecision(false))
+	b.TeleBot.Handle(&btnRemindPick, handleRemindPick)
+	b.TeleBot.Handle(&btnReminderDone, handleReminderDone)
+	b.TeleBot.Handle(&btnReminderSnooze, handleReminderSnooze)
+	b.TeleBot.Handle(tb.OnCallback, handleStaleCallback)
+	b.TeleBot.Handle(tb.OnMyChatMember, handleMyChatMember)
+	b.TeleBot.Handle(tb.OnMigration, handleMigration)
+	b.TeleBot.Handle(tb.OnText, handleTaskCommandFallback(b.Client))
 	// Initialize new digest architecture
 	digestIntegration, err := NewDigestIntegration(b.Client, DefaultErrorHandler)
 	if err != nil {
@@ -108,11 +265,22 @@ func (b *Bot) Start() error {
 		digestIntegration.ReplaceDigestHandlers(b.TeleBot, b.Client)
 		logger.L.Info("digest handlers replaced with new architecture")
 	}
-	b.TeleBot.Handle("/blockchain", handleBlockchain(b.Config.BlockchainAPI))
-	b.TeleBot.Handle("/chat", handleChat(b.Client))
-	b.TeleBot.Handle("/search", handleSearch())
-	b.TeleBot.Handle("/webdoc", handleWebDoc())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	lost := make(chan error, 1)
+	go watchLeadership(lock, stop, func(err error) {
+		logger.L.Error("lost leadership; stopping", "err", err)
+		lost <- err
+		b.Scheduler.Stop()
+		b.TeleBot.Stop()
+	})
 
 	b.TeleBot.Start()
-	return nil
+	select {
+	case err := <-lost:
+		return fmt.Errorf("run lock: %w", err)
+	default:
+		return nil
+	}
 }
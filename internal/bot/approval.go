@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+var (
+	approvalMu       sync.RWMutex
+	approvalRequired bool
+)
+
+// Inline buttons sent to admins for a pending chat. The button data carries
+// the chat ID.
+var (
+	btnApproveChat = tb.Btn{Unique: "chat_approve"}
+	btnRejectChat  = tb.Btn{Unique: "chat_reject"}
+)
+
+// SetApprovalRequired enables the moderated mode in which /start only files a
+// request that an admin has to approve.
+func SetApprovalRequired(on bool) {
+	approvalMu.Lock()
+	approvalRequired = on
+	approvalMu.Unlock()
+}
+
+// ApprovalRequired reports whether new chats need admin approval.
+func ApprovalRequired() bool {
+	approvalMu.RLock()
+	defer approvalMu.RUnlock()
+	return approvalRequired
+}
+
+// ErrChatRejected is returned for a chat that asks for approval again after an
+// admin rejected it.
+var ErrChatRejected = errors.New("chat was rejected")
+
+// RequestChatApproval registers the chat as pending. It returns false when the
+// chat is already active or already waiting, so admins are notified once. A
+// rejected chat stays rejected and gets ErrChatRejected, so it cannot notify
+// admins again by repeating /start.
+func RequestChatApproval(chat *tb.Chat) (bool, error) {
+	wlMu.Lock()
+	defer wlMu.Unlock()
+
+	existing, exists, err := chatStore.GetChat(chat.ID)
+	if err != nil {
+		return false, err
+	}
+	if exists && (existing.Active || existing.State() == ChatPending) {
+		return false, nil
+	}
+	if exists && existing.State() == ChatRejected {
+		return false, ErrChatRejected
+	}
+
+	info := existing
+	if !exists {
+		info = ChatInfo{
+			ID:            chat.ID,
+			AddedAt:       time.Now(),
+			Subscriptions: DefaultSubscriptions(),
+		}
+	}
+	info.Type = getChatTypeString(chat.Type)
+	info.Title = getChatTitle(chat)
+	info.Username = getChatUsername(chat)
+	info.Active = false
+	info.Status = ChatPending
+	if err := chatStore.SaveChat(info); err != nil {
+		return false, err
+	}
+	logger.L.Info("chat pending approval", "id", chat.ID, "title", info.Title)
+	return true, nil
+}
+
+// setChatStatus moves a registered chat to the given moderation state.
+func setChatStatus(id int64, status string) (ChatInfo, error) {
+	var out ChatInfo
+	err := updateChat(id, func(c *ChatInfo) error {
+		c.Status = status
+		c.Active = status == ChatApproved
+		out = *c
+		return nil
+	})
+	return out, err
+}
+
+// ApproveChat activates a pending chat for broadcasts.
+func ApproveChat(id int64) (ChatInfo, error) {
+	return setChatStatus(id, ChatApproved)
+}
+
+// RejectChat marks a chat as rejected; it receives no broadcasts.
+func RejectChat(id int64) (ChatInfo, error) {
+	return setChatStatus(id, ChatRejected)
+}
+
+// approvalMarkup builds the Approve/Reject keyboard for a chat.
+func approvalMarkup(id int64) *tb.ReplyMarkup {
+	m := &tb.ReplyMarkup{}
+	data := strconv.FormatInt(id, 10)
+	m.Inline(m.Row(
+		m.Data("✅ Одобрить", btnApproveChat.Unique, data),
+		m.Data("🚫 Отклонить", btnRejectChat.Unique, data),
+	))
+	return m
+}
+
+// notifyAdminsAboutChat sends an approval request for the chat to every admin.
+func notifyAdminsAboutChat(b *tb.Bot, chat *tb.Chat) {
+	text := fmt.Sprintf("🆕 Запрос на подключение\n%s %s\nID: %d",
+		getChatTypeRussian(getChatTypeString(chat.Type)), getChatTitle(chat), chat.ID)
+	notifyAdmins(b, text, approvalMarkup(chat.ID))
+}
+
+// handleChatDecision returns the callback handler for the Approve or Reject
+// button. Only admins may decide.
+func handleChatDecision(approve bool) func(tb.Context) error {
+	return func(c tb.Context) error {
+		var userID int64
+		if c.Sender() != nil {
+			userID = c.Sender().ID
+		}
+		if !IsAdmin(userID) {
+			logger.GetSecurityLogger().SecurityEvent("permission_denied", userID, map[string]interface{}{
+				"command":  "chat_approval",
+				"required": RoleAdmin.String(),
+			})
+			return c.Respond(&tb.CallbackResponse{Text: "⛔ Недостаточно прав"})
+		}
+
+		id, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+		if err != nil {
+			return c.Respond(&tb.CallbackResponse{Text: "Bad ID"})
+		}
+
+		decide, verdict, notice := RejectChat, "🚫 Отклонён", "🚫 Запрос на подключение бота отклонён"
+		if approve {
+			decide, verdict, notice = ApproveChat, "✅ Одобрен", "🎉 Бот активирован! Вы будете получать ежедневные дайджесты"
+		}
+		chat, err := decide(id)
+		if err != nil {
+			logger.L.Error("chat decision", "chat_id", id, "err", err)
+			return c.Respond(&tb.CallbackResponse{Text: "❌ " + err.Error()})
+		}
+		logger.GetSecurityLogger().SecurityEvent("chat_"+chat.State(), userID, map[string]interface{}{
+			"chat_id": id,
+		})
+
+		if _, err := c.Bot().Send(&tb.Chat{ID: id}, notice); err != nil {
+			logger.L.Error("notify chat decision", "chat_id", id, "err", err)
+		}
+		if err := c.Edit(fmt.Sprintf("%s: %s (ID %d)", verdict, chat.Title, id)); err != nil {
+			logger.L.Debug("edit approval message", "err", err)
+		}
+		return c.Respond()
+	}
+}
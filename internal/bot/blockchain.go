@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"telegram-reminder/internal/logger"
+)
+
+// BlockchainStats is the part of the BLOCKCHAIN_API response the bot uses.
+type BlockchainStats struct {
+	MarketPriceUSD float64 `json:"market_price_usd"`
+	NTx            int64   `json:"n_tx"`
+	HashRate       float64 `json:"hash_rate"`
+}
+
+// FetchBlockchainStats reads the current stats from apiURL.
+func FetchBlockchainStats(ctx context.Context, apiURL string) (BlockchainStats, error) {
+	var st BlockchainStats
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return st, fmt.Errorf("blockchain request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return st, fmt.Errorf("blockchain call: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.L.Error("failed to close response body", "err", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return st, fmt.Errorf("blockchain status: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return st, fmt.Errorf("blockchain decode: %w", err)
+	}
+	return st, nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"html"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -153,11 +152,24 @@ var baseCommands = []string{
 	"/search <запрос> – выполнить поиск через OpenAI",
 	"/ping – проверка состояния",
 	"/start – добавить текущий чат в рассылку (работает в группах!)",
-	"/whitelist – показать список подключённых чатов с деталями",
-	"/remove <id> – убрать чат из списка",
-	"/groups – показать только групповые чаты",
-	"/stats – статистика по чатам",
-	"/model [имя] – показать или сменить модель (по умолчанию gpt-4.1)",
+	"/whitelist – показать список подключённых чатов с деталями (админ)",
+	"/remove <id> – убрать чат из списка (админ)",
+	"/groups – показать только групповые чаты (админ)",
+	"/stats – статистика по чатам и очереди задач (админ)",
+	"/subscribe <задача|дайджест> – подписать чат на задачу или тип дайджеста",
+	"/unsubscribe <задача|дайджест> – отписать чат",
+	"/subscriptions – показать подписки чата",
+	"/tz [зона|reset] – показать или сменить часовой пояс чата",
+	"/mytime <задача> <HH:MM|reset> – своё время доставки задачи",
+	"/remind <когда> <текст> – напомнить в этот чат (15:30, 2026-11-01 09:00, через 20 минут, in 2h, каждый будний день в 8:30)",
+	"/reminders – напоминания этого чата",
+	"/unremind <id> – удалить напоминание",
+	"/alert <btc|hashrate|tx> <>|<> <значение> – уведомить, когда показатель пересечёт порог",
+	"/alerts – алерты этого чата",
+	"/unalert <id> – удалить алерт",
+	"/model [имя] – показать или сменить модель (смена – админ)",
+	"/promote <id> – назначить администратора (владелец)",
+	"/demote <id> – снять администратора (владелец)",
 	"/lunch – немедленно запросить идеи на обед",
 	"/brief – немедленно запросить вечерний дайджест",
 	"/crypto – криптовалютный дайджест",
@@ -169,7 +181,18 @@ var baseCommands = []string{
 	"/global – глобальный дайджест",
 	"/tasks – вывести текущее расписание задач",
 	"/task [имя] – список задач или запуск выбранной",
+	"/reload – перечитать файл задач (админ)",
+	"/addtask <имя> <HH:MM> <промпт> – добавить задачу (админ)",
+	"/edittask <имя> <промпт> – изменить промпт задачи (админ)",
+	"/settime <имя> <HH:MM> – изменить время задачи (админ)",
+	"/pausetask <имя> – приостановить задачу (админ)",
+	"/resumetask <имя> – возобновить задачу (админ)",
+	"/deltask <имя> – удалить задачу (админ)",
+	"/history [задача] – история запусков задач (админ)",
+	"/lastrun <задача> [send] – последний результат задачи или повторная рассылка (админ)",
+	"/preview <задача> [prompt] – пробный запуск задачи или дайджеста только в этот чат (админ)",
 	"/blockchain – метрики сети биткоина",
+	"/webdoc – документация по формату web_search",
 }
 
 func buildCommandsList(tasks []Task) string {
@@ -203,6 +226,28 @@ type Task struct {
 	Time   string `json:"time,omitempty" yaml:"time,omitempty"`
 	Cron   string `json:"cron,omitempty" yaml:"cron,omitempty"`
 	Model  string `json:"model,omitempty" yaml:"model,omitempty"`
+	// Paused tasks stay in the list but are not scheduled.
+	Paused bool `json:"paused,omitempty" yaml:"paused,omitempty"`
+	// Retry is the retry policy for failed scheduled runs; nil means one attempt.
+	Retry *RetryPolicy `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// CatchUp controls whether runs missed during downtime are run on
+	// startup; nil means yes.
+	CatchUp *bool `json:"catch_up,omitempty" yaml:"catch_up,omitempty"`
+	// DependsOn lists tasks whose output this task needs. A task with
+	// upstreams but without time or cron runs after they complete.
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	// Digest names a digest type whose prompt the task uses; Prompt is then
+	// appended as extra instructions and may be empty.
+	Digest string `json:"digest,omitempty" yaml:"digest,omitempty"`
+	// Jitter delays each scheduled run by a random duration up to this
+	// value, such as "2m", so tasks sharing a minute do not start at once.
+	Jitter string `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	// Sinks mirror scheduled results to webhooks, email or a Markdown
+	// archive after the Telegram delivery.
+	Sinks []SinkConfig `json:"sinks,omitempty" yaml:"sinks,omitempty"`
+	// GenParams override the generation settings of the runtime
+	// configuration for this task.
+	GenParams `yaml:",inline"`
 }
 
 var (
@@ -247,27 +292,25 @@ var (
 	}
 )
 
+// isSupportedModel reports whether model can be selected with /model or in
+// a tasks file.
+func isSupportedModel(model string) bool {
+	for _, m := range SupportedModels {
+		if model == m {
+			return true
+		}
+	}
+	return false
+}
+
 var (
 	LoadedTasks []Task
 	TasksMu     sync.RWMutex
 )
 
-// applyTemplate replaces placeholders in the prompt with runtime values.
-func applyTemplate(prompt, model string) string {
-	vars := map[string]string{
-		"base_prompt":  runtimeConfig.BasePrompt,
-		"date":         time.Now().Format("2006-01-02"),
-		"exchange_api": os.Getenv("EXCHANGE_API"),
-		"chart_path":   os.Getenv("CHART_PATH"),
-		"model":        model,
-	}
-	for k, v := range vars {
-		prompt = strings.ReplaceAll(prompt, "{"+k+"}", v)
-	}
-	return prompt
-}
-
-// RegisterTaskCommands creates bot handlers for all named tasks.
+// RegisterTaskCommands creates bot handlers for all named tasks. Handlers
+// look the task up on every call, so edits picked up by ReloadTasks apply
+// without re-registering.
 func RegisterTaskCommands(b *tb.Bot, client ChatCompleter) {
 	TasksMu.RLock()
 	tasks := append([]Task(nil), LoadedTasks...)
@@ -276,129 +319,175 @@ func RegisterTaskCommands(b *tb.Bot, client ChatCompleter) {
 		if t.Name == "" {
 			continue
 		}
-		tcopy := t
-		cmd := "/" + t.Name
-		b.Handle(cmd, func(c tb.Context) error {
-			ctx, cancel := context.WithTimeout(context.Background(), OpenAITimeout)
-			defer cancel()
-			ModelMu.RLock()
-			model := runtimeConfig.CurrentModel
-			ModelMu.RUnlock()
-			if tcopy.Model != "" {
-				model = tcopy.Model
-			}
-			prompt := applyTemplate(tcopy.Prompt, model)
-			resp, err := SystemCompletion(ctx, client, prompt, model)
-			if err != nil {
-				logger.L.Error("openai error", "task", tcopy.Name, "model", model, "err", err)
-				return c.Send(formatOpenAIError(err, model))
-			}
-			return c.Send(resp)
-		})
+		b.Handle("/"+t.Name, runTaskCommand(client, t.Name), AccessMiddleware())
 	}
 }
 
-// createTaskJob creates a job function for a scheduled task
-func createTaskJob(task Task, client ChatCompleter, b *tb.Bot, chatID int64) func() {
-	return func() {
+// runTaskCommand runs the named task on demand and replies with the result.
+func runTaskCommand(client ChatCompleter, name string) func(tb.Context) error {
+	return func(c tb.Context) error {
+		TasksMu.RLock()
+		task, ok := FindTask(LoadedTasks, name)
+		TasksMu.RUnlock()
+		if !ok {
+			return c.Send("unknown task")
+		}
 		ctx, cancel := context.WithTimeout(context.Background(), OpenAITimeout)
 		defer cancel()
-
-		model := getRuntimeConfig().CurrentModel
-		if task.Model != "" {
-			model = task.Model
+		model := taskModel(task)
+		prompt, err := taskPrompt(task, model, chatScope(c.Chat()))
+		if err != nil {
+			return c.Send("❌ " + err.Error())
 		}
-
-		taskLogger := logger.GetTaskLogger()
-		openaiLogger := logger.GetOpenAILogger()
-
-		op := taskLogger.Operation("task_execution")
-		op.WithContext("task_name", task.Name)
-		op.WithContext("model", model)
-		op.WithContext("chat_id", chatID)
-
-		op.Step("preparing_prompt")
-		prompt := applyTemplate(task.Prompt, model)
-
-		op.Step("calling_openai")
-		startTime := time.Now()
-		resp, err := SystemCompletion(ctx, client, prompt, model)
-		duration := time.Since(startTime)
-
-		openaiLogger.APICall("openai", "system_completion", err == nil, duration, err)
-
+		resp, err := TaskCompletion(ctx, client, task, prompt, model)
 		if err != nil {
-			op.Failure("Task execution failed", err)
-			DefaultErrorHandler.HandleTaskError(err, task.Name, model)
-			return
+			logger.L.Error("openai error", "task", task.Name, "model", model, "err", err)
+			return c.Send(formatOpenAIError(err, model))
 		}
+		return c.Send(resp)
+	}
+}
 
-		op.WithContext("response_length", len(resp))
-		op.Step("broadcasting_result")
+// handleTaskCommandFallback serves commands of tasks added by a reload after
+// the bot started; telebot routes unknown commands to OnText. Other text is
+// ignored.
+func handleTaskCommandFallback(client ChatCompleter) func(tb.Context) error {
+	return func(c tb.Context) error {
+		if c.Message() == nil {
+			return nil
+		}
+		cmd, _ := parseCommand(c.Message().Text)
+		name := strings.TrimPrefix(cmd, "/")
+		if name == "" {
+			return nil
+		}
+		TasksMu.RLock()
+		_, ok := FindTask(LoadedTasks, name)
+		TasksMu.RUnlock()
+		if !ok {
+			return nil
+		}
+		return AccessMiddleware()(runTaskCommand(client, name))(c)
+	}
+}
 
-		taskLogger.TaskExecution(task.Name, true, time.Since(startTime), nil)
-		op.Success("Task completed successfully")
+// createTaskJob creates a job function for one delivery slot of a scheduled task
+func createTaskJob(task Task, slot deliverySlot, client ChatCompleter, b *tb.Bot, chatID int64) func() {
+	return func() {
+		scheduled := time.Now()
+		if d := randomDelay(task.jitter()); d > 0 {
+			logger.L.Debug("task jitter", "task", task.Name, "delay", d)
+			time.Sleep(d)
+		}
+		startTaskRun(taskRun{
+			task:      task,
+			slot:      slot,
+			client:    client,
+			bot:       b,
+			chatID:    chatID,
+			scheduled: scheduled,
+			attempt:   1,
+		})
+	}
+}
 
-		broadcastTaskResult(b, chatID, resp)
+// startTaskRun runs a task for its slot unless nobody receives that slot or
+// another instance has already claimed the run.
+func startTaskRun(run taskRun) {
+	if run.chatID == 0 && !run.slot.isDefault(run.task) {
+		ids, err := recipientsForTask(run.task, run.slot)
+		if err == nil && len(ids) == 0 {
+			logger.L.Debug("skip slot without recipients", "task", run.task.Name, "slot", run.slot.String())
+			return
+		}
+	}
+	if !claimRun(run) {
+		return
 	}
+	runTaskWithRetry(run)
 }
 
-// broadcastTaskResult sends task result to specified chat or all whitelisted chats
-func broadcastTaskResult(b *tb.Bot, chatID int64, text string) {
+// broadcastTaskResult sends task result to specified chat or to all active
+// chats subscribed to the task whose delivery slot matches. It returns the
+// chats that received the message and those that failed.
+func broadcastTaskResult(b *tb.Bot, chatID int64, task Task, slot deliverySlot, text string) (delivered, failed []int64) {
 	if chatID != 0 {
-		if err := sendLong(b, tb.ChatID(chatID), text); err != nil {
+		if err := deliverToChat(b, chatID, text); err != nil {
 			DefaultErrorHandler.HandleTelegramError(err, chatID)
+			return nil, []int64{chatID}
 		}
-		return
+		return []int64{chatID}, nil
 	}
 
 	// Use new active chats system for better group support
-	ids, err := GetActiveChats()
+	ids, err := recipientsForTask(task, slot)
 	if err != nil {
 		logger.L.Error("load active chats", "err", err)
-		return
+		return nil, nil
 	}
 
-	logger.L.Info("broadcasting to active chats", "recipients", len(ids))
+	logger.L.Info("broadcasting to active chats", "task", task.Name, "slot", slot.String(), "recipients", len(ids))
 	for _, id := range ids {
-		if err := sendLong(b, tb.ChatID(id), text); err != nil {
+		if err := deliverToChat(b, id, text); err != nil {
 			DefaultErrorHandler.HandleTelegramError(err, id)
 			logger.L.Warn("failed to send to chat", "chat_id", id, "error", err)
+			failed = append(failed, id)
 		} else {
 			logger.L.Debug("message sent successfully", "chat_id", id)
+			delivered = append(delivered, id)
 		}
 	}
+	return delivered, failed
+}
+
+// schedulerMu serialises changes to the jobs of a scheduler once the bot is
+// running. The gocron job builder (Every/Cron, Tag, Do) keeps its state on the
+// scheduler and is not safe for concurrent use, while reminders, the tasks
+// watcher and admin commands add and remove jobs from many goroutines,
+// including from inside running jobs.
+var schedulerMu sync.Mutex
+
+// removeJobs removes the jobs of s tagged with tag, if there are any.
+func removeJobs(s *gocron.Scheduler, tag string) {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	_ = s.RemoveByTag(tag)
 }
 
-// scheduleTask schedules a single task in the scheduler
+// scheduleTask schedules a single task in the scheduler using its own schedule
 func scheduleTask(s *gocron.Scheduler, task Task, job func()) error {
-	var j *gocron.Job
-	var err error
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	return scheduleTaskAt(s, task, defaultSlot(task), job)
+}
 
+// scheduleTaskAt schedules a task job for the given delivery slot. Slots in a
+// foreign timezone are expressed as CRON_TZ cron expressions. schedulerMu
+// must be held.
+func scheduleTaskAt(s *gocron.Scheduler, task Task, slot deliverySlot, job func()) error {
 	switch {
-	case task.Cron != "":
+	case slot.Time == "" && slot.Zone == "":
 		logger.L.Debug("schedule cron", "name", task.Name, "cron", task.Cron)
-		j, err = s.Cron(task.Cron).Do(job)
+		s = s.Cron(task.Cron)
+	case slot.Time == "":
+		logger.L.Debug("schedule cron", "name", task.Name, "cron", task.Cron, "zone", slot.Zone)
+		s = s.Cron(fmt.Sprintf("CRON_TZ=%s %s", slot.Zone, task.Cron))
+	case slot.Zone == "":
+		logger.L.Debug("schedule daily", "name", task.Name, "schedule_time", slot.Time)
+		s = s.Every(1).Day().At(slot.Time)
 	default:
-		timeStr := task.Time
-		if timeStr == "" {
-			timeStr = "00:00"
+		at, err := parseClock(slot.Time)
+		if err != nil {
+			return err
 		}
-		logger.L.Debug("schedule daily", "name", task.Name, "schedule_time", timeStr)
-		j, err = s.Every(1).Day().At(timeStr).Do(job)
+		logger.L.Debug("schedule daily", "name", task.Name, "schedule_time", slot.Time, "zone", slot.Zone)
+		s = s.CronWithSeconds(fmt.Sprintf("CRON_TZ=%s %d %d %d * * *", slot.Zone, at.Second(), at.Minute(), at.Hour()))
 	}
-
-	if err != nil {
-		return err
-	}
-
-	// Register event listeners for monitoring (debug logging removed to prevent spam)
-	j.RegisterEventListeners(
-		gocron.WhenJobReturnsError(func(jobName string, err error) { logger.L.Error("job error", "job", task.Name, "err", err) }),
-	)
-	j.Tag(task.Name)
-
-	return nil
+	// Tags are set before Do: the job is live in a running scheduler as soon
+	// as Do returns. The job function returns nothing, so gocron has no job
+	// errors to report; createTaskJob logs failures itself.
+	_, err := s.Tag(task.Name, slot.tag(task)).Do(job)
+	return err
 }
 
 // ScheduleDailyMessages sets up the daily lunch idea and brief messages.
@@ -410,14 +499,21 @@ func ScheduleDailyMessages(s *gocron.Scheduler, client ChatCompleter, b *tb.Bot,
 	}
 
 	logger.L.Debug("loaded tasks", "count", len(tasks))
+	if err := ValidateTasks(tasks); err != nil {
+		logger.L.Error("invalid tasks", "err", err)
+	}
 
 	TasksMu.Lock()
 	LoadedTasks = tasks
 	TasksMu.Unlock()
 
+	r := &taskRunner{scheduler: s, client: client, bot: b, chatID: chatID}
+	setTaskRunner(r)
+
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
 	for _, task := range tasks {
-		job := createTaskJob(task, client, b, chatID)
-		if err := scheduleTask(s, task, job); err != nil {
+		if err := r.scheduleTaskSlots(task); err != nil {
 			logger.L.Error("schedule job", "task", task.Name, "err", err)
 		}
 	}
@@ -440,7 +536,7 @@ func SendStartupMessage(b *tb.Bot, chatID int64, msg string) {
 	}
 	logger.L.Info("sending startup message to chats", "recipients", len(ids))
 	for _, id := range ids {
-		if err := sendLong(b, tb.ChatID(id), msg); err != nil {
+		if err := deliverToChat(b, id, msg); err != nil {
 			logger.L.Error("telegram send", "chat_id", id, "err", err)
 		} else {
 			logger.L.Debug("startup message sent", "chat_id", id)
@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+// runStateFile is the on-disk layout of the last successful run per slot.
+type runStateFile struct {
+	LastRuns map[string]time.Time `json:"last_runs"`
+}
+
+var (
+	runStateMu   sync.Mutex
+	lastRuns     = map[string]time.Time{}
+	runStatePath string // empty keeps run state in memory only
+)
+
+// LoadRunState reads the last successful run times from path and persists
+// later runs there. A missing file yields an empty state.
+func LoadRunState(path string) error {
+	var rs runStateFile
+	if err := loadJSONFile(path, &rs); err != nil {
+		return fmt.Errorf("load run state %s: %w", path, err)
+	}
+	runStateMu.Lock()
+	lastRuns = rs.LastRuns
+	if lastRuns == nil {
+		lastRuns = map[string]time.Time{}
+	}
+	runStatePath = path
+	runStateMu.Unlock()
+	return nil
+}
+
+// ResetRunState forgets all recorded runs. Used in tests.
+func ResetRunState() {
+	runStateMu.Lock()
+	lastRuns = map[string]time.Time{}
+	runStatePath = ""
+	runStateMu.Unlock()
+}
+
+// recordSuccessfulRun stores the scheduled time of a delivered run.
+func recordSuccessfulRun(key string, at time.Time) {
+	runStateMu.Lock()
+	defer runStateMu.Unlock()
+	if prev, ok := lastRuns[key]; ok && !at.After(prev) {
+		return
+	}
+	lastRuns[key] = at
+	if runStatePath == "" {
+		return
+	}
+	if err := saveJSONFile(runStatePath, runStateFile{LastRuns: lastRuns}); err != nil {
+		logger.L.Error("save run state", "file", runStatePath, "err", err)
+	}
+}
+
+// lastSuccessfulRun returns when the slot last ran successfully.
+func lastSuccessfulRun(key string) (time.Time, bool) {
+	runStateMu.Lock()
+	defer runStateMu.Unlock()
+	t, ok := lastRuns[key]
+	return t, ok
+}
+
+// catchUpEnabled reports whether missed runs of the task are caught up.
+func (t Task) catchUpEnabled() bool {
+	return t.CatchUp == nil || *t.CatchUp
+}
+
+// slotSchedule returns the cron schedule and location of a delivery slot.
+func slotSchedule(task Task, slot deliverySlot, loc *time.Location) (cron.Schedule, *time.Location, error) {
+	if slot.Zone != "" {
+		zone, err := time.LoadLocation(slot.Zone)
+		if err != nil {
+			return nil, nil, err
+		}
+		loc = zone
+	}
+	expr := task.Cron
+	if slot.Time != "" {
+		at, err := parseClock(slot.Time)
+		if err != nil {
+			return nil, nil, err
+		}
+		expr = fmt.Sprintf("%d %d * * *", at.Minute(), at.Hour())
+	}
+	sched, err := cron.ParseStandard(expr)
+	return sched, loc, err
+}
+
+// lastOccurrence returns the latest fire time of the slot in (from, to].
+func lastOccurrence(task Task, slot deliverySlot, loc *time.Location, from, to time.Time) (time.Time, bool, error) {
+	sched, loc, err := slotSchedule(task, slot, loc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var last time.Time
+	found := false
+	for t := sched.Next(from.In(loc)); !t.After(to); t = sched.Next(t) {
+		last, found = t, true
+	}
+	return last, found, nil
+}
+
+// MissedRun is a slot that should have fired while the bot was down.
+type MissedRun struct {
+	Task      string
+	Slot      string
+	Scheduled time.Time
+
+	task Task
+	slot deliverySlot
+}
+
+// FindMissedRuns returns slots that fired within window before now but have
+// no successful run recorded since. Slots that never ran are skipped, so a
+// fresh deployment does not send anything early.
+func FindMissedRuns(now time.Time, window time.Duration) []MissedRun {
+	r := currentTaskRunner()
+	if r == nil || window <= 0 {
+		return nil
+	}
+	TasksMu.RLock()
+	tasks := append([]Task(nil), LoadedTasks...)
+	TasksMu.RUnlock()
+
+	var missed []MissedRun
+	for _, task := range tasks {
+		if task.Paused || task.triggered() || !task.catchUpEnabled() {
+			continue
+		}
+		slots, err := taskSlots(task, r.chatID)
+		if err != nil {
+			logger.L.Error("load delivery slots", "task", task.Name, "err", err)
+		}
+		for _, slot := range slots {
+			at, ok, err := lastOccurrence(task, slot, r.scheduler.Location(), now.Add(-window), now)
+			if err != nil {
+				logger.L.Error("catch up schedule", "task", task.Name, "slot", slot.String(), "err", err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			last, ran := lastSuccessfulRun(slot.tag(task))
+			if !ran || !last.Before(at) {
+				continue
+			}
+			missed = append(missed, MissedRun{Task: task.Name, Slot: slot.String(), Scheduled: at, task: task, slot: slot})
+		}
+	}
+	sort.Slice(missed, func(i, j int) bool { return missed[i].Scheduled.Before(missed[j].Scheduled) })
+	return missed
+}
+
+// CatchUpMissedRuns runs every slot missed within window once, in the
+// background, and marks the output as delayed.
+func CatchUpMissedRuns(window time.Duration) {
+	r := currentTaskRunner()
+	if r == nil {
+		return
+	}
+	for _, m := range FindMissedRuns(time.Now(), window) {
+		logger.L.Info("catching up missed run", "task", m.Task, "slot", m.Slot, "scheduled", m.Scheduled)
+		go startTaskRun(taskRun{
+			task:      m.task,
+			slot:      m.slot,
+			client:    r.client,
+			bot:       r.bot,
+			chatID:    r.chatID,
+			scheduled: m.Scheduled,
+			attempt:   1,
+			delayed:   true,
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// MigrateChat moves a chat's registry entry, including subscriptions and
+// delivery settings, from a group ID to its new supergroup ID, together with
+// the chat's reminders and alerts. When the new ID is already registered, the
+// settings it lacks are taken from the old entry.
+func MigrateChat(oldID, newID int64) error {
+	if err := migrateChatEntry(oldID, newID); err != nil {
+		return err
+	}
+	if err := migrateReminders(oldID, newID); err != nil {
+		return err
+	}
+	return migrateAlerts(oldID, newID)
+}
+
+// migrateChatEntry moves the registry entry of a migrated chat.
+func migrateChatEntry(oldID, newID int64) error {
+	wlMu.Lock()
+	defer wlMu.Unlock()
+
+	chat, ok, err := chatStore.GetChat(oldID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	merged, exists, err := chatStore.GetChat(newID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if merged.Subscriptions == nil {
+			merged.Subscriptions = chat.Subscriptions
+		}
+		if merged.Timezone == "" {
+			merged.Timezone = chat.Timezone
+		}
+		if len(merged.TaskTimes) == 0 {
+			merged.TaskTimes = chat.TaskTimes
+		}
+		if merged.Status == "" {
+			merged.Status = chat.Status
+		}
+	} else {
+		merged = chat
+		merged.ID = newID
+		merged.Type = "supergroup"
+	}
+	if err := chatStore.SaveChat(merged); err != nil {
+		return err
+	}
+	if err := chatStore.DeleteChat(oldID); err != nil {
+		return err
+	}
+	logger.L.Info("chat migrated", "from", oldID, "to", newID, "title", chat.Title)
+	return nil
+}
+
+// deliverToChat sends text to a chat and keeps the registry in sync with
+// delivery failures: chats that blocked or kicked the bot are deactivated and
+// upgraded groups are migrated and retried under their new ID.
+func deliverToChat(b *tb.Bot, id int64, text string) error {
+	err := sendLong(b, tb.ChatID(id), text)
+	if err == nil {
+		return nil
+	}
+	if newID, ok := DefaultErrorHandler.MigratedChatID(err); ok {
+		if mErr := MigrateChat(id, newID); mErr != nil {
+			logger.L.Error("migrate chat", "from", id, "to", newID, "err", mErr)
+		}
+		return sendLong(b, tb.ChatID(newID), text)
+	}
+	if DefaultErrorHandler.IsChatUnavailable(err) {
+		logger.L.Warn("chat unavailable, deactivating", "chat_id", id, "err", err)
+		if dErr := DeactivateChat(id); dErr != nil {
+			logger.L.Error("deactivate chat", "chat_id", id, "err", dErr)
+		}
+	}
+	return err
+}
+
+// handleMyChatMember deactivates a chat once the bot is blocked, kicked or
+// removed from it. Re-adding the bot requires /start again.
+func handleMyChatMember(c tb.Context) error {
+	upd := c.ChatMember()
+	if upd == nil || upd.Chat == nil || upd.NewChatMember == nil {
+		return nil
+	}
+	logger.L.Debug("my chat member", "chat", upd.Chat.ID, "status", upd.NewChatMember.Role)
+	switch upd.NewChatMember.Role {
+	case tb.Left, tb.Kicked:
+		var userID int64
+		if upd.Sender != nil {
+			userID = upd.Sender.ID
+		}
+		logger.GetSecurityLogger().SecurityEvent("bot_removed", userID, map[string]interface{}{
+			"chat_id": upd.Chat.ID,
+			"status":  string(upd.NewChatMember.Role),
+		})
+		return DeactivateChat(upd.Chat.ID)
+	}
+	return nil
+}
+
+// handleMigration follows a group that was upgraded to a supergroup.
+func handleMigration(c tb.Context) error {
+	from, to := c.Migration()
+	if from == 0 || to == 0 {
+		return nil
+	}
+	return MigrateChat(from, to)
+}
@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+)
+
+// ChatStore persists the chat registry so that chats survive restarts.
+// Implementations must be safe for concurrent use.
+type ChatStore interface {
+	// GetChat returns the chat with the given ID, if present.
+	GetChat(id int64) (ChatInfo, bool, error)
+	// ListChats returns all known chats ordered by the time they were added.
+	ListChats() ([]ChatInfo, error)
+	// SaveChat inserts or replaces a chat.
+	SaveChat(chat ChatInfo) error
+	// DeleteChat removes a chat. Deleting an unknown chat is not an error.
+	DeleteChat(id int64) error
+	// Close releases resources held by the store.
+	Close() error
+}
+
+// sortChats orders chats by the time they were added, then by ID.
+func sortChats(chats []ChatInfo) {
+	sort.Slice(chats, func(i, j int) bool {
+		if !chats[i].AddedAt.Equal(chats[j].AddedAt) {
+			return chats[i].AddedAt.Before(chats[j].AddedAt)
+		}
+		return chats[i].ID < chats[j].ID
+	})
+}
+
+// MemoryChatStore keeps chats in memory only. It is the default store and is
+// used in tests.
+type MemoryChatStore struct {
+	mu    sync.RWMutex
+	chats map[int64]ChatInfo
+}
+
+// NewMemoryChatStore creates an empty in-memory chat store.
+func NewMemoryChatStore() *MemoryChatStore {
+	return &MemoryChatStore{chats: make(map[int64]ChatInfo)}
+}
+
+func (m *MemoryChatStore) GetChat(id int64) (ChatInfo, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	chat, ok := m.chats[id]
+	return chat, ok, nil
+}
+
+func (m *MemoryChatStore) ListChats() ([]ChatInfo, error) {
+	m.mu.RLock()
+	out := make([]ChatInfo, 0, len(m.chats))
+	for _, chat := range m.chats {
+		out = append(out, chat)
+	}
+	m.mu.RUnlock()
+	sortChats(out)
+	return out, nil
+}
+
+func (m *MemoryChatStore) SaveChat(chat ChatInfo) error {
+	m.mu.Lock()
+	m.chats[chat.ID] = chat
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryChatStore) DeleteChat(id int64) error {
+	m.mu.Lock()
+	delete(m.chats, id)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryChatStore) Close() error { return nil }
+
+// chatFile is the on-disk layout of FileChatStore.
+type chatFile struct {
+	Chats []ChatInfo `json:"chats"`
+}
+
+// FileChatStore keeps chats in a JSON file. Every change rewrites the file
+// atomically, so a crash never leaves a truncated registry behind.
+type FileChatStore struct {
+	mu    sync.RWMutex
+	path  string
+	chats map[int64]ChatInfo
+}
+
+// NewFileChatStore opens the JSON chat registry at path. A missing or empty
+// file yields an empty store. A legacy file containing a plain array of chat
+// IDs is imported once and rewritten in the current format.
+func NewFileChatStore(path string) (*FileChatStore, error) {
+	fs := &FileChatStore{path: path, chats: make(map[int64]ChatInfo)}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return fs, nil
+	}
+
+	if data[0] == '[' {
+		var ids []int64
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return nil, fmt.Errorf("parse legacy whitelist %s: %w", path, err)
+		}
+		for _, id := range ids {
+			fs.chats[id] = legacyChatInfo(id)
+		}
+		if err := fs.flushLocked(); err != nil {
+			return nil, err
+		}
+		logger.L.Info("legacy whitelist imported", "file", path, "chats", len(ids))
+		return fs, nil
+	}
+
+	var cf chatFile
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, chat := range cf.Chats {
+		fs.chats[chat.ID] = chat
+	}
+	return fs, nil
+}
+
+func (fs *FileChatStore) GetChat(id int64) (ChatInfo, bool, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	chat, ok := fs.chats[id]
+	return chat, ok, nil
+}
+
+func (fs *FileChatStore) ListChats() ([]ChatInfo, error) {
+	fs.mu.RLock()
+	out := make([]ChatInfo, 0, len(fs.chats))
+	for _, chat := range fs.chats {
+		out = append(out, chat)
+	}
+	fs.mu.RUnlock()
+	sortChats(out)
+	return out, nil
+}
+
+func (fs *FileChatStore) SaveChat(chat ChatInfo) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	prev, existed := fs.chats[chat.ID]
+	fs.chats[chat.ID] = chat
+	if err := fs.flushLocked(); err != nil {
+		if existed {
+			fs.chats[chat.ID] = prev
+		} else {
+			delete(fs.chats, chat.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (fs *FileChatStore) DeleteChat(id int64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	prev, existed := fs.chats[id]
+	if !existed {
+		return nil
+	}
+	delete(fs.chats, id)
+	if err := fs.flushLocked(); err != nil {
+		fs.chats[id] = prev
+		return err
+	}
+	return nil
+}
+
+func (fs *FileChatStore) Close() error { return nil }
+
+// flushLocked writes the registry to disk. The caller must hold fs.mu.
+func (fs *FileChatStore) flushLocked() error {
+	cf := chatFile{Chats: make([]ChatInfo, 0, len(fs.chats))}
+	for _, chat := range fs.chats {
+		cf.Chats = append(cf.Chats, chat)
+	}
+	sortChats(cf.Chats)
+	if err := saveJSONFile(fs.path, cf); err != nil {
+		return fmt.Errorf("write %s: %w", fs.path, err)
+	}
+	return nil
+}
+
+// legacyChatInfo builds a registry entry for a chat known only by its ID.
+func legacyChatInfo(id int64) ChatInfo {
+	return ChatInfo{
+		ID:      id,
+		Type:    "private", // Default assumption for legacy entries
+		Title:   fmt.Sprintf("Legacy Chat %d", id),
+		AddedAt: time.Now(),
+		Active:  true,
+	}
+}
+
+// ImportLegacyWhitelist adds the given chat IDs to the store unless they are
+// already known. It returns the number of imported chats.
+func ImportLegacyWhitelist(store ChatStore, ids []int64) (int, error) {
+	imported := 0
+	for _, id := range ids {
+		_, ok, err := store.GetChat(id)
+		if err != nil {
+			return imported, err
+		}
+		if ok {
+			continue
+		}
+		if err := store.SaveChat(legacyChatInfo(id)); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var chatsBucket = []byte("chats")
+
+// BoltChatStore keeps chats in an embedded bbolt key-value database.
+// Each chat is stored as a JSON value keyed by its decimal ID.
+type BoltChatStore struct {
+	db *bolt.DB
+}
+
+// NewBoltChatStore opens (or creates) the bbolt database at path.
+func NewBoltChatStore(path string) (*BoltChatStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(chatsBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init %s: %w", path, err)
+	}
+	return &BoltChatStore{db: db}, nil
+}
+
+func chatKey(id int64) []byte {
+	return []byte(strconv.FormatInt(id, 10))
+}
+
+func (bs *BoltChatStore) GetChat(id int64) (ChatInfo, bool, error) {
+	var chat ChatInfo
+	found := false
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(chatsBucket).Get(chatKey(id))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &chat)
+	})
+	return chat, found, err
+}
+
+func (bs *BoltChatStore) ListChats() ([]ChatInfo, error) {
+	var out []ChatInfo
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(chatsBucket).ForEach(func(_, v []byte) error {
+			var chat ChatInfo
+			if err := json.Unmarshal(v, &chat); err != nil {
+				return err
+			}
+			out = append(out, chat)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortChats(out)
+	return out, nil
+}
+
+func (bs *BoltChatStore) SaveChat(chat ChatInfo) error {
+	data, err := json.Marshal(chat)
+	if err != nil {
+		return err
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(chatsBucket).Put(chatKey(chat.ID), data)
+	})
+}
+
+func (bs *BoltChatStore) DeleteChat(id int64) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(chatsBucket).Delete(chatKey(id))
+	})
+}
+
+func (bs *BoltChatStore) Close() error {
+	return bs.db.Close()
+}
@@ -1,9 +1,13 @@
 package bot
 
 import (
+	"time"
+
 	"telegram-reminder/internal/container"
 	"telegram-reminder/internal/domain"
 	"telegram-reminder/internal/handlers"
+	"telegram-reminder/internal/logger"
+	"telegram-reminder/internal/prompt"
 	"telegram-reminder/internal/services"
 
 	tb "gopkg.in/telebot.v3"
@@ -24,6 +28,7 @@ func NewDigestIntegration(client ChatCompleter, errorHandler *ErrorHandler) (*Di
 	aiAdapter := services.NewOpenAIAdapter(client)
 	diContainer.RegisterService(container.AIClientName, aiAdapter)
 	diContainer.RegisterService(container.ErrorHandlerName, &ErrorHandlerAdapter{handler: errorHandler})
+	diContainer.RegisterService(container.ChatScoperName, ChatScopeAdapter{})
 	diContainer.RegisterConfig(container.AITimeoutConfig, OpenAITimeout)
 
 	// Build services
@@ -65,6 +70,19 @@ func (ea *ErrorHandlerAdapter) HandleOpenAIError(err error, model string) string
 	return ea.handler.HandleOpenAIError(err, model)
 }
 
+// ChatScopeAdapter describes chats for digest commands the same way as for
+// scheduled tasks, in the chat's /tz timezone.
+type ChatScopeAdapter struct{}
+
+func (ChatScopeAdapter) ChatScope(chat *tb.Chat) (prompt.Chat, *time.Location) {
+	scope := chatScope(chat)
+	loc, err := scope.location()
+	if err != nil {
+		logger.L.Warn("chat timezone", "chat", chat.ID, "err", err)
+	}
+	return scope.chat, loc
+}
+
 // ReplaceDigestHandlers replaces old digest handlers with new ones
 func (di *DigestIntegration) ReplaceDigestHandlers(bot *tb.Bot, client ChatCompleter) {
 	// Get all digest configs
@@ -76,8 +94,8 @@ func (di *DigestIntegration) ReplaceDigestHandlers(bot *tb.Bot, client ChatCompl
 		capturedType := digestType
 		handler := di.digestHandler.HandleDigest(capturedType)
 
-		// Register with bot
-		bot.Handle("/"+config.CommandName, handler)
+		// Register with bot; digests call OpenAI, so guard them
+		bot.Handle("/"+config.CommandName, handler, AccessMiddleware())
 	}
 }
 
@@ -1,10 +1,13 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
 )
 
 // ErrorHandler provides utilities for handling and formatting errors
@@ -44,6 +47,26 @@ func (eh *ErrorHandler) HandleTelegramError(err error, chatID int64) {
 	logger.L.Error("telegram error", "chat_id", chatID, "error", err)
 }
 
+// IsChatUnavailable reports whether a Telegram error means the bot can no
+// longer post to the chat: it was blocked, kicked or the chat is gone.
+func (eh *ErrorHandler) IsChatUnavailable(err error) bool {
+	if errors.Is(err, tb.ErrChatNotFound) {
+		return true
+	}
+	var tgErr *tb.Error
+	return errors.As(err, &tgErr) && tgErr.Code == 403
+}
+
+// MigratedChatID returns the new supergroup ID when a send failed because the
+// group was upgraded.
+func (eh *ErrorHandler) MigratedChatID(err error) (int64, bool) {
+	var groupErr tb.GroupError
+	if errors.As(err, &groupErr) && groupErr.MigratedTo != 0 {
+		return groupErr.MigratedTo, true
+	}
+	return 0, false
+}
+
 // HandleWhitelistError logs and formats whitelist operation errors
 func (eh *ErrorHandler) HandleWhitelistError(err error, operation string) string {
 	if err == nil {
@@ -2,9 +2,8 @@ package bot
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +30,25 @@ func handleStart(c tb.Context) error {
 
 	handlerLogger.UserAction(c.Chat().ID, "start", nil)
 
+	if ApprovalRequired() && !isChatActive(c.Chat().ID) && (c.Sender() == nil || !IsAdmin(c.Sender().ID)) {
+		requested, err := RequestChatApproval(c.Chat())
+		if errors.Is(err, ErrChatRejected) {
+			securityLogger.SecurityEvent("chat_approval_rerequested", c.Chat().ID, nil)
+			op.Success("Chat was rejected")
+			return c.Send("🚫 Запрос на подключение бота отклонён администратором")
+		}
+		if err != nil {
+			op.Failure("Failed to file approval request", err)
+			return c.Send("Ошибка активации")
+		}
+		if requested {
+			securityLogger.SecurityEvent("chat_approval_requested", c.Chat().ID, nil)
+			notifyAdminsAboutChat(c.Bot(), c.Chat())
+		}
+		op.Success("Chat awaiting approval")
+		return c.Send("⏳ Запрос на подключение отправлен администраторам. Мы сообщим, когда его одобрят")
+	}
+
 	// Use enhanced chat management for better group support
 	if err := AddChatToWhitelist(c.Chat()); err != nil {
 		securityLogger.SecurityEvent("whitelist_add_failed", c.Chat().ID, map[string]interface{}{
@@ -71,14 +89,17 @@ func handleWhitelist(c tb.Context) error {
 func handleGroups(c tb.Context) error {
 	logger.L.Debug("command groups", "chat", c.Chat().ID)
 
-	wlMu.RLock()
-	var groupChats []*ChatInfo
-	for _, chat := range chatRegistry {
+	chats, err := ListChats()
+	if err != nil {
+		logger.L.Error("list chats", "err", err)
+		return c.Send("❌ Не удалось загрузить список чатов")
+	}
+	var groupChats []ChatInfo
+	for _, chat := range chats {
 		if chat.Active && (chat.Type == "group" || chat.Type == "supergroup") {
 			groupChats = append(groupChats, chat)
 		}
 	}
-	wlMu.RUnlock()
 
 	if len(groupChats) == 0 {
 		return c.Send("👥 Нет активных групповых чатов")
@@ -115,12 +136,19 @@ func handleStats(c tb.Context) error {
 	var result strings.Builder
 	result.WriteString("📈 <b>Статистика чатов:</b>\n\n")
 	result.WriteString(fmt.Sprintf("📊 Всего чатов: <b>%d</b>\n", stats["total"]))
-	result.WriteString(fmt.Sprintf("✅ Активных: <b>%d</b>\n\n", stats["active"]))
+	result.WriteString(fmt.Sprintf("✅ Активных: <b>%d</b>\n", stats["active"]))
+	if stats["pending"] > 0 || stats["rejected"] > 0 {
+		result.WriteString(fmt.Sprintf("⏳ Ожидают одобрения: <b>%d</b>\n", stats["pending"]))
+		result.WriteString(fmt.Sprintf("🚫 Отклонено: <b>%d</b>\n", stats["rejected"]))
+	}
+	result.WriteString("\n")
 	result.WriteString("📁 <b>По типам:</b>\n")
 	result.WriteString(fmt.Sprintf("👤 Личных: <b>%d</b>\n", stats["private"]))
 	result.WriteString(fmt.Sprintf("👥 Групп: <b>%d</b>\n", stats["group"]))
 	result.WriteString(fmt.Sprintf("🏢 Супергрупп: <b>%d</b>\n", stats["supergroup"]))
 	result.WriteString(fmt.Sprintf("📢 Каналов: <b>%d</b>\n", stats["channel"]))
+	result.WriteString("\n")
+	result.WriteString(fmt.Sprintf("⚙️ Задач выполняется: <b>%d</b>, в очереди: <b>%d</b>\n", RunningJobs(), QueueDepth()))
 
 	return c.Send(result.String(), &tb.SendOptions{ParseMode: tb.ModeHTML})
 }
@@ -174,12 +202,12 @@ func handleTask(client ChatCompleter) func(tb.Context) error {
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), OpenAITimeout)
 		defer cancel()
-		model := getRuntimeConfig().CurrentModel
-		if t.Model != "" {
-			model = t.Model
+		model := taskModel(t)
+		prompt, err := taskPrompt(t, model, chatScope(c.Chat()))
+		if err != nil {
+			return c.Send("❌ " + err.Error())
 		}
-		prompt := applyTemplate(t.Prompt, model)
-		resp, err := SystemCompletion(ctx, client, prompt, model)
+		resp, err := TaskCompletion(ctx, client, t, prompt, model)
 		if err != nil {
 			return c.Send(DefaultErrorHandler.HandleOpenAIError(err, model))
 		}
@@ -202,14 +230,7 @@ func handleModel() func(tb.Context) error {
 				cur, strings.Join(SupportedModels, ", "),
 			))
 		}
-		valid := false
-		for _, m := range SupportedModels {
-			if payload == m {
-				valid = true
-				break
-			}
-		}
-		if !valid {
+		if !isSupportedModel(payload) {
 			return c.Send(fmt.Sprintf("Unsupported model: %s", payload))
 		}
 		updateRuntimeConfig(func(cfg *RuntimeConfig) {
@@ -225,7 +246,10 @@ func handleLunch(client ChatCompleter) func(tb.Context) error {
 		ctx, cancel := context.WithTimeout(context.Background(), OpenAITimeout)
 		defer cancel()
 		model := getRuntimeConfig().CurrentModel
-		prompt := applyTemplate(LunchIdeaPrompt, model)
+		prompt, err := renderPrompt(LunchIdeaPrompt, model, "lunch", chatScope(c.Chat()))
+		if err != nil {
+			return c.Send("❌ " + err.Error())
+		}
 		resp, err := SystemCompletion(ctx, client, prompt, model)
 		if err != nil {
 			logger.L.Error("openai error", "command", "lunch", "model", model, "err", err)
@@ -241,7 +265,10 @@ func handleBrief(client ChatCompleter) func(tb.Context) error {
 		ctx, cancel := context.WithTimeout(context.Background(), OpenAITimeout)
 		defer cancel()
 		model := getRuntimeConfig().CurrentModel
-		prompt := applyTemplate(DailyBriefPrompt, model)
+		prompt, err := renderPrompt(DailyBriefPrompt, model, "brief", chatScope(c.Chat()))
+		if err != nil {
+			return c.Send("❌ " + err.Error())
+		}
 		resp, err := SystemCompletion(ctx, client, prompt, model)
 		if err != nil {
 			logger.L.Error("openai error", "command", "brief", "model", model, "err", err)
@@ -256,32 +283,9 @@ func handleBlockchain(apiURL string) func(tb.Context) error {
 		logger.L.Debug("command blockchain", "chat", c.Chat().ID)
 		ctx, cancel := context.WithTimeout(context.Background(), BlockchainTimeout)
 		defer cancel()
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
-		if err != nil {
-			logger.L.Error("blockchain req", "err", err)
-			return c.Send("blockchain error")
-		}
-		resp, err := http.DefaultClient.Do(req)
+		st, err := FetchBlockchainStats(ctx, apiURL)
 		if err != nil {
-			logger.L.Error("blockchain call", "err", err)
-			return c.Send("blockchain error")
-		}
-		defer func() {
-			if err := resp.Body.Close(); err != nil {
-				logger.L.Error("failed to close response body", "err", err)
-			}
-		}()
-		if resp.StatusCode != http.StatusOK {
-			logger.L.Error("blockchain status", "status", resp.Status)
-			return c.Send("blockchain error")
-		}
-		var st struct {
-			MarketPriceUSD float64 `json:"market_price_usd"`
-			NTx            int64   `json:"n_tx"`
-			HashRate       float64 `json:"hash_rate"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
-			logger.L.Error("blockchain decode", "err", err)
+			logger.L.Error("blockchain", "err", err)
 			return c.Send("blockchain error")
 		}
 		msg := fmt.Sprintf("BTC price: $%.2f\nTransactions: %d\nHash rate: %.2f", st.MarketPriceUSD, st.NTx, st.HashRate)
@@ -0,0 +1,256 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// Final states of a scheduled run.
+const (
+	RunOK     = "ok"
+	RunFailed = "failed"
+	RunStale  = "stale"
+	// RunSkipped means the job limit was reached in skip mode.
+	RunSkipped = "skipped"
+)
+
+// DefaultHistoryLimit is the number of runs kept when no limit is configured.
+const DefaultHistoryLimit = 200
+
+// TaskRunRecord describes the outcome of one scheduled run.
+type TaskRunRecord struct {
+	Task           string    `json:"task"`
+	SlotTime       string    `json:"slot_time,omitempty"`
+	SlotZone       string    `json:"slot_zone,omitempty"`
+	Started        time.Time `json:"started"`
+	Finished       time.Time `json:"finished"`
+	Model          string    `json:"model"`
+	Status         string    `json:"status"`
+	Error          string    `json:"error,omitempty"`
+	Attempt        int       `json:"attempt"`
+	Delayed        bool      `json:"delayed,omitempty"`
+	ResponseLength int       `json:"response_length"`
+	Output         string    `json:"output,omitempty"`
+	Delivered      []int64   `json:"delivered,omitempty"`
+	Failed         []int64   `json:"failed,omitempty"`
+	// Sinks holds the per-sink results; they are filled in once the sinks
+	// finish, after the run is recorded.
+	Sinks []SinkResult `json:"sinks,omitempty"`
+}
+
+func (r TaskRunRecord) slot() deliverySlot {
+	return deliverySlot{Time: r.SlotTime, Zone: r.SlotZone}
+}
+
+// historyFile is the on-disk layout of the run history, oldest first.
+type historyFile struct {
+	Runs []TaskRunRecord `json:"runs"`
+}
+
+var (
+	historyMu    sync.RWMutex
+	history      []TaskRunRecord
+	historyLimit = DefaultHistoryLimit
+	historyPath  string // empty keeps history in memory only
+)
+
+// LoadHistory reads the run history from path, keeps at most limit runs and
+// persists new runs there. A missing file yields an empty history.
+func LoadHistory(path string, limit int) error {
+	var hf historyFile
+	if err := loadJSONFile(path, &hf); err != nil {
+		return fmt.Errorf("load history %s: %w", path, err)
+	}
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	historyMu.Lock()
+	historyPath = path
+	historyLimit = limit
+	history = trimHistory(hf.Runs, limit)
+	historyMu.Unlock()
+	return nil
+}
+
+// ResetHistory drops all recorded runs. Used in tests.
+func ResetHistory() {
+	historyMu.Lock()
+	history = nil
+	historyLimit = DefaultHistoryLimit
+	historyPath = ""
+	historyMu.Unlock()
+}
+
+func trimHistory(runs []TaskRunRecord, limit int) []TaskRunRecord {
+	if len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	return append([]TaskRunRecord(nil), runs...)
+}
+
+// recordTaskRun appends a run to the history, dropping the oldest runs beyond
+// the limit.
+func recordTaskRun(rec TaskRunRecord) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history = trimHistory(append(history, rec), historyLimit)
+	if historyPath == "" {
+		return
+	}
+	if err := saveJSONFile(historyPath, historyFile{Runs: history}); err != nil {
+		logger.L.Error("save history", "file", historyPath, "err", err)
+	}
+}
+
+// recordSinkResults attaches sink results to the recorded run of the task
+// that started at started.
+func recordSinkResults(task string, started time.Time, results []SinkResult) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Task != task || !history[i].Started.Equal(started) {
+			continue
+		}
+		history[i].Sinks = results
+		if historyPath == "" {
+			return
+		}
+		if err := saveJSONFile(historyPath, historyFile{Runs: history}); err != nil {
+			logger.L.Error("save history", "file", historyPath, "err", err)
+		}
+		return
+	}
+}
+
+// TaskHistory returns up to n most recent runs, newest first. An empty task
+// name returns runs of all tasks.
+func TaskHistory(task string, n int) []TaskRunRecord {
+	historyMu.RLock()
+	defer historyMu.RUnlock()
+	var out []TaskRunRecord
+	for i := len(history) - 1; i >= 0 && len(out) < n; i-- {
+		if task == "" || history[i].Task == task {
+			out = append(out, history[i])
+		}
+	}
+	return out
+}
+
+// LastRun returns the most recent run of the task.
+func LastRun(task string) (TaskRunRecord, bool) {
+	runs := TaskHistory(task, 1)
+	if len(runs) == 0 {
+		return TaskRunRecord{}, false
+	}
+	return runs[0], true
+}
+
+// lastSuccessfulTaskRun returns the most recent run of the task with output.
+func lastSuccessfulTaskRun(task string) (TaskRunRecord, bool) {
+	historyMu.RLock()
+	defer historyMu.RUnlock()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Task == task && history[i].Status == RunOK {
+			return history[i], true
+		}
+	}
+	return TaskRunRecord{}, false
+}
+
+// FormatRun renders a run as a single history line.
+func FormatRun(r TaskRunRecord) string {
+	icon := "✅"
+	switch r.Status {
+	case RunFailed:
+		icon = "❌"
+	case RunStale:
+		icon = "⌛"
+	case RunSkipped:
+		icon = "⏭"
+	}
+	line := fmt.Sprintf("%s %s %s — %s, %s",
+		icon, r.Started.Format("02.01 15:04"), r.Task,
+		r.Finished.Sub(r.Started).Round(100*time.Millisecond), r.Model)
+	if r.Attempt > 1 {
+		line += fmt.Sprintf(", попытка %d", r.Attempt)
+	}
+	if r.Status == RunOK {
+		line += fmt.Sprintf(", %d симв., доставлено %d/%d", r.ResponseLength, len(r.Delivered), len(r.Delivered)+len(r.Failed))
+		for _, s := range r.Sinks {
+			if s.Error == "" {
+				line += ", " + html.EscapeString(s.Sink) + " ✅"
+			} else {
+				line += ", " + html.EscapeString(s.Sink) + " ❌ " + html.EscapeString(s.Error)
+			}
+		}
+	} else if r.Error != "" {
+		line += ": " + html.EscapeString(r.Error)
+	}
+	if r.Delayed {
+		line += " (с опозданием)"
+	}
+	return line
+}
+
+func handleHistory(c tb.Context) error {
+	logger.L.Debug("command history", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	name := sanitizeInput(c.Message().Payload)
+	if err := validatePayload(name); err != nil {
+		return c.Send("Usage: /history [задача]")
+	}
+	runs := TaskHistory(name, 10)
+	if len(runs) == 0 {
+		return c.Send("📭 Запусков пока не было")
+	}
+	lines := make([]string, len(runs))
+	for i, r := range runs {
+		lines[i] = FormatRun(r)
+	}
+	return replyLong(c, "🗂 История запусков:\n"+strings.Join(lines, "\n"))
+}
+
+func handleLastRun(c tb.Context) error {
+	logger.L.Debug("command lastrun", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	payload := sanitizeInput(c.Message().Payload)
+	parts := strings.Fields(payload)
+	if err := validatePayload(payload); err != nil || len(parts) == 0 || len(parts) > 2 || (len(parts) == 2 && parts[1] != "send") {
+		return c.Send("Usage: /lastrun <задача> [send]")
+	}
+	name := parts[0]
+
+	if len(parts) == 2 {
+		run, ok := lastSuccessfulTaskRun(name)
+		if !ok {
+			return c.Send("📭 Нет успешных запусков " + name)
+		}
+		TasksMu.RLock()
+		task, ok := FindTask(LoadedTasks, name)
+		TasksMu.RUnlock()
+		if !ok {
+			task = Task{Name: name}
+		}
+		var chatID int64
+		if r := currentTaskRunner(); r != nil {
+			chatID = r.chatID
+		}
+		delivered, failed := broadcastTaskResult(c.Bot(), chatID, task, run.slot(), run.Output)
+		return c.Send(fmt.Sprintf("📤 Повторно отправлено: %d, ошибок: %d", len(delivered), len(failed)))
+	}
+
+	run, ok := LastRun(name)
+	if !ok {
+		return c.Send("📭 Запусков " + name + " пока не было")
+	}
+	text := FormatRun(run)
+	if out, ok := lastSuccessfulTaskRun(name); ok {
+		text += "\n\n" + out.Output
+	}
+	return replyLong(c, text)
+}
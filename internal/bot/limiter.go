@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// What happens to a scheduled run when the job limit is reached.
+const (
+	JobLimitQueue = "queue" // wait for a running job to finish
+	JobLimitSkip  = "skip"  // drop the run and record it as skipped
+)
+
+var (
+	jobLimitMu   sync.RWMutex
+	jobSlots     chan struct{} // nil means no limit
+	jobLimitMode = JobLimitQueue
+	jobsQueued   atomic.Int64
+	jobsRunning  atomic.Int64
+)
+
+// SetJobLimits caps the number of scheduled runs executing at once; max <= 0
+// removes the cap. mode is JobLimitQueue or JobLimitSkip. Runs already in
+// progress keep their slots.
+func SetJobLimits(max int, mode string) {
+	jobLimitMu.Lock()
+	defer jobLimitMu.Unlock()
+	jobSlots = nil
+	if max > 0 {
+		jobSlots = make(chan struct{}, max)
+	}
+	jobLimitMode = mode
+}
+
+// QueueDepth returns the number of scheduled runs waiting for a free slot.
+func QueueDepth() int {
+	return int(jobsQueued.Load())
+}
+
+// RunningJobs returns the number of scheduled runs in progress.
+func RunningJobs() int {
+	return int(jobsRunning.Load())
+}
+
+// acquireJobSlot takes a slot for a run. It blocks while the limit is reached
+// in queue mode and returns false at once in skip mode. The caller must call
+// release when the run is done.
+func acquireJobSlot() (release func(), ok bool) {
+	jobLimitMu.RLock()
+	slots, mode := jobSlots, jobLimitMode
+	jobLimitMu.RUnlock()
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		default:
+			if mode == JobLimitSkip {
+				return nil, false
+			}
+			jobsQueued.Add(1)
+			slots <- struct{}{}
+			jobsQueued.Add(-1)
+		}
+	}
+	jobsRunning.Add(1)
+	return func() {
+		jobsRunning.Add(-1)
+		if slots != nil {
+			<-slots
+		}
+	}, true
+}
+
+// jitter returns the parsed start jitter of the task, or 0 when unset.
+func (t Task) jitter() time.Duration {
+	if t.Jitter == "" {
+		return 0
+	}
+	d, _ := time.ParseDuration(t.Jitter)
+	return d
+}
+
+// validateJitter checks the jitter field of a task.
+func validateJitter(s string) error {
+	if s == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(s); err != nil || d < 0 {
+		return fmt.Errorf("invalid jitter %q, want a duration such as 90s or 5m", s)
+	}
+	return nil
+}
+
+// randomDelay returns a random duration in [0, max).
+func randomDelay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
@@ -85,30 +85,7 @@ func StreamChatCompletion(ctx context.Context, client StreamCompleter, msgs []op
 		Messages: msgs,
 		Stream:   true,
 	}
-	if getRuntimeConfig().ServiceTier != "" {
-		req.ServiceTier = getRuntimeConfig().ServiceTier
-	}
-	if getRuntimeConfig().ReasoningEffort != "" {
-		req.ReasoningEffort = getRuntimeConfig().ReasoningEffort
-	}
-	if getRuntimeConfig().EnableWebSearch && supportsWebSearch(model) {
-		req.Tools = []openai.Tool{webSearchTool}
-	}
-	if getRuntimeConfig().ToolChoice != "" {
-		req.ToolChoice = getRuntimeConfig().ToolChoice
-		if getRuntimeConfig().ToolChoice == "none" {
-			req.Tools = nil
-		}
-	}
-	if strings.HasPrefix(model, "o3") || strings.HasPrefix(model, "o1") {
-		req.MaxCompletionTokens = getRuntimeConfig().MaxTokens
-	} else if strings.HasPrefix(model, "gpt-4o") {
-		req.Temperature = 0.9
-		req.MaxTokens = getRuntimeConfig().MaxTokens
-	} else {
-		req.Temperature = 0.9
-		req.MaxTokens = getRuntimeConfig().MaxTokens
-	}
+	runtimeParams().apply(&req, model)
 
 	stream, err := client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
@@ -175,6 +152,11 @@ func StreamChatCompletion(ctx context.Context, client StreamCompleter, msgs []op
 //   - string: The generated response text, trimmed of whitespace
 //   - error: Any error that occurred during the API call
 func ChatCompletion(ctx context.Context, client ChatCompleter, msgs []openai.ChatCompletionMessage, model string) (string, error) {
+	return chatCompletion(ctx, client, msgs, model, runtimeParams())
+}
+
+// chatCompletion is ChatCompletion with explicit generation parameters.
+func chatCompletion(ctx context.Context, client ChatCompleter, msgs []openai.ChatCompletionMessage, model string, params completionParams) (string, error) {
 	logger.L.Debug("chat completion", "model", model, "messages", len(msgs))
 	if len(msgs) == 0 {
 		return "", nil
@@ -196,37 +178,7 @@ func ChatCompletion(ctx context.Context, client ChatCompleter, msgs []openai.Cha
 		Model:    model,
 		Messages: msgs,
 	}
-	if getRuntimeConfig().ServiceTier != "" {
-		req.ServiceTier = getRuntimeConfig().ServiceTier
-	}
-	if getRuntimeConfig().ReasoningEffort != "" {
-		req.ReasoningEffort = getRuntimeConfig().ReasoningEffort
-	}
-	if getRuntimeConfig().EnableWebSearch && supportsWebSearch(model) {
-		req.Tools = []openai.Tool{webSearchTool}
-	}
-
-	if getRuntimeConfig().ToolChoice != "" {
-		req.ToolChoice = getRuntimeConfig().ToolChoice
-		if getRuntimeConfig().ToolChoice == "none" {
-			req.Tools = nil
-		}
-	}
-
-	// Configure parameters based on model type
-	if strings.HasPrefix(model, "o3") || strings.HasPrefix(model, "o1") {
-		// o3/o1 models have fixed parameters: temperature=1, top_p=1, n=1
-		// presence_penalty and frequency_penalty are fixed at 0
-		req.MaxCompletionTokens = getRuntimeConfig().MaxTokens
-	} else if strings.HasPrefix(model, "gpt-4o") {
-		// GPT-4o models support web search (handled automatically by OpenAI)
-		req.Temperature = 0.9
-		req.MaxTokens = getRuntimeConfig().MaxTokens
-	} else {
-		// Standard models support custom parameters
-		req.Temperature = 0.9
-		req.MaxTokens = getRuntimeConfig().MaxTokens
-	}
+	params.apply(&req, model)
 
 	resp, err := client.CreateChatCompletion(ctx, req)
 	if err != nil {
@@ -239,7 +191,7 @@ func ChatCompletion(ctx context.Context, client ChatCompleter, msgs []openai.Cha
 	}
 
 	msg := resp.Choices[0].Message
-	if getRuntimeConfig().EnableWebSearch && len(msg.ToolCalls) > 0 {
+	if params.WebSearch && len(msg.ToolCalls) > 0 {
 		toolMsgs := make([]openai.ChatCompletionMessage, 0, len(msg.ToolCalls))
 		for _, tc := range msg.ToolCalls {
 			if tc.Type != openai.ToolTypeFunction || tc.Function.Name != "web_search" {
@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// DefaultTemperature is the sampling temperature used when neither the task
+// nor the tasks file sets one.
+const DefaultTemperature float32 = 0.9
+
+// GenParams are the generation settings of a task. Unset fields inherit the
+// file-level defaults and then the runtime configuration.
+type GenParams struct {
+	MaxTokens   int      `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	// WebSearch enables the web_search tool for models that support it.
+	WebSearch       *bool  `json:"web_search,omitempty" yaml:"web_search,omitempty"`
+	ToolChoice      string `json:"tool_choice,omitempty" yaml:"tool_choice,omitempty"`
+	ReasoningEffort string `json:"reasoning_effort,omitempty" yaml:"reasoning_effort,omitempty"`
+	ServiceTier     string `json:"service_tier,omitempty" yaml:"service_tier,omitempty"`
+}
+
+// Validate checks the parameter values.
+func (p GenParams) Validate() error {
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative")
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	for _, f := range []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"tool_choice", p.ToolChoice, []string{"auto", "none", "required"}},
+		{"reasoning_effort", p.ReasoningEffort, []string{"minimal", "low", "medium", "high"}},
+		{"service_tier", p.ServiceTier, []string{"auto", "default", "flex", "priority"}},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if !slices.Contains(f.allowed, f.value) {
+			return fmt.Errorf("%s: unknown value %q, want one of %s", f.name, f.value, strings.Join(f.allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// inherit fills the unset fields of p from def.
+func (p GenParams) inherit(def GenParams) GenParams {
+	if p.MaxTokens == 0 {
+		p.MaxTokens = def.MaxTokens
+	}
+	if p.Temperature == nil {
+		p.Temperature = def.Temperature
+	}
+	if p.WebSearch == nil {
+		p.WebSearch = def.WebSearch
+	}
+	if p.ToolChoice == "" {
+		p.ToolChoice = def.ToolChoice
+	}
+	if p.ReasoningEffort == "" {
+		p.ReasoningEffort = def.ReasoningEffort
+	}
+	if p.ServiceTier == "" {
+		p.ServiceTier = def.ServiceTier
+	}
+	return p
+}
+
+// completionParams are the settings of one OpenAI request with every value
+// resolved.
+type completionParams struct {
+	MaxTokens       int
+	Temperature     float32
+	WebSearch       bool
+	ToolChoice      string
+	ReasoningEffort string
+	ServiceTier     openai.ServiceTier
+}
+
+// runtimeParams returns the request settings of the runtime configuration.
+func runtimeParams() completionParams {
+	cfg := getRuntimeConfig()
+	return completionParams{
+		MaxTokens:       cfg.MaxTokens,
+		Temperature:     DefaultTemperature,
+		WebSearch:       cfg.EnableWebSearch,
+		ToolChoice:      cfg.ToolChoice,
+		ReasoningEffort: cfg.ReasoningEffort,
+		ServiceTier:     cfg.ServiceTier,
+	}
+}
+
+// with overrides c with the fields set in p.
+func (c completionParams) with(p GenParams) completionParams {
+	if p.MaxTokens > 0 {
+		c.MaxTokens = p.MaxTokens
+	}
+	if p.Temperature != nil {
+		c.Temperature = *p.Temperature
+	}
+	if p.WebSearch != nil {
+		c.WebSearch = *p.WebSearch
+	}
+	if p.ToolChoice != "" {
+		c.ToolChoice = p.ToolChoice
+	}
+	if p.ReasoningEffort != "" {
+		c.ReasoningEffort = p.ReasoningEffort
+	}
+	if p.ServiceTier != "" {
+		c.ServiceTier = openai.ServiceTier(p.ServiceTier)
+	}
+	return c
+}
+
+// apply sets the generation fields of req for model.
+func (c completionParams) apply(req *openai.ChatCompletionRequest, model string) {
+	if c.ServiceTier != "" {
+		req.ServiceTier = c.ServiceTier
+	}
+	if c.ReasoningEffort != "" {
+		req.ReasoningEffort = c.ReasoningEffort
+	}
+	if c.WebSearch && supportsWebSearch(model) {
+		req.Tools = []openai.Tool{webSearchTool}
+	}
+	if c.ToolChoice != "" {
+		req.ToolChoice = c.ToolChoice
+		if c.ToolChoice == "none" {
+			req.Tools = nil
+		}
+	}
+
+	// Configure parameters based on model type
+	if strings.HasPrefix(model, "o3") || strings.HasPrefix(model, "o1") {
+		// o3/o1 models have fixed parameters: temperature=1, top_p=1, n=1
+		// presence_penalty and frequency_penalty are fixed at 0
+		req.MaxCompletionTokens = c.MaxTokens
+	} else {
+		req.Temperature = c.Temperature
+		req.MaxTokens = c.MaxTokens
+	}
+}
+
+// taskModel returns the model a task runs with.
+func taskModel(task Task) string {
+	if task.Model != "" {
+		return task.Model
+	}
+	return getRuntimeConfig().CurrentModel
+}
+
+// TaskCompletion sends the rendered prompt of a task to OpenAI using the
+// task's generation parameters on top of the runtime configuration.
+func TaskCompletion(ctx context.Context, client ChatCompleter, task Task, prompt, model string) (string, error) {
+	msgs := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: prompt}}
+	return chatCompletion(ctx, client, msgs, model, runtimeParams().with(task.GenParams))
+}
@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+)
+
+// outputPlaceholderRx matches {output:<task>} placeholders in prompts.
+var outputPlaceholderRx = regexp.MustCompile(`\{output:([^{}\s]+)\}`)
+
+var (
+	pipelineMu    sync.Mutex
+	lastTriggered = map[string]time.Time{}
+)
+
+// upstreams returns the tasks whose output the task uses: depends_on plus
+// every {output:<task>} placeholder, without duplicates.
+func (t Task) upstreams() []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range t.DependsOn {
+		add(name)
+	}
+	for _, m := range outputPlaceholderRx.FindAllStringSubmatch(t.Prompt, -1) {
+		add(m[1])
+	}
+	return names
+}
+
+// triggered reports whether the task has no schedule of its own and runs
+// after its upstreams instead.
+func (t Task) triggered() bool {
+	return t.Time == "" && t.Cron == "" && len(t.upstreams()) > 0
+}
+
+// validateDependencies reports upstreams that are not defined and dependency
+// cycles.
+func validateDependencies(tasks []Task) []taskIssue {
+	var issues []taskIssue
+	byName := map[string]Task{}
+	index := map[string]int{}
+	for i, t := range tasks {
+		if t.Name != "" {
+			byName[t.Name] = t
+			index[t.Name] = i
+		}
+	}
+	for i, t := range tasks {
+		for _, up := range t.upstreams() {
+			if _, ok := byName[up]; !ok {
+				issues = append(issues, taskIssue{i, "depends_on", fmt.Errorf("unknown upstream task %q", up)})
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == name {
+					start = i
+				}
+			}
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path[start:], " -> "), name)
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, up := range byName[name].upstreams() {
+			if _, ok := byName[up]; !ok {
+				continue
+			}
+			if err := visit(up); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, t := range tasks {
+		if t.Name == "" || state[t.Name] != 0 {
+			continue
+		}
+		if err := visit(t.Name); err != nil {
+			issues = append(issues, taskIssue{index[t.Name], "depends_on", err})
+			break
+		}
+	}
+	return issues
+}
+
+// lastOutputRun returns the latest successful run of the task in its default
+// slot. Failed and skipped runs and the runs of per-chat timezone slots do not
+// replace the output other tasks use.
+func lastOutputRun(name string) (TaskRunRecord, bool) {
+	historyMu.RLock()
+	defer historyMu.RUnlock()
+	for i := len(history) - 1; i >= 0; i-- {
+		r := history[i]
+		if r.Task == name && r.Status == RunOK && r.SlotZone == "" {
+			return r, true
+		}
+	}
+	return TaskRunRecord{}, false
+}
+
+// upstreamOutput returns the latest output of an upstream task. It fails when
+// the task has never succeeded.
+func upstreamOutput(name string) (string, error) {
+	last, ok := lastOutputRun(name)
+	if !ok {
+		return "", fmt.Errorf("upstream %s has no successful run yet", name)
+	}
+	return last.Output, nil
+}
+
+// resolveOutputs replaces {output:<task>} placeholders in prompt with the
+// outputs of the upstreams. A triggered task fails without them. A task with
+// its own schedule runs anyway and omits the sections of upstreams that have
+// no output, so one failed upstream does not cost the whole run.
+func resolveOutputs(task Task, prompt string) (string, error) {
+	outputs := map[string]string{}
+	for _, name := range task.upstreams() {
+		out, err := upstreamOutput(name)
+		if err != nil {
+			if task.triggered() {
+				return "", err
+			}
+			logger.L.Warn("upstream output missing, section omitted", "task", task.Name, "upstream", name, "err", err)
+			prompt = omitOutput(prompt, name)
+			continue
+		}
+		outputs[name] = out
+	}
+	return outputPlaceholderRx.ReplaceAllStringFunc(prompt, func(m string) string {
+		return outputs[outputPlaceholderRx.FindStringSubmatch(m)[1]]
+	}), nil
+}
+
+// omitOutput removes the output of the named task from prompt: a line holding
+// only a label and the placeholder is dropped, elsewhere just the placeholder.
+func omitOutput(prompt, name string) string {
+	placeholder := "{output:" + name + "}"
+	var out []string
+	for _, line := range strings.Split(prompt, "\n") {
+		if strings.Contains(line, placeholder) {
+			rest := strings.TrimSpace(strings.ReplaceAll(line, placeholder, ""))
+			if rest == "" || strings.HasSuffix(rest, ":") {
+				continue
+			}
+			line = strings.ReplaceAll(line, placeholder, "")
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// runDependents starts triggered tasks downstream of a successful run once
+// all their upstreams have produced output since the task last ran.
+func runDependents(run taskRun) {
+	if !run.slot.isDefault(run.task) || run.task.Name == "" {
+		return
+	}
+	TasksMu.RLock()
+	tasks := append([]Task(nil), LoadedTasks...)
+	TasksMu.RUnlock()
+
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	for _, task := range tasks {
+		if task.Paused || !task.triggered() || !dependsOn(task, run.task.Name) || !upstreamsReady(task) {
+			continue
+		}
+		lastTriggered[task.Name] = time.Now()
+		logger.L.Info("running dependent task", "task", task.Name, "upstream", run.task.Name)
+		go startTaskRun(taskRun{
+			task:      task,
+			slot:      defaultSlot(task),
+			client:    run.client,
+			bot:       run.bot,
+			chatID:    run.chatID,
+			scheduled: run.scheduled,
+			attempt:   1,
+			delayed:   run.delayed,
+		})
+	}
+}
+
+func dependsOn(task Task, name string) bool {
+	for _, up := range task.upstreams() {
+		if up == name {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamsReady reports whether every upstream of the task succeeded since
+// the task was last triggered or run.
+func upstreamsReady(task Task) bool {
+	since := lastTriggered[task.Name]
+	if last, ok := LastRun(task.Name); ok && last.Started.After(since) {
+		since = last.Started
+	}
+	for _, up := range task.upstreams() {
+		last, ok := lastOutputRun(up)
+		if !ok || !last.Finished.After(since) {
+			return false
+		}
+	}
+	return true
+}
+
+// ResetPipelines forgets which dependent tasks were triggered. Used in tests.
+func ResetPipelines() {
+	pipelineMu.Lock()
+	lastTriggered = map[string]time.Time{}
+	pipelineMu.Unlock()
+}
@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"telegram-reminder/internal/domain"
+	"telegram-reminder/internal/prompt"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// promptFragments returns placeholders that expand to whole prompts: the base
+// prompt and every digest prompt by its DigestConfig.Placeholder.
+func promptFragments() map[string]string {
+	fragments := map[string]string{"base_prompt": getRuntimeConfig().BasePrompt}
+	for _, cfg := range domain.GetDigestConfigs() {
+		fragments[cfg.Placeholder] = cfg.Prompt
+	}
+	return fragments
+}
+
+// templateVars returns the values of legacy placeholders read from the
+// environment.
+func templateVars() map[string]string {
+	return map[string]string{
+		"exchange_api": os.Getenv("EXCHANGE_API"),
+		"chart_path":   os.Getenv("CHART_PATH"),
+	}
+}
+
+// promptScope describes where a prompt is rendered: the timezone of the run,
+// empty for the scheduler's, and the chat when the run serves a single one.
+type promptScope struct {
+	zone string
+	chat prompt.Chat
+}
+
+// chatScope returns the scope of a run for one chat, in the chat's timezone
+// when it has one.
+func chatScope(chat *tb.Chat) promptScope {
+	scope := promptScope{chat: prompt.Chat{ID: chat.ID, Title: getChatTitle(chat), Type: getChatTypeString(chat.Type)}}
+	if info, ok, err := currentChatStore().GetChat(chat.ID); err == nil && ok {
+		scope.zone = info.Timezone
+	}
+	return scope
+}
+
+// chatIDScope returns the scope of a run for a chat known only by ID.
+func chatIDScope(id int64) promptScope {
+	if id == 0 {
+		return promptScope{}
+	}
+	scope := promptScope{chat: prompt.Chat{ID: id}}
+	if info, ok, err := currentChatStore().GetChat(id); err == nil && ok {
+		scope.chat.Title, scope.chat.Type, scope.zone = info.Title, info.Type, info.Timezone
+	}
+	return scope
+}
+
+// renderPrompt renders a prompt template for the given model, task and scope.
+func renderPrompt(text, model, task string, scope promptScope) (string, error) {
+	data := prompt.Data{
+		Model:    model,
+		Task:     task,
+		Chat:     scope.chat,
+		Vars:     templateVars(),
+		Partials: promptFragments(),
+	}
+	loc, err := scope.location()
+	if err != nil {
+		return "", err
+	}
+	data.Location = loc
+	return prompt.Render(text, data)
+}
+
+// location returns the timezone of the scope, nil for the default one.
+func (s promptScope) location() (*time.Location, error) {
+	if s.zone == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(s.zone)
+	if err != nil {
+		return nil, fmt.Errorf("timezone %q: %w", s.zone, err)
+	}
+	return loc, nil
+}
+
+// taskPrompt builds the prompt sent for a task: the digest prompt when the
+// task names one, followed by its own prompt, with placeholders and upstream
+// outputs filled in.
+func taskPrompt(task Task, model string, scope promptScope) (string, error) {
+	text := task.Prompt
+	if task.Digest != "" {
+		cfg := domain.GetDigestConfigs()[domain.DigestType(task.Digest)]
+		text = strings.TrimSpace(cfg.Prompt + "\n\n" + task.Prompt)
+	}
+	rendered, err := renderPrompt(text, model, task.Name, scope)
+	if err != nil {
+		return "", fmt.Errorf("render prompt: %w", err)
+	}
+	return resolveOutputs(task, rendered)
+}
+
+// validatePrompt reports an unknown digest type, placeholders that cannot be
+// filled and template errors in the task at index.
+func validatePrompt(task Task, index int) []taskIssue {
+	var issues []taskIssue
+	if task.Digest != "" {
+		if _, ok := domain.GetDigestConfigs()[domain.DigestType(task.Digest)]; !ok {
+			issues = append(issues, taskIssue{index, "digest", fmt.Errorf("unknown digest %q, want one of %s", task.Digest, strings.Join(digestTypeNames(), ", "))})
+		}
+	}
+	known := templateVars()
+	for name := range promptFragments() {
+		known[name] = ""
+	}
+	for _, name := range prompt.Builtins {
+		known[name] = ""
+	}
+	for _, name := range prompt.Placeholders(task.Prompt) {
+		if _, ok := known[name]; !ok {
+			issues = append(issues, taskIssue{index, "prompt", fmt.Errorf("unknown placeholder {%s}", name)})
+		}
+	}
+	if len(issues) == 0 {
+		if _, err := renderPrompt(task.Prompt, "", task.Name, promptScope{}); err != nil {
+			issues = append(issues, taskIssue{index, "prompt", err})
+		}
+	}
+	return issues
+}
+
+// digestTypeNames returns the sorted digest types usable in digest:.
+func digestTypeNames() []string {
+	var names []string
+	for digestType := range domain.GetDigestConfigs() {
+		names = append(names, string(digestType))
+	}
+	sort.Strings(names)
+	return names
+}
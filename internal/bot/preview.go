@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"time"
+
+	"telegram-reminder/internal/config"
+	"telegram-reminder/internal/domain"
+	"telegram-reminder/internal/logger"
+	"telegram-reminder/internal/prompt"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// Preview is the result of a dry run of a task.
+type Preview struct {
+	Task   string
+	Model  string
+	Prompt string
+	Output string
+}
+
+// LoadForPreview prepares a process that only previews tasks, such as the
+// run-task command. It reads the same environment as the bot: tasks with the
+// Telegram overlay, the run history for {output:<task>}, the timezone and the
+// prompt env allowlist. No tokens are needed.
+func LoadForPreview() error {
+	if err := LoadTaskOverlay(envDefault(config.EnvTasksOverlayFile, config.DefaultTasksOverlay)); err != nil {
+		return err
+	}
+	if err := LoadHistory(envDefault(config.EnvHistoryFile, config.DefaultHistoryFile), 0); err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(envDefault(config.EnvTimezone, config.DefaultTimezone))
+	if err != nil {
+		return fmt.Errorf("invalid TIMEZONE: %w", err)
+	}
+	prompt.SetDefaultLocation(loc)
+	prompt.SetEnvAllowlist(strings.Split(envDefault(config.EnvPromptEnvAllowlist, config.DefaultPromptEnv), ","))
+	if model := os.Getenv(config.EnvOpenAIModel); model != "" {
+		updateRuntimeConfig(func(cfg *RuntimeConfig) { cfg.CurrentModel = model })
+	}
+
+	tasks, err := LoadTasks()
+	if err != nil {
+		return err
+	}
+	TasksMu.Lock()
+	LoadedTasks = tasks
+	TasksMu.Unlock()
+	return nil
+}
+
+// findPreviewTask returns the loaded task with the given name or, failing
+// that, a task for the digest type of that name.
+func findPreviewTask(name string) (Task, bool) {
+	TasksMu.RLock()
+	task, ok := FindTask(LoadedTasks, name)
+	TasksMu.RUnlock()
+	if ok {
+		return task, true
+	}
+	if _, ok := domain.GetDigestConfigs()[domain.DigestType(name)]; ok {
+		return Task{Name: name, Digest: name}, true
+	}
+	return Task{}, false
+}
+
+// PreviewTask renders the prompt of a task or digest the way a scheduled run
+// would and, unless client is nil, calls the model. The result is only
+// returned: nothing is delivered to chats or recorded in the history.
+func PreviewTask(ctx context.Context, client ChatCompleter, name string) (Preview, error) {
+	task, ok := findPreviewTask(name)
+	if !ok {
+		return Preview{}, fmt.Errorf("unknown task %q", name)
+	}
+	model := taskModel(task)
+	var chatID int64
+	if r := currentTaskRunner(); r != nil {
+		chatID = r.chatID
+	}
+	text, err := taskPrompt(task, model, chatIDScope(chatID))
+	if err != nil {
+		return Preview{}, err
+	}
+	p := Preview{Task: name, Model: model, Prompt: text}
+	if client == nil {
+		return p, nil
+	}
+	if p.Output, err = TaskCompletion(ctx, client, task, text, model); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// handlePreview runs a task or digest and replies with the result in the
+// admin's chat only. "/preview <task> prompt" shows the rendered prompt
+// without calling the model.
+func handlePreview(client ChatCompleter) func(tb.Context) error {
+	return func(c tb.Context) error {
+		logger.L.Debug("command preview", "chat", c.Chat().ID, "payload", c.Message().Payload)
+		payload := sanitizeInput(c.Message().Payload)
+		parts := strings.Fields(payload)
+		if err := validatePayload(payload); err != nil || len(parts) == 0 || len(parts) > 2 || (len(parts) == 2 && parts[1] != "prompt") {
+			return c.Send("Usage: /preview <задача> [prompt]")
+		}
+
+		var cl ChatCompleter
+		if len(parts) == 1 {
+			cl = client
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), OpenAITimeout)
+		defer cancel()
+		p, err := PreviewTask(ctx, cl, parts[0])
+		if err != nil {
+			if p.Model != "" {
+				return c.Send(DefaultErrorHandler.HandleOpenAIError(err, p.Model))
+			}
+			return c.Send("❌ " + err.Error())
+		}
+		if cl == nil {
+			return replyLong(c, fmt.Sprintf("📝 Промпт %s (%s):\n\n%s", p.Task, p.Model, html.EscapeString(p.Prompt)))
+		}
+		return replyLong(c, fmt.Sprintf("👁 Предпросмотр %s (%s), в чаты не отправлялось:\n\n%s", p.Task, p.Model, p.Output))
+	}
+}
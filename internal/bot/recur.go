@@ -0,0 +1,459 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ErrNotRecurring is returned by ParseRecurrence when the text does not start
+// with a repeat phrase; it is then parsed as a one-off time.
+var ErrNotRecurring = errors.New("not a recurring schedule")
+
+var errBadRecurrence = errors.New("не понял повтор: например «каждый день в 9:00», «по будням в 8:30», «every Monday at 9» или «1st of every month»")
+
+// Recurrence is a repeating reminder schedule.
+type Recurrence struct {
+	Cron string // standard five-field cron expression
+	Desc string // the interpretation shown to the user, in Russian
+}
+
+// AmbiguousRecurrence is returned when a repeat phrase has several plausible
+// readings, e.g. "в 7" (07:00 or 19:00) or "every month" without a day.
+// Options lists them for the user to pick from.
+type AmbiguousRecurrence struct {
+	Options []Recurrence
+}
+
+func (e *AmbiguousRecurrence) Error() string {
+	return "уточните расписание"
+}
+
+// Bare hours up to ambiguousHourMax without am/pm, утра or вечера may mean
+// either half of the day.
+const ambiguousHourMax = 7
+
+// defaultReminderHours are offered when a daily, weekly or monthly phrase has
+// no time.
+var defaultReminderHours = []int{9, 13, 18}
+
+// recurWeekdays maps English and Russian day names, in the forms used after
+// "every", "каждый" and "по", to cron weekday numbers.
+var recurWeekdays = map[string]int{
+	"sunday": 0, "sundays": 0, "sun": 0,
+	"monday": 1, "mondays": 1, "mon": 1,
+	"tuesday": 2, "tuesdays": 2, "tue": 2, "tues": 2,
+	"wednesday": 3, "wednesdays": 3, "wed": 3,
+	"thursday": 4, "thursdays": 4, "thu": 4, "thurs": 4,
+	"friday": 5, "fridays": 5, "fri": 5,
+	"saturday": 6, "saturdays": 6, "sat": 6,
+
+	"воскресенье": 0, "воскресеньям": 0, "вс": 0,
+	"понедельник": 1, "понедельникам": 1, "пн": 1,
+	"вторник": 2, "вторникам": 2, "вт": 2,
+	"среда": 3, "среду": 3, "средам": 3, "ср": 3,
+	"четверг": 4, "четвергам": 4, "чт": 4,
+	"пятница": 5, "пятницу": 5, "пятницам": 5, "пт": 5,
+	"суббота": 6, "субботу": 6, "субботам": 6, "сб": 6,
+}
+
+// weekdaysDative names weekdays as in "по понедельникам".
+var weekdaysDative = [...]string{"воскресеньям", "понедельникам", "вторникам", "средам", "четвергам", "пятницам", "субботам"}
+
+var (
+	shortWeekdays = [...]string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"}
+	shortMonths   = [...]string{"янв", "фев", "мар", "апр", "мая", "июн", "июл", "авг", "сен", "окт", "ноя", "дек"}
+)
+
+// recurSpec is a parsed repeat phrase before ambiguities are resolved.
+type recurSpec struct {
+	hourly  int   // repeat every N hours; the other fields are unused
+	dow     []int // weekdays, empty for every day
+	monthly bool  // repeat on a day of the month
+	doms    []int // candidate days of the month
+	hours   []int // candidate hours
+	minute  int
+}
+
+// recurParser walks the fields of a /remind payload.
+type recurParser struct {
+	fields []string
+	pos    int
+}
+
+// peek returns the lower-cased field at offset n from the current position
+// without surrounding punctuation, or "" past the end.
+func (p *recurParser) peek(n int) string {
+	if p.pos+n >= len(p.fields) {
+		return ""
+	}
+	return strings.Trim(strings.ToLower(p.fields[p.pos+n]), ",.;")
+}
+
+// ParseRecurrence splits a repeat phrase at the start of a /remind payload
+// from the reminder text and converts it to a cron expression. It
+// understands, in English and Russian, "every day at 9", "каждый будний день
+// в 8:30", "по выходным в 10", "every Monday and Thursday at 19:00", "1st of
+// every month", "каждое 15 число в 12:00" and "каждые 2 часа". A payload that
+// does not start with a repeat phrase yields ErrNotRecurring; several readings
+// yield *AmbiguousRecurrence. now supplies the day offered for "every month".
+func ParseRecurrence(s string, now time.Time) (Recurrence, string, error) {
+	p := &recurParser{fields: strings.Fields(s)}
+	spec, err := p.period(now)
+	if err != nil {
+		return Recurrence{}, "", err
+	}
+	if spec.hourly == 0 {
+		if err := p.clock(&spec); err != nil {
+			return Recurrence{}, "", err
+		}
+	}
+	text := strings.Join(p.fields[p.pos:], " ")
+
+	opts, err := spec.options()
+	if err != nil {
+		return Recurrence{}, "", err
+	}
+	if len(opts) > 1 {
+		return Recurrence{}, text, &AmbiguousRecurrence{Options: opts}
+	}
+	return opts[0], text, nil
+}
+
+// period parses the repeat phrase up to the time of day.
+func (p *recurParser) period(now time.Time) (recurSpec, error) {
+	var spec recurSpec
+	w := p.peek(0)
+	switch w {
+	case "daily", "ежедневно":
+		p.pos++
+		return spec, nil
+	case "hourly", "ежечасно":
+		p.pos++
+		spec.hourly = 1
+		return spec, nil
+	case "monthly", "ежемесячно":
+		p.pos++
+		spec.monthly = true
+		p.monthDay(&spec, now)
+		return spec, nil
+	case "по":
+		switch next := p.peek(1); {
+		case next == "будням":
+			spec.dow = []int{1, 2, 3, 4, 5}
+			p.pos += 2
+		case next == "выходным":
+			spec.dow = []int{0, 6}
+			p.pos += 2
+		default:
+			p.pos++
+			if !p.weekdays(&spec) {
+				return spec, ErrNotRecurring
+			}
+		}
+		return spec, nil
+	case "every", "each", "каждый", "каждую", "каждое", "каждые", "каждого":
+		p.pos++
+	default:
+		if n, ok := p.ordinal(0); ok && p.monthSuffix(1) {
+			spec.monthly, spec.doms = true, []int{n}
+			return spec, nil
+		}
+		return spec, ErrNotRecurring
+	}
+
+	switch w := p.peek(0); w {
+	case "day", "день":
+		p.pos++
+	case "weekday", "weekdays", "workday", "workdays":
+		p.pos++
+		spec.dow = []int{1, 2, 3, 4, 5}
+	case "будний", "рабочий":
+		if p.peek(1) != "день" {
+			return spec, errBadRecurrence
+		}
+		p.pos += 2
+		spec.dow = []int{1, 2, 3, 4, 5}
+	case "weekend", "выходные":
+		p.pos++
+		spec.dow = []int{0, 6}
+	case "hour", "час":
+		p.pos++
+		spec.hourly = 1
+	case "month", "месяц":
+		p.pos++
+		spec.monthly = true
+		p.monthDay(&spec, now)
+	default:
+		if p.weekdays(&spec) {
+			return spec, nil
+		}
+		if n, err := strconv.Atoi(w); err == nil && isHourUnit(p.peek(1)) {
+			if n < 1 || n > 23 {
+				return spec, fmt.Errorf("повтор каждые %d ч не поддерживается, укажите от 1 до 23 часов", n)
+			}
+			p.pos += 2
+			spec.hourly = n
+			return spec, nil
+		}
+		if _, unit := timeUnits[p.peek(1)]; unit {
+			return spec, errBadRecurrence
+		}
+		if n, ok := p.ordinal(0); ok {
+			// "каждое 15 число", "every 15th"
+			p.pos++
+			if w := p.peek(0); w == "число" || w == "числа" {
+				p.pos++
+			}
+			spec.monthly, spec.doms = true, []int{n}
+			return spec, nil
+		}
+		return spec, errBadRecurrence
+	}
+	return spec, nil
+}
+
+// weekdays parses a list such as "monday", "mon,wed" or "понедельникам и
+// четвергам" into spec.dow.
+func (p *recurParser) weekdays(spec *recurSpec) bool {
+	start := p.pos
+	for {
+		w := p.peek(0)
+		ok := w != ""
+		var days []int
+		for _, part := range strings.Split(w, ",") {
+			d, found := recurWeekdays[part]
+			if !found {
+				ok = false
+				break
+			}
+			days = append(days, d)
+		}
+		if !ok {
+			break
+		}
+		spec.dow = append(spec.dow, days...)
+		p.pos++
+		if sep := p.peek(0); sep == "and" || sep == "и" {
+			if _, found := recurWeekdays[p.peek(1)]; found {
+				p.pos++
+			}
+		}
+	}
+	return p.pos > start
+}
+
+// monthDay parses the optional day after "every month": "on the 15th",
+// "15th", "15 числа" or "15-го". Without one, the first of the month and the
+// current day are offered.
+func (p *recurParser) monthDay(spec *recurSpec, now time.Time) {
+	off := 0
+	if w := p.peek(0); w == "on" {
+		off++
+		if p.peek(off) == "the" {
+			off++
+		}
+	}
+	if n, ok := p.ordinal(off); ok {
+		p.pos += off + 1
+		if w := p.peek(0); w == "числа" || w == "число" {
+			p.pos++
+		}
+		spec.doms = []int{n}
+		return
+	}
+	spec.doms = []int{1}
+	if now.Day() != 1 {
+		spec.doms = append(spec.doms, now.Day())
+	}
+}
+
+// monthSuffix reports whether the fields at offset n say "of every month" or
+// "числа каждого месяца" and consumes them together with the ordinal before.
+func (p *recurParser) monthSuffix(n int) bool {
+	var words []string
+	switch p.peek(n) {
+	case "of":
+		words = []string{"of", "every", "month"}
+		if p.peek(n+1) == "each" || p.peek(n+1) == "the" {
+			words[1] = p.peek(n + 1)
+		}
+	case "числа":
+		words = []string{"числа", "каждого", "месяца"}
+	default:
+		return false
+	}
+	for i, w := range words {
+		if p.peek(n+i) != w {
+			return false
+		}
+	}
+	p.pos += n + len(words)
+	return true
+}
+
+// ordinal parses a day of the month such as "1", "1st", "15th", "1-го" or
+// "15-е" at offset n.
+func (p *recurParser) ordinal(n int) (int, bool) {
+	w := p.peek(n)
+	for _, suffix := range []string{"st", "nd", "rd", "th", "-го", "-е", "-ое"} {
+		if strings.HasSuffix(w, suffix) {
+			w = strings.TrimSuffix(w, suffix)
+			break
+		}
+	}
+	d, err := strconv.Atoi(w)
+	if err != nil || d < 1 || d > 31 {
+		return 0, false
+	}
+	return d, true
+}
+
+// isHourUnit reports whether w names hours, as in "every 2 hours".
+func isHourUnit(w string) bool {
+	return timeUnits[w] == time.Hour
+}
+
+// clock parses the optional time of day: "at 9", "at 9:30pm", "в 8:30",
+// "в 7 вечера" or a bare "21:00".
+func (p *recurParser) clock(spec *recurSpec) error {
+	off := 0
+	if w := p.peek(0); w == "at" || w == "в" || w == "@" {
+		off = 1
+	}
+	w := p.peek(off)
+	suffix := ""
+	for _, s := range []string{"am", "pm"} {
+		if strings.HasSuffix(w, s) && len(w) > len(s) {
+			w, suffix = strings.TrimSuffix(w, s), s
+			break
+		}
+	}
+	hour, minute, colon := 0, 0, false
+	var err error
+	if h, m, ok := strings.Cut(w, ":"); ok {
+		colon = true
+		if hour, err = strconv.Atoi(h); err == nil && len(m) == 2 {
+			minute, err = strconv.Atoi(m)
+		} else if err == nil {
+			err = errBadRecurrence
+		}
+	} else {
+		hour, err = strconv.Atoi(w)
+	}
+	if err != nil || (off == 0 && !colon) {
+		// No time: offer a few for daily, weekly and monthly reminders.
+		spec.hours = defaultReminderHours
+		return nil
+	}
+	p.pos += off + 1
+	if suffix == "" {
+		switch s := p.peek(0); s {
+		case "am", "pm", "утра", "дня", "вечера", "ночи":
+			suffix = s
+			p.pos++
+		}
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 || (suffix != "" && (hour < 1 || hour > 12)) {
+		return fmt.Errorf("неверное время %s", w)
+	}
+	spec.minute = minute
+	switch suffix {
+	case "am":
+		spec.hours = []int{hour % 12}
+	case "ночи":
+		// "2 ночи" is 02:00, "11 ночи" is 23:00.
+		if hour >= 9 {
+			hour = (hour + 12) % 24
+		}
+		spec.hours = []int{hour}
+	case "утра":
+		spec.hours = []int{hour}
+	case "pm", "вечера":
+		spec.hours = []int{hour%12 + 12}
+	case "дня":
+		if hour < 12 {
+			hour += 12
+		}
+		spec.hours = []int{hour}
+	default:
+		spec.hours = []int{hour}
+		if !colon && hour >= 1 && hour <= ambiguousHourMax {
+			spec.hours = append(spec.hours, hour+12)
+		}
+	}
+	return nil
+}
+
+// options lists every reading of the spec as a validated cron expression.
+func (spec recurSpec) options() ([]Recurrence, error) {
+	if spec.hourly > 0 {
+		expr, desc := "0 * * * *", "каждый час"
+		if spec.hourly > 1 {
+			expr, desc = fmt.Sprintf("0 */%d * * *", spec.hourly), fmt.Sprintf("каждые %d ч", spec.hourly)
+		}
+		return []Recurrence{{Cron: expr, Desc: desc}}, nil
+	}
+
+	dow, days := "*", "каждый день"
+	if len(spec.dow) > 0 {
+		set := slices.Clone(spec.dow)
+		slices.Sort(set)
+		set = slices.Compact(set)
+		names := make([]string, len(set))
+		nums := make([]string, len(set))
+		for i, d := range set {
+			names[i], nums[i] = weekdaysDative[d], strconv.Itoa(d)
+		}
+		dow, days = strings.Join(nums, ","), "по "+strings.Join(names, ", ")
+		switch dow {
+		case "1,2,3,4,5":
+			dow, days = "1-5", "по будням"
+		case "0,6":
+			days = "по выходным"
+		}
+	}
+	doms := []int{0}
+	if spec.monthly {
+		doms = spec.doms
+	}
+
+	var out []Recurrence
+	for _, dom := range doms {
+		for _, hour := range spec.hours {
+			r := Recurrence{
+				Cron: fmt.Sprintf("%d %d * * %s", spec.minute, hour, dow),
+				Desc: fmt.Sprintf("%s в %02d:%02d", days, hour, spec.minute),
+			}
+			if spec.monthly {
+				r.Cron = fmt.Sprintf("%d %d %d * *", spec.minute, hour, dom)
+				r.Desc = fmt.Sprintf("%d-го числа каждого месяца в %02d:%02d", dom, hour, spec.minute)
+			}
+			if _, err := cron.ParseStandard(r.Cron); err != nil {
+				return nil, fmt.Errorf("invalid schedule %q: %w", r.Cron, err)
+			}
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// NextRecurrence returns the first time after from at which the cron
+// expression fires in loc.
+func NextRecurrence(expr string, loc *time.Location, from time.Time) (time.Time, error) {
+	sched, err := cron.ParseStandard(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sched.Next(from.In(loc)), nil
+}
+
+// formatNext renders a fire time as "пн 21 окт 09:00 MSK".
+func formatNext(t time.Time) string {
+	return fmt.Sprintf("%s %d %s %s", shortWeekdays[t.Weekday()], t.Day(), shortMonths[t.Month()-1], t.Format("15:04 MST"))
+}
@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	"github.com/go-co-op/gocron"
+	tb "gopkg.in/telebot.v3"
+)
+
+// TaskReload summarises which tasks a reload added, removed or changed.
+type TaskReload struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether the reload found no differences.
+func (r TaskReload) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+func (r TaskReload) String() string {
+	if r.Empty() {
+		return "без изменений"
+	}
+	var parts []string
+	for _, p := range []struct {
+		title string
+		names []string
+	}{{"добавлены", r.Added}, {"изменены", r.Changed}, {"удалены", r.Removed}} {
+		if len(p.names) == 0 {
+			continue
+		}
+		labels := make([]string, len(p.names))
+		for i, name := range p.names {
+			labels[i] = name
+			if name == "" {
+				labels[i] = "(без имени)"
+			}
+		}
+		parts = append(parts, p.title+": "+strings.Join(labels, ", "))
+	}
+	return strings.Join(parts, "\n")
+}
+
+var reloadMu sync.Mutex
+
+// diffTasks compares task lists grouped by name.
+func diffTasks(old, updated []Task) TaskReload {
+	var d TaskReload
+	oldNames, oldGroups := groupTasksByName(old)
+	newNames, newGroups := groupTasksByName(updated)
+	for _, name := range newNames {
+		prev, ok := oldGroups[name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, name)
+		case !reflect.DeepEqual(prev, newGroups[name]):
+			d.Changed = append(d.Changed, name)
+		}
+	}
+	for _, name := range oldNames {
+		if _, ok := newGroups[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+	return d
+}
+
+// ReloadTasks re-reads and validates the task configuration, swaps
+// LoadedTasks and replaces only the jobs of tasks that changed. On any error
+// the current tasks and schedule stay in place.
+func ReloadTasks() (TaskReload, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	set, err := loadTaskSet()
+	if err != nil {
+		return TaskReload{}, err
+	}
+	if err := ValidateTasks(set.Tasks); err != nil {
+		return TaskReload{}, err
+	}
+
+	TasksMu.Lock()
+	old := LoadedTasks
+	LoadedTasks = set.Tasks
+	TasksMu.Unlock()
+
+	applyTaskSetConfig(set)
+
+	diff := diffTasks(old, set.Tasks)
+	if r := currentTaskRunner(); r != nil {
+		_, groups := groupTasksByName(set.Tasks)
+		for _, names := range [][]string{diff.Added, diff.Changed, diff.Removed} {
+			for _, name := range names {
+				if err := r.replaceTaskJobs(name, groups[name]); err != nil {
+					logger.L.Error("reschedule task", "task", name, "err", err)
+				}
+			}
+		}
+	}
+	logger.L.Info("tasks reloaded", "tasks", len(set.Tasks), "added", len(diff.Added), "changed", len(diff.Changed), "removed", len(diff.Removed))
+	return diff, nil
+}
+
+// fileStamp identifies a version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileStamp, bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, true
+}
+
+// WatchTasksFile polls the tasks file every interval and reloads it when it
+// changes. Reload errors are logged and reported to admins; the previous
+// schedule keeps running.
+func WatchTasksFile(s *gocron.Scheduler, b *tb.Bot, interval time.Duration) error {
+	path := tasksFilePath()
+	if path == "" || interval <= 0 {
+		return nil
+	}
+	last, _ := statFile(path)
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	_, err := s.Every(interval).Tag("tasks-watch").Do(func() {
+		stamp, ok := statFile(path)
+		if !ok || (stamp.modTime.Equal(last.modTime) && stamp.size == last.size) {
+			return
+		}
+		last = stamp
+		diff, err := ReloadTasks()
+		if err != nil {
+			logger.L.Error("reload tasks", "file", path, "err", err)
+			if b != nil {
+				notifyAdmins(b, fmt.Sprintf("❌ Ошибка в %s, продолжаю со старым расписанием:\n%v", path, err))
+			}
+			return
+		}
+		if !diff.Empty() && b != nil {
+			notifyAdmins(b, fmt.Sprintf("🔄 %s перечитан\n%s", path, diff))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	logger.L.Info("watching tasks file", "file", path, "interval", interval)
+	return nil
+}
+
+func handleReload(c tb.Context) error {
+	logger.L.Debug("command reload", "chat", c.Chat().ID)
+	diff, err := ReloadTasks()
+	if err != nil {
+		logger.L.Error("reload tasks", "err", err)
+		return c.Send(fmt.Sprintf("❌ Задачи не перезагружены, работает старое расписание:\n%v", err))
+	}
+	return c.Send("🔄 Задачи перезагружены\n" + diff.String())
+}
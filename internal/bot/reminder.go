@@ -0,0 +1,562 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	"github.com/go-co-op/gocron"
+	tb "gopkg.in/telebot.v3"
+)
+
+// MaxRemindersPerChat limits pending reminders in one chat.
+const MaxRemindersPerChat = 50
+
+// Reminder is a message a user asked the bot to send to a chat, once or on a
+// repeating schedule.
+type Reminder struct {
+	ID      int64     `json:"id"`
+	ChatID  int64     `json:"chat_id"`
+	UserID  int64     `json:"user_id,omitempty"`
+	Text    string    `json:"text"`
+	At      time.Time `json:"at"` // due time; the next run for recurring reminders
+	Created time.Time `json:"created"`
+
+	Cron     string `json:"cron,omitempty"`     // repeat schedule, empty for one-offs
+	Zone     string `json:"zone,omitempty"`     // timezone of Cron; empty uses the scheduler's
+	Schedule string `json:"schedule,omitempty"` // human-readable Cron
+}
+
+// Recurring reports whether the reminder repeats.
+func (r Reminder) Recurring() bool {
+	return r.Cron != ""
+}
+
+// location returns the timezone of the reminder's schedule.
+func (r Reminder) location() *time.Location {
+	if r.Zone != "" {
+		if loc, err := time.LoadLocation(r.Zone); err == nil {
+			return loc
+		}
+	}
+	return chatLocation(r.ChatID)
+}
+
+// next returns the first run of a recurring reminder after from.
+func (r Reminder) next(from time.Time) (time.Time, error) {
+	return NextRecurrence(r.Cron, r.location(), from)
+}
+
+// reminderFile is the on-disk layout of the reminder store.
+type reminderFile struct {
+	NextID    int64      `json:"next_id"`
+	Reminders []Reminder `json:"reminders"`
+	// Sent holds delivered reminders whose buttons still work.
+	NextSentID int64          `json:"next_sent_id,omitempty"`
+	Sent       []SentReminder `json:"sent,omitempty"`
+}
+
+var (
+	reminderMu    sync.Mutex
+	reminders     = map[int64]Reminder{}
+	nextReminder  = int64(1)
+	remindersPath string // empty keeps reminders in memory only
+	// reminderSched and reminderBot are set by StartReminders; until then
+	// reminders are only stored.
+	reminderSched *gocron.Scheduler
+	reminderBot   *tb.Bot
+
+	sentReminders = map[int64]SentReminder{}
+	nextSent      = int64(1)
+)
+
+// LoadReminders reads pending reminders from path and persists later changes
+// there. A missing file yields an empty store.
+func LoadReminders(path string) error {
+	var rf reminderFile
+	if err := loadJSONFile(path, &rf); err != nil {
+		return fmt.Errorf("load reminders %s: %w", path, err)
+	}
+	reminderMu.Lock()
+	defer reminderMu.Unlock()
+	reminders = map[int64]Reminder{}
+	nextReminder = rf.NextID
+	for _, r := range rf.Reminders {
+		reminders[r.ID] = r
+		if r.ID >= nextReminder {
+			nextReminder = r.ID + 1
+		}
+	}
+	if nextReminder < 1 {
+		nextReminder = 1
+	}
+	sentReminders = map[int64]SentReminder{}
+	nextSent = max(rf.NextSentID, 1)
+	for _, sr := range rf.Sent {
+		sentReminders[sr.ID] = sr
+		nextSent = max(nextSent, sr.ID+1)
+	}
+	remindersPath = path
+	return nil
+}
+
+// ResetReminders forgets all reminders and detaches the scheduler. Used in
+// tests.
+func ResetReminders() {
+	reminderMu.Lock()
+	reminders = map[int64]Reminder{}
+	nextReminder = 1
+	sentReminders = map[int64]SentReminder{}
+	nextSent = 1
+	remindersPath = ""
+	reminderSched = nil
+	reminderBot = nil
+	reminderMu.Unlock()
+}
+
+// saveRemindersLocked writes the store to disk. reminderMu must be held.
+func saveRemindersLocked() error {
+	if remindersPath == "" {
+		return nil
+	}
+	rf := reminderFile{NextID: nextReminder, Reminders: make([]Reminder, 0, len(reminders))}
+	for _, r := range reminders {
+		rf.Reminders = append(rf.Reminders, r)
+	}
+	sortReminders(rf.Reminders)
+	rf.NextSentID = nextSent
+	for _, sr := range sentReminders {
+		rf.Sent = append(rf.Sent, sr)
+	}
+	sort.Slice(rf.Sent, func(i, j int) bool { return rf.Sent[i].ID < rf.Sent[j].ID })
+	return saveJSONFile(remindersPath, rf)
+}
+
+// sortReminders orders reminders by due time, then by ID.
+func sortReminders(rs []Reminder) {
+	sort.Slice(rs, func(i, j int) bool {
+		if !rs[i].At.Equal(rs[j].At) {
+			return rs[i].At.Before(rs[j].At)
+		}
+		return rs[i].ID < rs[j].ID
+	})
+}
+
+// reminderTag is the scheduler tag of a reminder's job.
+func reminderTag(id int64) string {
+	return "reminder:" + strconv.FormatInt(id, 10)
+}
+
+// StartReminders schedules every stored reminder on s and sends them with b.
+// Reminders that fell due while the bot was down are sent at once.
+func StartReminders(s *gocron.Scheduler, b *tb.Bot) {
+	reminderMu.Lock()
+	reminderSched, reminderBot = s, b
+	pending := make([]Reminder, 0, len(reminders))
+	for id, r := range reminders {
+		// Runs of recurring reminders missed while the bot was down are
+		// skipped.
+		if r.Recurring() && !r.At.After(time.Now()) {
+			if next, err := r.next(time.Now()); err == nil {
+				r.At = next
+				reminders[id] = r
+			}
+		}
+		pending = append(pending, r)
+	}
+	reminderMu.Unlock()
+
+	sortReminders(pending)
+	for _, r := range pending {
+		if err := scheduleReminder(s, r); err != nil {
+			logger.L.Error("schedule reminder", "id", r.ID, "err", err)
+		}
+	}
+	if len(pending) > 0 {
+		logger.L.Info("reminders scheduled", "count", len(pending))
+	}
+}
+
+// scheduleReminder adds the job for r: a cron job for recurring reminders and
+// a one-off job otherwise.
+func scheduleReminder(s *gocron.Scheduler, r Reminder) error {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	if r.Recurring() {
+		expr := r.Cron
+		if r.Zone != "" {
+			expr = fmt.Sprintf("CRON_TZ=%s %s", r.Zone, r.Cron)
+		}
+		_, err := s.Cron(expr).Tag(reminderTag(r.ID)).Do(fireReminder, r.ID)
+		return err
+	}
+	job := s.Every(1).Day().LimitRunsTo(1).Tag(reminderTag(r.ID))
+	if r.At.After(time.Now()) {
+		job = job.StartAt(r.At)
+	} else {
+		job = job.StartImmediately()
+	}
+	_, err := job.Do(fireReminder, r.ID)
+	return err
+}
+
+// AddReminder stores a reminder for a chat and schedules it when the
+// scheduler is running.
+func AddReminder(chatID, userID int64, text string, at time.Time) (Reminder, error) {
+	return addReminder(Reminder{ChatID: chatID, UserID: userID, Text: text, At: at})
+}
+
+// AddRecurringReminder stores a reminder that repeats on rec in loc.
+func AddRecurringReminder(chatID, userID int64, text string, rec Recurrence, loc *time.Location) (Reminder, error) {
+	r := Reminder{ChatID: chatID, UserID: userID, Text: text, Cron: rec.Cron, Schedule: rec.Desc}
+	// Fixed zones cannot be loaded by name; they fall back to the chat zone.
+	if _, err := time.LoadLocation(loc.String()); err == nil {
+		r.Zone = loc.String()
+	}
+	next, err := r.next(time.Now())
+	if err != nil {
+		return Reminder{}, fmt.Errorf("invalid schedule %q: %w", rec.Cron, err)
+	}
+	r.At = next
+	return addReminder(r)
+}
+
+// addReminder assigns r an ID, stores it and schedules it when the scheduler
+// is running.
+func addReminder(r Reminder) (Reminder, error) {
+	chatID := r.ChatID
+	reminderMu.Lock()
+	count := 0
+	for _, r := range reminders {
+		if r.ChatID == chatID {
+			count++
+		}
+	}
+	if count >= MaxRemindersPerChat {
+		reminderMu.Unlock()
+		return Reminder{}, fmt.Errorf("в чате уже %d напоминаний, удалите лишние через /unremind", count)
+	}
+	r.ID, r.Created = nextReminder, time.Now()
+	nextReminder++
+	reminders[r.ID] = r
+	err := saveRemindersLocked()
+	s := reminderSched
+	reminderMu.Unlock()
+	if err != nil {
+		return r, fmt.Errorf("save reminders: %w", err)
+	}
+	if s != nil {
+		if err := scheduleReminder(s, r); err != nil {
+			return r, fmt.Errorf("schedule reminder: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// ChatReminders returns the pending reminders of a chat ordered by due time.
+func ChatReminders(chatID int64) []Reminder {
+	reminderMu.Lock()
+	var out []Reminder
+	for _, r := range reminders {
+		if r.ChatID == chatID {
+			out = append(out, r)
+		}
+	}
+	reminderMu.Unlock()
+	sortReminders(out)
+	return out
+}
+
+// migrateReminders moves the reminders of a chat, pending and delivered, to
+// its new ID. Jobs look reminders up by ID, so they keep running.
+func migrateReminders(oldID, newID int64) error {
+	reminderMu.Lock()
+	defer reminderMu.Unlock()
+	moved := false
+	for id, r := range reminders {
+		if r.ChatID == oldID {
+			r.ChatID = newID
+			reminders[id] = r
+			moved = true
+		}
+	}
+	for id, sr := range sentReminders {
+		if sr.ChatID == oldID {
+			sr.ChatID = newID
+			sentReminders[id] = sr
+			moved = true
+		}
+	}
+	if !moved {
+		return nil
+	}
+	return saveRemindersLocked()
+}
+
+// CancelReminder deletes a pending reminder of a chat.
+func CancelReminder(chatID, id int64) error {
+	reminderMu.Lock()
+	r, ok := reminders[id]
+	if !ok || r.ChatID != chatID {
+		reminderMu.Unlock()
+		return fmt.Errorf("нет напоминания #%d", id)
+	}
+	delete(reminders, id)
+	err := saveRemindersLocked()
+	s := reminderSched
+	reminderMu.Unlock()
+	if s != nil {
+		removeJobs(s, reminderTag(id))
+	}
+	return err
+}
+
+// fireReminder sends a due reminder. One-off reminders are then removed from
+// the store; recurring ones move on to their next run.
+func fireReminder(id int64) {
+	reminderMu.Lock()
+	r, ok := reminders[id]
+	s, b := reminderSched, reminderBot
+	reminderMu.Unlock()
+	if !ok {
+		return
+	}
+	key := reminderTag(id)
+	if r.Recurring() {
+		key += "@" + time.Now().UTC().Truncate(time.Minute).Format(time.RFC3339)
+	} else if s != nil {
+		removeJobs(s, reminderTag(id))
+	}
+	if claimed, err := currentRunLock().Claim(key); err == nil && !claimed {
+		return
+	}
+
+	text := reminderMessage(r.Text)
+	if late := time.Since(r.At); !r.Recurring() && late > time.Minute {
+		text += fmt.Sprintf("\n⏰ С опозданием: должно было прийти %s", r.At.In(chatLocation(r.ChatID)).Format("02.01 15:04"))
+	}
+	if b != nil {
+		sendReminder(b, r, text)
+	}
+
+	reminderMu.Lock()
+	if cur, ok := reminders[id]; ok && cur.Recurring() {
+		if next, err := cur.next(time.Now()); err == nil {
+			cur.At = next
+			reminders[id] = cur
+		}
+	} else {
+		delete(reminders, id)
+	}
+	if err := saveRemindersLocked(); err != nil {
+		logger.L.Error("save reminders", "err", err)
+	}
+	reminderMu.Unlock()
+}
+
+// chatLocation returns the timezone of a chat, falling back to the scheduler
+// timezone.
+func chatLocation(chatID int64) *time.Location {
+	if zone := chatIDScope(chatID).zone; zone != "" {
+		if loc, err := time.LoadLocation(zone); err == nil {
+			return loc
+		}
+	}
+	if r := currentTaskRunner(); r != nil && r.scheduler != nil {
+		return r.scheduler.Location()
+	}
+	reminderMu.Lock()
+	s := reminderSched
+	reminderMu.Unlock()
+	if s != nil {
+		return s.Location()
+	}
+	return time.Local
+}
+
+// formatReminder renders a reminder as one list line in loc.
+func formatReminder(r Reminder, loc *time.Location) string {
+	if r.Recurring() {
+		return fmt.Sprintf("#%d 🔁 %s (след. %s) — %s", r.ID, r.Schedule, formatNext(r.At.In(r.location())), html.EscapeString(r.Text))
+	}
+	return fmt.Sprintf("#%d %s — %s", r.ID, r.At.In(loc).Format("02.01.2006 15:04"), html.EscapeString(r.Text))
+}
+
+// recurringConfirmation echoes the interpretation of a recurring reminder.
+func recurringConfirmation(r Reminder) string {
+	return fmt.Sprintf("🔁 Напоминание #%d: %s\nСледующее: %s", r.ID, r.Schedule, formatNext(r.At.In(r.location())))
+}
+
+// pendingRecurrenceTTL is how long the buttons of an ambiguous /remind stay
+// valid.
+const pendingRecurrenceTTL = time.Hour
+
+// pendingRecurrence is a recurring /remind waiting for the user to pick one
+// of several readings.
+type pendingRecurrence struct {
+	chatID  int64
+	userID  int64
+	text    string
+	loc     *time.Location
+	options []Recurrence
+	created time.Time
+}
+
+var (
+	pendingRecurMu sync.Mutex
+	pendingRecur   = map[int64]pendingRecurrence{}
+	nextPending    int64
+)
+
+// btnRemindPick is the inline button of one reading of an ambiguous
+// schedule. The data is "<request>:<option>".
+var btnRemindPick = tb.Btn{Unique: "remind_pick"}
+
+// addPendingRecurrence stores an ambiguous request and returns its ID.
+// Expired requests are dropped.
+func addPendingRecurrence(p pendingRecurrence) int64 {
+	pendingRecurMu.Lock()
+	defer pendingRecurMu.Unlock()
+	for id, old := range pendingRecur {
+		if time.Since(old.created) > pendingRecurrenceTTL {
+			delete(pendingRecur, id)
+		}
+	}
+	nextPending++
+	pendingRecur[nextPending] = p
+	return nextPending
+}
+
+// clarifyMarkup builds one button per reading of an ambiguous schedule.
+func clarifyMarkup(id int64, options []Recurrence) *tb.ReplyMarkup {
+	m := &tb.ReplyMarkup{}
+	rows := make([]tb.Row, 0, len(options))
+	for i, o := range options {
+		rows = append(rows, m.Row(m.Data(o.Desc, btnRemindPick.Unique, fmt.Sprintf("%d:%d", id, i))))
+	}
+	m.Inline(rows...)
+	return m
+}
+
+func handleRemind(c tb.Context) error {
+	logger.L.Debug("command remind", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	payload := sanitizeInput(c.Message().Payload)
+	if err := validatePayload(payload); err != nil || payload == "" {
+		return c.Send("Usage: /remind <когда> <текст>\nНапример: /remind 15:30 позвонить маме, /remind через 20 минут чай, /remind in 2h deploy, /remind каждый будний день в 8:30 зарядка")
+	}
+	loc := chatLocation(c.Chat().ID)
+	var userID int64
+	if c.Sender() != nil {
+		userID = c.Sender().ID
+	}
+
+	rec, text, err := ParseRecurrence(payload, time.Now().In(loc))
+	if !errors.Is(err, ErrNotRecurring) {
+		var ambiguous *AmbiguousRecurrence
+		switch {
+		case errors.As(err, &ambiguous):
+			if strings.TrimSpace(text) == "" {
+				return c.Send("❌ Напишите, о чём напомнить: /remind каждый день в 9:00 зарядка")
+			}
+			id := addPendingRecurrence(pendingRecurrence{
+				chatID: c.Chat().ID, userID: userID, text: text, loc: loc,
+				options: ambiguous.Options, created: time.Now(),
+			})
+			return c.Send("🤔 Уточните расписание:", clarifyMarkup(id, ambiguous.Options))
+		case err != nil:
+			return c.Send("❌ " + err.Error())
+		}
+		if strings.TrimSpace(text) == "" {
+			return c.Send("❌ Напишите, о чём напомнить: /remind каждый день в 9:00 зарядка")
+		}
+		r, err := AddRecurringReminder(c.Chat().ID, userID, text, rec, loc)
+		if err != nil {
+			logger.L.Error("add reminder", "chat", c.Chat().ID, "err", err)
+			return c.Send("❌ " + err.Error())
+		}
+		return c.Send(recurringConfirmation(r))
+	}
+
+	at, text, err := ParseWhen(payload, time.Now().In(loc))
+	if err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	if strings.TrimSpace(text) == "" {
+		return c.Send("❌ Напишите, о чём напомнить: /remind 15:30 позвонить маме")
+	}
+	r, err := AddReminder(c.Chat().ID, userID, text, at)
+	if err != nil {
+		logger.L.Error("add reminder", "chat", c.Chat().ID, "err", err)
+		return c.Send("❌ " + err.Error())
+	}
+	return c.Send(fmt.Sprintf("🔔 Напомню %s (#%d)", at.In(loc).Format("02.01.2006 15:04"), r.ID))
+}
+
+// handleRemindPick creates the recurring reminder for the reading the user
+// picked and replaces the question with the confirmation.
+func handleRemindPick(c tb.Context) error {
+	reqPart, optPart, _ := strings.Cut(c.Callback().Data, ":")
+	id, err1 := strconv.ParseInt(reqPart, 10, 64)
+	opt, err2 := strconv.Atoi(optPart)
+	if err1 != nil || err2 != nil {
+		return c.Respond(&tb.CallbackResponse{Text: "Bad request"})
+	}
+
+	pendingRecurMu.Lock()
+	p, ok := pendingRecur[id]
+	if ok && c.Sender() != nil && p.userID != 0 && c.Sender().ID != p.userID {
+		pendingRecurMu.Unlock()
+		return c.Respond(&tb.CallbackResponse{Text: "⛔ Это не ваш запрос"})
+	}
+	if ok {
+		delete(pendingRecur, id)
+	}
+	pendingRecurMu.Unlock()
+	if !ok || time.Since(p.created) > pendingRecurrenceTTL || opt < 0 || opt >= len(p.options) {
+		return c.Respond(&tb.CallbackResponse{Text: "⌛ Запрос устарел, повторите /remind"})
+	}
+
+	r, err := AddRecurringReminder(p.chatID, p.userID, p.text, p.options[opt], p.loc)
+	if err != nil {
+		logger.L.Error("add reminder", "chat", p.chatID, "err", err)
+		return c.Respond(&tb.CallbackResponse{Text: "❌ " + err.Error()})
+	}
+	if err := c.Edit(recurringConfirmation(r)); err != nil {
+		logger.L.Debug("edit remind question", "err", err)
+	}
+	return c.Respond()
+}
+
+func handleReminders(c tb.Context) error {
+	logger.L.Debug("command reminders", "chat", c.Chat().ID)
+	list := ChatReminders(c.Chat().ID)
+	if len(list) == 0 {
+		return c.Send("🔕 Напоминаний нет. Добавить: /remind <когда> <текст>")
+	}
+	loc := chatLocation(c.Chat().ID)
+	lines := make([]string, 0, len(list))
+	for _, r := range list {
+		lines = append(lines, formatReminder(r, loc))
+	}
+	return replyLong(c, "🔔 Напоминания:\n"+strings.Join(lines, "\n")+"\n\nУдалить: /unremind <id>")
+}
+
+func handleUnremind(c tb.Context) error {
+	logger.L.Debug("command unremind", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	payload := strings.TrimPrefix(sanitizeInput(c.Message().Payload), "#")
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return c.Send("Usage: /unremind <id>")
+	}
+	if err := CancelReminder(c.Chat().ID, id); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	return c.Send(fmt.Sprintf("🗑 Напоминание #%d удалено", id))
+}
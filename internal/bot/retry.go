@@ -0,0 +1,223 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// RetryPolicy controls how a failed scheduled run is retried. Durations use
+// Go syntax such as "30s" or "2h".
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+	// BaseDelay is the wait before the first retry; it doubles after each
+	// attempt. Empty uses ErrorHandler.GetRetryDelay for the error.
+	BaseDelay string `json:"base_delay,omitempty" yaml:"base_delay,omitempty"`
+	// Deadline is how long after the scheduled time a result is still worth
+	// sending. Empty means no deadline.
+	Deadline string `json:"deadline,omitempty" yaml:"deadline,omitempty"`
+}
+
+// Validate checks the policy fields.
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("retry.max_attempts must be at least 1")
+	}
+	for _, f := range []struct{ name, value string }{{"base_delay", p.BaseDelay}, {"deadline", p.Deadline}} {
+		if f.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(f.value); err != nil || d <= 0 {
+			return fmt.Errorf("retry.%s: invalid duration %q", f.name, f.value)
+		}
+	}
+	return nil
+}
+
+// deadline returns the parsed deadline, or 0 when unset.
+func (p *RetryPolicy) deadline() time.Duration {
+	if p == nil || p.Deadline == "" {
+		return 0
+	}
+	d, _ := time.ParseDuration(p.Deadline)
+	return d
+}
+
+// NextDelay returns how long to wait before the next attempt after attempt
+// failed with err, elapsed after the scheduled time. It returns false when the
+// error is not retryable, attempts are exhausted or the retry would land past
+// the deadline.
+func (p *RetryPolicy) NextDelay(err error, attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts || !DefaultErrorHandler.IsRetryableError(err) {
+		return 0, false
+	}
+	base := time.Duration(DefaultErrorHandler.GetRetryDelay(err)) * time.Second
+	if p.BaseDelay != "" {
+		base, _ = time.ParseDuration(p.BaseDelay)
+	}
+	delay := base << (attempt - 1)
+	if d := p.deadline(); d > 0 && elapsed+delay > d {
+		return 0, false
+	}
+	return delay, true
+}
+
+// taskRun is one scheduled run of a task in a delivery slot.
+type taskRun struct {
+	task      Task
+	slot      deliverySlot
+	client    ChatCompleter
+	bot       *tb.Bot
+	chatID    int64
+	scheduled time.Time // when the slot fired; the deadline counts from it
+	attempt   int
+	delayed   bool // caught up after downtime
+}
+
+// runTaskAttempt calls OpenAI for one attempt of a scheduled task and returns
+// the response and the model used.
+func runTaskAttempt(run taskRun) (string, string, error) {
+	task := run.task
+	ctx, cancel := context.WithTimeout(context.Background(), OpenAITimeout)
+	defer cancel()
+
+	model := taskModel(task)
+
+	taskLogger := logger.GetTaskLogger()
+	openaiLogger := logger.GetOpenAILogger()
+
+	op := taskLogger.Operation("task_execution")
+	op.WithContext("task_name", task.Name)
+	op.WithContext("model", model)
+	op.WithContext("chat_id", run.chatID)
+	op.WithContext("slot", run.slot.String())
+	op.WithContext("attempt", run.attempt)
+
+	op.Step("preparing_prompt")
+	scope := chatIDScope(run.chatID)
+	if run.slot.Zone != "" {
+		scope.zone = run.slot.Zone
+	}
+	prompt, err := taskPrompt(task, model, scope)
+	if err != nil {
+		op.Failure("Upstream output unavailable", err)
+		return "", model, err
+	}
+
+	op.Step("calling_openai")
+	startTime := time.Now()
+	resp, err := TaskCompletion(ctx, run.client, task, prompt, model)
+	duration := time.Since(startTime)
+
+	openaiLogger.APICall("openai", "system_completion", err == nil, duration, err)
+
+	if err != nil {
+		op.Failure("Task execution failed", err)
+		DefaultErrorHandler.HandleTaskError(err, task.Name, model)
+		return "", model, err
+	}
+
+	op.WithContext("response_length", len(resp))
+	taskLogger.TaskExecution(task.Name, true, duration, nil)
+	op.Success("Task completed successfully")
+	return resp, model, nil
+}
+
+// runTaskWithRetry runs one attempt and, on a retryable failure, schedules the
+// next one with time.AfterFunc so the scheduler is never blocked. Every
+// attempt waits for a job slot first, see SetJobLimits.
+func runTaskWithRetry(run taskRun) {
+	task := run.task
+	release, ok := acquireJobSlot()
+	if !ok {
+		logger.L.Warn("task skipped: job limit reached", "task", task.Name, "slot", run.slot.String(), "attempt", run.attempt)
+		now := time.Now()
+		recordTaskRun(TaskRunRecord{
+			Task:     task.Name,
+			SlotTime: run.slot.Time,
+			SlotZone: run.slot.Zone,
+			Started:  now,
+			Finished: now,
+			Attempt:  run.attempt,
+			Delayed:  run.delayed,
+			Status:   RunSkipped,
+			Error:    "too many jobs running",
+		})
+		return
+	}
+	defer release()
+
+	started := time.Now()
+	resp, model, err := runTaskAttempt(run)
+	rec := TaskRunRecord{
+		Task:     task.Name,
+		SlotTime: run.slot.Time,
+		SlotZone: run.slot.Zone,
+		Started:  started,
+		Model:    model,
+		Attempt:  run.attempt,
+		Delayed:  run.delayed,
+	}
+	if err == nil {
+		if d := task.Retry.deadline(); d > 0 && time.Since(run.scheduled) > d {
+			err = fmt.Errorf("result is stale: deadline %s exceeded", d)
+			rec.Status = RunStale
+			rec.Finished = time.Now()
+			rec.Error = err.Error()
+			recordTaskRun(rec)
+			reportTaskFailure(run, err)
+			return
+		}
+		// History, dependents and sinks get the response without the delay
+		// note meant for Telegram.
+		raw := resp
+		if run.delayed {
+			resp = fmt.Sprintf("⏰ С опозданием: запуск от %s\n\n%s", run.scheduled.Format("02.01 15:04"), resp)
+		}
+		rec.Delivered, rec.Failed = broadcastTaskResult(run.bot, run.chatID, task, run.slot, resp)
+		rec.Status = RunOK
+		rec.Finished = time.Now()
+		rec.Output = raw
+		rec.ResponseLength = len([]rune(raw))
+		recordTaskRun(rec)
+		if len(task.Sinks) > 0 {
+			// Sinks run after the Telegram delivery and never hold it up.
+			msg := SinkMessage{Task: task.Name, Time: run.scheduled, Text: raw}
+			if run.slot.Zone != "" {
+				msg.Slot = run.slot.String()
+			}
+			go func() { recordSinkResults(task.Name, rec.Started, deliverToSinks(task, msg)) }()
+		}
+		recordSuccessfulRun(run.slot.tag(task), run.scheduled)
+		runDependents(run)
+		return
+	}
+
+	if delay, ok := task.Retry.NextDelay(err, run.attempt, time.Since(run.scheduled)); ok {
+		logger.L.Warn("task retry scheduled", "task", task.Name, "slot", run.slot.String(), "attempt", run.attempt+1, "delay", delay)
+		next := run
+		next.attempt++
+		time.AfterFunc(delay, func() { runTaskWithRetry(next) })
+		return
+	}
+	rec.Status = RunFailed
+	rec.Finished = time.Now()
+	rec.Error = err.Error()
+	recordTaskRun(rec)
+	reportTaskFailure(run, err)
+}
+
+// reportTaskFailure logs the final failure of a run, which also forwards it
+// to the log chat when configured, and notifies admins.
+func reportTaskFailure(run taskRun, err error) {
+	logger.L.Error("task failed", "task", run.task.Name, "slot", run.slot.String(), "attempts", run.attempt, "err", err)
+	logger.GetTaskLogger().TaskExecution(run.task.Name, false, 0, err)
+	if run.bot != nil {
+		notifyAdmins(run.bot, fmt.Sprintf("❌ Задача %s (%s) не выполнена, попыток: %d\n%v", run.task.Name, run.slot, run.attempt, err))
+	}
+}
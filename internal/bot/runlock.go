@@ -0,0 +1,260 @@
+package bot
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+)
+
+// RunLock keeps several replicas of the bot from doing the same work. Only
+// the leader polls Telegram and runs the scheduler, and every scheduled run
+// is claimed before it starts, so a slot fired by two instances during a
+// deploy is executed once. Implementations backed by a shared store let
+// replicas on different hosts coordinate.
+type RunLock interface {
+	// TryLead makes this instance the leader if no other instance is. It is
+	// called periodically and must keep returning true while leadership is
+	// held.
+	TryLead() (bool, error)
+	// Claim reserves the run identified by key. It returns false when the
+	// run was already claimed by any instance.
+	Claim(key string) (bool, error)
+	// Close gives up leadership and releases resources.
+	Close() error
+}
+
+// claimTTL is how long claims are kept; a slot never fires twice that far
+// apart.
+const claimTTL = 48 * time.Hour
+
+// LeaderRetryInterval is how often a standby instance tries to take over and
+// the leader checks it still holds the lock.
+var LeaderRetryInterval = 5 * time.Second
+
+// MemoryRunLock coordinates nothing but runs within one process. It is used
+// when RUN_LOCK is "none" and in tests.
+type MemoryRunLock struct {
+	mu     sync.Mutex
+	claims map[string]time.Time
+}
+
+// NewMemoryRunLock creates a lock that always leads.
+func NewMemoryRunLock() *MemoryRunLock {
+	return &MemoryRunLock{claims: make(map[string]time.Time)}
+}
+
+func (m *MemoryRunLock) TryLead() (bool, error) { return true, nil }
+
+func (m *MemoryRunLock) Claim(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for k, at := range m.claims {
+		if now.Sub(at) > claimTTL {
+			delete(m.claims, k)
+		}
+	}
+	if _, ok := m.claims[key]; ok {
+		return false, nil
+	}
+	m.claims[key] = now
+	return true, nil
+}
+
+func (m *MemoryRunLock) Close() error { return nil }
+
+// FileRunLock elects the leader with a lock on a file, so it works for
+// instances on one host sharing a directory, such as overlapping containers
+// with a common volume. Claims are files created exclusively in the
+// directory path + ".claims".
+type FileRunLock struct {
+	mu        sync.Mutex
+	path      string
+	claimDir  string
+	f         *os.File
+	lastPrune time.Time
+}
+
+// NewFileRunLock creates a lock on path. Leadership is not taken until
+// TryLead is called.
+func NewFileRunLock(path string) (*FileRunLock, error) {
+	dir := path + ".claims"
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return nil, fmt.Errorf("create claims dir: %w: the directory of RUN_LOCK_FILE must be writable by the bot user (uid %d)", err, os.Getuid())
+		}
+		return nil, fmt.Errorf("create claims dir: %w", err)
+	}
+	return &FileRunLock{path: path, claimDir: dir}, nil
+}
+
+func (l *FileRunLock) TryLead() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		return true, nil
+	}
+	f, ok, err := lockFile(l.path)
+	if err != nil {
+		return false, fmt.Errorf("lock %s: %w", l.path, err)
+	}
+	l.f = f
+	return ok, nil
+}
+
+func (l *FileRunLock) Claim(key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pruneLocked()
+
+	sum := sha1.Sum([]byte(key))
+	f, err := os.OpenFile(filepath.Join(l.claimDir, hex.EncodeToString(sum[:])), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = fmt.Fprintf(f, "%s\n%d\n", key, os.Getpid())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return true, err
+}
+
+// pruneLocked removes expired claims at most once an hour.
+func (l *FileRunLock) pruneLocked() {
+	if time.Since(l.lastPrune) < time.Hour {
+		return
+	}
+	l.lastPrune = time.Now()
+	entries, err := os.ReadDir(l.claimDir)
+	if err != nil {
+		logger.L.Warn("read claims dir", "dir", l.claimDir, "err", err)
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < claimTTL {
+			continue
+		}
+		if err := os.Remove(filepath.Join(l.claimDir, e.Name())); err != nil {
+			logger.L.Warn("remove claim", "file", e.Name(), "err", err)
+		}
+	}
+}
+
+func (l *FileRunLock) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := unlockFile(l.f)
+	l.f = nil
+	return err
+}
+
+// OpenRunLock creates the run lock selected by kind: "file" (lock file at
+// path) or "none".
+func OpenRunLock(kind, path string) (RunLock, error) {
+	switch kind {
+	case "", "file":
+		return NewFileRunLock(path)
+	case "none":
+		return NewMemoryRunLock(), nil
+	default:
+		return nil, fmt.Errorf("unknown run lock %q", kind)
+	}
+}
+
+var (
+	runLockMu sync.RWMutex
+	runLock   RunLock = NewMemoryRunLock()
+)
+
+// SetRunLock replaces the active run lock.
+func SetRunLock(l RunLock) {
+	runLockMu.Lock()
+	runLock = l
+	runLockMu.Unlock()
+}
+
+// ResetRunLock restores an in-memory run lock. Used in tests.
+func ResetRunLock() {
+	SetRunLock(NewMemoryRunLock())
+}
+
+func currentRunLock() RunLock {
+	runLockMu.RLock()
+	defer runLockMu.RUnlock()
+	return runLock
+}
+
+// waitForLeadership blocks until l makes this instance the leader.
+func waitForLeadership(l RunLock) error {
+	waiting := false
+	for {
+		ok, err := l.TryLead()
+		if err != nil {
+			return err
+		}
+		if ok {
+			if waiting {
+				logger.L.Info("became leader")
+			}
+			return nil
+		}
+		if !waiting {
+			logger.L.Info("another instance is the leader; waiting")
+			waiting = true
+		}
+		time.Sleep(LeaderRetryInterval)
+	}
+}
+
+// watchLeadership calls lost once l stops confirming leadership. It returns
+// when stop is closed.
+func watchLeadership(l RunLock, stop <-chan struct{}, lost func(error)) {
+	t := time.NewTicker(LeaderRetryInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			ok, err := l.TryLead()
+			if err == nil && !ok {
+				err = fmt.Errorf("leadership taken by another instance")
+			}
+			if err != nil {
+				lost(err)
+				return
+			}
+		}
+	}
+}
+
+// claimRun reserves a scheduled run so that only one instance executes it.
+// Runs are identified by slot and scheduled minute. If the lock cannot be
+// reached the run goes ahead: a duplicate is better than a lost digest.
+func claimRun(run taskRun) bool {
+	key := run.slot.tag(run.task) + "@" + run.scheduled.UTC().Truncate(time.Minute).Format(time.RFC3339)
+	ok, err := currentRunLock().Claim(key)
+	if err != nil {
+		logger.L.Error("claim run", "task", run.task.Name, "key", key, "err", err)
+		return true
+	}
+	if !ok {
+		logger.L.Info("run claimed by another instance", "task", run.task.Name, "slot", run.slot.String())
+	}
+	return ok
+}
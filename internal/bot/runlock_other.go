@@ -0,0 +1,30 @@
+//go:build !unix
+
+package bot
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile creates path exclusively and writes the PID into it. Without
+// flock the file outlives a crashed process and must then be removed by hand.
+func lockFile(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	_, _ = fmt.Fprintf(f, "%d\n", os.Getpid())
+	return f, true, nil
+}
+
+// unlockFile releases the lock by removing the file.
+func unlockFile(f *os.File) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on path without blocking and writes the
+// PID into the file. The kernel drops the lock when the process exits, so a
+// crashed leader never blocks the next one.
+func lockFile(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if err := f.Truncate(0); err == nil {
+		_, _ = fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+	return f, true, nil
+}
+
+// unlockFile releases the lock. The file stays so that the next leader locks
+// the same inode.
+func unlockFile(f *os.File) error {
+	return f.Close()
+}
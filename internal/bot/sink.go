@@ -0,0 +1,262 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"telegram-reminder/internal/logger"
+)
+
+// SinkTimeout limits one delivery to an output sink.
+const SinkTimeout = 15 * time.Second
+
+// Sink types accepted in the sinks list of a task.
+const (
+	SinkWebhook = "webhook"
+	SinkEmail   = "email"
+	SinkArchive = "archive"
+)
+
+// Payload formats of a webhook sink.
+const (
+	WebhookJSON    = "json"
+	WebhookSlack   = "slack"
+	WebhookDiscord = "discord"
+)
+
+// discordContentLimit is the longest message Discord accepts.
+const discordContentLimit = 2000
+
+// SinkConfig is an output of a task besides Telegram, listed under sinks in
+// tasks.yml.
+type SinkConfig struct {
+	Type string `json:"type" yaml:"type"`
+	// Name labels the sink in the run history; it defaults to the type.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// URL and Format configure a webhook: format is json (default), slack or
+	// discord.
+	URL    string `json:"url,omitempty" yaml:"url,omitempty"`
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// To and Subject configure an email; the server comes from SMTP_*.
+	To      []string `json:"to,omitempty" yaml:"to,omitempty"`
+	Subject string   `json:"subject,omitempty" yaml:"subject,omitempty"`
+	// Dir is the root of a Markdown archive.
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+}
+
+// label returns the name of the sink in the run history.
+func (c SinkConfig) label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+// Validate checks the fields required by the sink type.
+func (c SinkConfig) Validate() error {
+	switch c.Type {
+	case SinkWebhook:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook sink needs an http(s) url")
+		}
+		switch c.Format {
+		case "", WebhookJSON, WebhookSlack, WebhookDiscord:
+		default:
+			return fmt.Errorf("invalid webhook format %q, want json, slack or discord", c.Format)
+		}
+	case SinkEmail:
+		return validateEmailSink(c)
+	case SinkArchive:
+		if c.Dir == "" {
+			return fmt.Errorf("archive sink needs a dir")
+		}
+	default:
+		return fmt.Errorf("invalid sink type %q, want webhook, email or archive", c.Type)
+	}
+	return nil
+}
+
+// SinkMessage is a task result handed to the sinks. Text is the model
+// response in Telegram HTML, without the notes added to the Telegram message;
+// each sink converts it to the format its destination renders.
+type SinkMessage struct {
+	Task string    `json:"task"`
+	Slot string    `json:"slot,omitempty"` // set for per-chat timezone slots
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+// Sink delivers task results to a destination other than Telegram.
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, msg SinkMessage) error
+}
+
+// SinkResult is the outcome of one sink delivery, kept in the run history.
+type SinkResult struct {
+	Sink  string `json:"sink"`
+	Error string `json:"error,omitempty"`
+}
+
+// NewSink builds the sink described by c.
+func NewSink(c SinkConfig) (Sink, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case SinkWebhook:
+		format := c.Format
+		if format == "" {
+			format = WebhookJSON
+		}
+		return webhookSink{name: c.label(), url: c.URL, format: format}, nil
+	case SinkEmail:
+		return newEmailSink(c)
+	default:
+		return archiveSink{name: c.label(), dir: c.Dir}, nil
+	}
+}
+
+// deliverToSinks sends msg to every sink of the task in parallel and returns
+// the results in the order of the task's sinks list.
+func deliverToSinks(task Task, msg SinkMessage) []SinkResult {
+	results := make([]SinkResult, len(task.Sinks))
+	var wg sync.WaitGroup
+	for i, c := range task.Sinks {
+		results[i].Sink = c.label()
+		sink, err := NewSink(c)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, sink Sink) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), SinkTimeout)
+			defer cancel()
+			if err := sink.Deliver(ctx, msg); err != nil {
+				logger.L.Warn("sink delivery failed", "task", task.Name, "sink", sink.Name(), "err", err)
+				results[i].Error = err.Error()
+			}
+		}(i, sink)
+	}
+	wg.Wait()
+	return results
+}
+
+// webhookSink posts results to a URL as generic JSON or as a Slack or
+// Discord message.
+type webhookSink struct {
+	name   string
+	url    string
+	format string
+}
+
+func (s webhookSink) Name() string { return s.name }
+
+func (s webhookSink) Deliver(ctx context.Context, msg SinkMessage) error {
+	var payload any
+	switch s.format {
+	case WebhookSlack:
+		payload = map[string]string{"text": fmt.Sprintf("*%s*\n%s", msg.Task, convertTelegramHTML(msg.Text, textSlack))}
+	case WebhookDiscord:
+		content := fmt.Sprintf("**%s**\n%s", msg.Task, convertTelegramHTML(msg.Text, textMarkdown))
+		if utf8.RuneCountInString(content) > discordContentLimit {
+			content = string([]rune(content)[:discordContentLimit-1]) + "…"
+		}
+		payload = map[string]string{"content": content}
+	default:
+		// Generic receivers get plain text and the original markup.
+		payload = struct {
+			SinkMessage
+			HTML string `json:"html"`
+		}{SinkMessage{Task: msg.Task, Slot: msg.Slot, Time: msg.Time, Text: convertTelegramHTML(msg.Text, textPlain)}, msg.Text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.L.Error("failed to close response body", "err", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook status: %s", resp.Status)
+	}
+	return nil
+}
+
+// archiveSink appends results to dated Markdown files,
+// <dir>/<task>/<YYYY-MM-DD>.md, one section per run.
+type archiveSink struct {
+	name string
+	dir  string
+}
+
+// archiveMu serialises archive writes so parallel runs do not interleave.
+var archiveMu sync.Mutex
+
+func (s archiveSink) Name() string { return s.name }
+
+func (s archiveSink) Deliver(_ context.Context, msg SinkMessage) error {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	dir := filepath.Join(s.dir, archiveDirName(msg.Task))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, msg.Time.Format("2006-01-02")+".md")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	heading := msg.Time.Format("15:04")
+	if msg.Slot != "" {
+		heading += " (" + msg.Slot + ")"
+	}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		heading = fmt.Sprintf("# %s — %s\n\n## %s", msg.Task, msg.Time.Format("02.01.2006"), heading)
+	} else {
+		heading = "## " + heading
+	}
+	_, err = fmt.Fprintf(f, "%s\n\n%s\n\n", heading, strings.TrimSpace(convertTelegramHTML(msg.Text, textMarkdown)))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// archiveDirName turns a task name into a safe directory name.
+func archiveDirName(task string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, task)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPConfig is the mail server used by email sinks.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+var (
+	smtpMu  sync.RWMutex
+	smtpCfg SMTPConfig
+)
+
+// SetSMTPConfig sets the mail server of email sinks.
+func SetSMTPConfig(c SMTPConfig) {
+	smtpMu.Lock()
+	smtpCfg = c
+	smtpMu.Unlock()
+}
+
+func currentSMTPConfig() SMTPConfig {
+	smtpMu.RLock()
+	defer smtpMu.RUnlock()
+	return smtpCfg
+}
+
+// validateEmailSink checks the recipients of an email sink.
+func validateEmailSink(c SinkConfig) error {
+	if len(c.To) == 0 {
+		return fmt.Errorf("email sink needs at least one address in to")
+	}
+	for _, addr := range c.To {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("invalid email address %q", addr)
+		}
+	}
+	return nil
+}
+
+// emailSink mails results through the SMTP server set by SetSMTPConfig.
+type emailSink struct {
+	name    string
+	to      []*mail.Address
+	subject string
+}
+
+// newEmailSink parses the recipients of a validated email sink config.
+func newEmailSink(c SinkConfig) (emailSink, error) {
+	s := emailSink{name: c.label(), subject: c.Subject}
+	for _, raw := range c.To {
+		addr, err := mail.ParseAddress(raw)
+		if err != nil {
+			return emailSink{}, fmt.Errorf("invalid email address %q", raw)
+		}
+		s.to = append(s.to, addr)
+	}
+	return s, nil
+}
+
+func (s emailSink) Name() string { return s.name }
+
+func (s emailSink) Deliver(ctx context.Context, msg SinkMessage) error {
+	cfg := currentSMTPConfig()
+	if cfg.Host == "" || cfg.From == "" {
+		return fmt.Errorf("SMTP_HOST and SMTP_FROM are not set")
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP_FROM %q", cfg.From)
+	}
+	subject := s.subject
+	if subject == "" {
+		subject = fmt.Sprintf("%s — %s", msg.Task, msg.Time.Format("02.01.2006"))
+	}
+	body := buildEmail(from, s.to, subject, convertTelegramHTML(msg.Text, textPlain), msg.Time)
+	// The envelope takes bare addresses; display names stay in the headers.
+	rcpt := make([]string, len(s.to))
+	for i, a := range s.to {
+		rcpt[i] = a.Address
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	// smtp.SendMail has no context; run it aside and give up on timeout.
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(addr, auth, from.Address, rcpt, body) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// buildEmail renders a plain text UTF-8 message.
+func buildEmail(from *mail.Address, to []*mail.Address, subject, text string, date time.Time) []byte {
+	var b strings.Builder
+	rcpt := make([]string, len(to))
+	for i, a := range to {
+		rcpt[i] = a.String()
+	}
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(rcpt, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	enc := base64.StdEncoding.EncodeToString([]byte(text))
+	for len(enc) > 76 {
+		b.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	b.WriteString(enc + "\r\n")
+	return []byte(b.String())
+}
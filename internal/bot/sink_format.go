@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Text formats of sink destinations. Task results are Telegram HTML and are
+// converted to the format the destination renders.
+const (
+	textPlain    = "plain"    // email, generic JSON
+	textMarkdown = "markdown" // archive, Discord
+	textSlack    = "slack"    // Slack mrkdwn
+)
+
+// htmlTagRx matches an opening or closing tag of Telegram HTML.
+var htmlTagRx = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)([^>]*)>`)
+
+// hrefRx extracts the href attribute of a link.
+var hrefRx = regexp.MustCompile(`href\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// tagMarks maps Telegram HTML tags to the markers of a text format.
+var tagMarks = map[string]map[string]string{
+	textMarkdown: {
+		"b": "**", "strong": "**", "i": "*", "em": "*",
+		"s": "~~", "strike": "~~", "del": "~~", "code": "`",
+	},
+	textSlack: {
+		"b": "*", "strong": "*", "i": "_", "em": "_",
+		"s": "~", "strike": "~", "del": "~", "code": "`",
+	},
+}
+
+// slackEscaper escapes the characters Slack reserves for its own markup.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// convertTelegramHTML renders Telegram HTML as plain text, Markdown or Slack
+// mrkdwn. Unknown tags are dropped and entities are unescaped, except the ones
+// Slack needs escaped.
+func convertTelegramHTML(text, format string) string {
+	unescape := html.UnescapeString
+	if format == textSlack {
+		unescape = func(s string) string { return slackEscaper.Replace(html.UnescapeString(s)) }
+	}
+	var b strings.Builder
+	var links []string // href of every open <a>, innermost last
+	var linkStart []int
+	pos := 0
+	for _, m := range htmlTagRx.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(unescape(text[pos:m[0]]))
+		pos = m[1]
+		closing := text[m[2]:m[3]] == "/"
+		tag := strings.ToLower(text[m[4]:m[5]])
+		attrs := text[m[6]:m[7]]
+
+		switch tag {
+		case "br":
+			b.WriteByte('\n')
+		case "pre":
+			if format == textPlain {
+				continue
+			}
+			if closing {
+				b.WriteString("\n```")
+			} else {
+				b.WriteString("```\n")
+			}
+		case "a":
+			if !closing {
+				href := ""
+				if h := hrefRx.FindStringSubmatch(attrs); h != nil {
+					href = html.UnescapeString(h[1] + h[2])
+				}
+				links = append(links, href)
+				linkStart = append(linkStart, b.Len())
+				if format == textMarkdown && href != "" {
+					b.WriteByte('[')
+				}
+				continue
+			}
+			if len(links) == 0 {
+				continue
+			}
+			href := links[len(links)-1]
+			start := linkStart[len(linkStart)-1]
+			links, linkStart = links[:len(links)-1], linkStart[:len(linkStart)-1]
+			if href == "" {
+				continue
+			}
+			switch format {
+			case textMarkdown:
+				b.WriteString("](" + href + ")")
+			case textSlack:
+				label := b.String()[start:]
+				rest := b.String()[:start]
+				b.Reset()
+				b.WriteString(rest + "<" + href + "|" + label + ">")
+			default:
+				if label := b.String()[start:]; label != href {
+					b.WriteString(" (" + href + ")")
+				}
+			}
+		default:
+			b.WriteString(tagMarks[format][tag])
+		}
+	}
+	b.WriteString(unescape(text[pos:]))
+	return b.String()
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// sentReminderTTL is how long the buttons of a delivered reminder keep
+// working.
+const sentReminderTTL = 7 * 24 * time.Hour
+
+// Snooze options of the reminder buttons.
+const (
+	Snooze10m      = "10m"
+	Snooze1h       = "1h"
+	SnoozeTomorrow = "tomorrow"
+)
+
+// SentReminder is a delivered reminder message whose Done and snooze buttons
+// are still active. It is stored with the reminders so that buttons pressed
+// after a restart still work.
+type SentReminder struct {
+	ID         int64     `json:"id"`
+	ReminderID int64     `json:"reminder_id"`
+	ChatID     int64     `json:"chat_id"`
+	UserID     int64     `json:"user_id,omitempty"`
+	Text       string    `json:"text"`
+	Due        time.Time `json:"due"`
+	Sent       time.Time `json:"sent"`
+}
+
+// Inline buttons under a delivered reminder. The data carries the SentReminder
+// ID and, for snoozing, the option: "<id>:<option>".
+var (
+	btnReminderDone   = tb.Btn{Unique: "reminder_done"}
+	btnReminderSnooze = tb.Btn{Unique: "reminder_snooze"}
+)
+
+// reminderMessage renders the text of a delivered reminder.
+func reminderMessage(text string) string {
+	return "🔔 Напоминание: " + html.EscapeString(text)
+}
+
+// reminderMarkup builds the Done/+10 min/+1 h/Tomorrow keyboard.
+func reminderMarkup(sentID int64) *tb.ReplyMarkup {
+	m := &tb.ReplyMarkup{}
+	id := strconv.FormatInt(sentID, 10)
+	m.Inline(m.Row(
+		m.Data("✅ Готово", btnReminderDone.Unique, id),
+		m.Data("+10 мин", btnReminderSnooze.Unique, id+":"+Snooze10m),
+		m.Data("+1 ч", btnReminderSnooze.Unique, id+":"+Snooze1h),
+		m.Data("Завтра", btnReminderSnooze.Unique, id+":"+SnoozeTomorrow),
+	))
+	return m
+}
+
+// sendReminder delivers a due reminder with its buttons and remembers the
+// message so the buttons can be handled later.
+func sendReminder(b *tb.Bot, r Reminder, text string) {
+	reminderMu.Lock()
+	for id, old := range sentReminders {
+		if time.Since(old.Sent) > sentReminderTTL {
+			delete(sentReminders, id)
+		}
+	}
+	sr := SentReminder{ID: nextSent, ReminderID: r.ID, ChatID: r.ChatID, UserID: r.UserID, Text: r.Text, Due: r.At, Sent: time.Now()}
+	nextSent++
+	reminderMu.Unlock()
+
+	if _, err := b.Send(tb.ChatID(r.ChatID), text, reminderMarkup(sr.ID), tb.ModeHTML); err != nil {
+		DefaultErrorHandler.HandleTelegramError(err, r.ChatID)
+		logger.L.Warn("send reminder", "id", r.ID, "chat_id", r.ChatID, "err", err)
+		return
+	}
+
+	reminderMu.Lock()
+	sentReminders[sr.ID] = sr
+	if err := saveRemindersLocked(); err != nil {
+		logger.L.Error("save reminders", "err", err)
+	}
+	reminderMu.Unlock()
+}
+
+// takeSentReminder removes a delivered reminder of the chat so its buttons
+// work only once.
+func takeSentReminder(chatID, id int64) (SentReminder, error) {
+	reminderMu.Lock()
+	defer reminderMu.Unlock()
+	sr, ok := sentReminders[id]
+	if !ok || sr.ChatID != chatID || time.Since(sr.Sent) > sentReminderTTL {
+		return SentReminder{}, fmt.Errorf("напоминание уже закрыто")
+	}
+	delete(sentReminders, id)
+	if err := saveRemindersLocked(); err != nil {
+		logger.L.Error("save reminders", "err", err)
+	}
+	return sr, nil
+}
+
+// CompleteReminder closes a delivered reminder.
+func CompleteReminder(chatID, sentID int64) (SentReminder, error) {
+	return takeSentReminder(chatID, sentID)
+}
+
+// SnoozeReminder closes a delivered reminder and sets a one-off reminder with
+// the same text: in 10 minutes, in an hour or tomorrow at the time it was due.
+func SnoozeReminder(chatID, sentID int64, option string, now time.Time) (Reminder, error) {
+	var at time.Time
+	switch option {
+	case Snooze10m:
+		at = now.Add(10 * time.Minute)
+	case Snooze1h:
+		at = now.Add(time.Hour)
+	case SnoozeTomorrow:
+	default:
+		return Reminder{}, fmt.Errorf("unknown snooze option %q", option)
+	}
+	sr, err := takeSentReminder(chatID, sentID)
+	if err != nil {
+		return Reminder{}, err
+	}
+	if option == SnoozeTomorrow {
+		loc := chatLocation(chatID)
+		at = sr.Due.In(loc).AddDate(0, 0, 1)
+		if !at.After(now) {
+			at = now.In(loc).AddDate(0, 0, 1)
+		}
+	}
+	return AddReminder(sr.ChatID, sr.UserID, sr.Text, at)
+}
+
+// sentIDFromCallback parses "<id>[:<option>]" button data.
+func sentIDFromCallback(data string) (int64, string, error) {
+	idPart, option, _ := strings.Cut(data, ":")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	return id, option, err
+}
+
+func handleReminderDone(c tb.Context) error {
+	id, _, err := sentIDFromCallback(c.Callback().Data)
+	if err != nil {
+		return c.Respond(&tb.CallbackResponse{Text: "Bad ID"})
+	}
+	sr, err := CompleteReminder(c.Chat().ID, id)
+	if err != nil {
+		return c.Respond(&tb.CallbackResponse{Text: "⌛ " + err.Error()})
+	}
+	if err := c.Edit(reminderMessage(sr.Text)+"\n✅ Готово", tb.ModeHTML); err != nil {
+		logger.L.Debug("edit reminder message", "err", err)
+	}
+	return c.Respond(&tb.CallbackResponse{Text: "✅ Готово"})
+}
+
+func handleReminderSnooze(c tb.Context) error {
+	id, option, err := sentIDFromCallback(c.Callback().Data)
+	if err != nil {
+		return c.Respond(&tb.CallbackResponse{Text: "Bad ID"})
+	}
+	r, err := SnoozeReminder(c.Chat().ID, id, option, time.Now())
+	if err != nil {
+		logger.L.Debug("snooze reminder", "chat", c.Chat().ID, "id", id, "err", err)
+		return c.Respond(&tb.CallbackResponse{Text: "⌛ " + err.Error()})
+	}
+	when := r.At.In(chatLocation(r.ChatID)).Format("02.01 15:04")
+	if err := c.Edit(fmt.Sprintf("%s\n⏰ Отложено до %s (#%d)", reminderMessage(r.Text), when, r.ID), tb.ModeHTML); err != nil {
+		logger.L.Debug("edit reminder message", "err", err)
+	}
+	return c.Respond(&tb.CallbackResponse{Text: "⏰ Отложено до " + when})
+}
+
+// handleStaleCallback answers buttons no handler is registered for, such as
+// keyboards left over from older versions, so the client stops waiting.
+func handleStaleCallback(c tb.Context) error {
+	logger.L.Debug("unhandled callback", "data", c.Callback().Data)
+	return c.Respond(&tb.CallbackResponse{Text: "⌛ Кнопка больше не работает"})
+}
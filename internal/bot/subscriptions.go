@@ -0,0 +1,327 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"telegram-reminder/internal/domain"
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// SubscribeAll is the wildcard subscription that matches every task and digest.
+const SubscribeAll = "*"
+
+var (
+	subsMu               sync.RWMutex
+	defaultSubscriptions = []string{SubscribeAll}
+)
+
+// SetDefaultSubscriptions sets the subscription set given to newly added chats.
+func SetDefaultSubscriptions(subs []string) {
+	subsMu.Lock()
+	defaultSubscriptions = normalizeSubscriptions(subs)
+	subsMu.Unlock()
+}
+
+// DefaultSubscriptions returns the subscription set given to newly added chats.
+func DefaultSubscriptions() []string {
+	subsMu.RLock()
+	defer subsMu.RUnlock()
+	return append([]string{}, defaultSubscriptions...)
+}
+
+// normalizeSubscriptions lowercases, deduplicates and sorts subscription keys.
+// The result is never nil so that "no subscriptions" survives a JSON round trip.
+func normalizeSubscriptions(subs []string) []string {
+	seen := make(map[string]bool, len(subs))
+	out := []string{}
+	for _, s := range subs {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "all" {
+			s = SubscribeAll
+		}
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// taskDigestType returns the digest type a task produces, given by its digest
+// field or detected from the digest prompt placeholders it uses.
+func taskDigestType(task Task) (domain.DigestType, bool) {
+	if task.Digest != "" {
+		return domain.DigestType(task.Digest), true
+	}
+	for digestType, cfg := range domain.GetDigestConfigs() {
+		if strings.Contains(task.Prompt, "{"+cfg.Placeholder+"}") {
+			return digestType, true
+		}
+	}
+	return "", false
+}
+
+// WantsTask reports whether the chat is subscribed to the task, either by the
+// task name or by the digest type the task produces. Chats that never chose
+// their subscriptions receive everything.
+func (c ChatInfo) WantsTask(task Task) bool {
+	if c.Subscriptions == nil {
+		return true
+	}
+	for _, s := range c.Subscriptions {
+		if s == SubscribeAll || s == strings.ToLower(task.Name) {
+			return true
+		}
+	}
+	if digestType, ok := taskDigestType(task); ok {
+		for _, s := range c.Subscriptions {
+			if s == string(digestType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SubscriptionKeys returns all valid subscription keys: task names and
+// digest types.
+func SubscriptionKeys() []string {
+	TasksMu.RLock()
+	tasks := append([]Task(nil), LoadedTasks...)
+	TasksMu.RUnlock()
+
+	keys := []string{}
+	for _, t := range tasks {
+		if t.Name != "" {
+			keys = append(keys, t.Name)
+		}
+	}
+	for digestType := range domain.GetDigestConfigs() {
+		keys = append(keys, string(digestType))
+	}
+	return normalizeSubscriptions(keys)
+}
+
+func isSubscriptionKey(key string) bool {
+	if key == SubscribeAll {
+		return true
+	}
+	for _, k := range SubscriptionKeys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// GetChatSubscriptions returns the effective subscription set of a chat.
+func GetChatSubscriptions(id int64) ([]string, error) {
+	chat, ok, err := currentChatStore().GetChat(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("chat %d is not registered", id)
+	}
+	if chat.Subscriptions == nil {
+		return []string{SubscribeAll}, nil
+	}
+	return append([]string{}, chat.Subscriptions...), nil
+}
+
+// updateChatSubscriptions applies fn to the chat's subscription set, saves it
+// and reschedules the tasks whose delivery slots changed.
+func updateChatSubscriptions(id int64, fn func([]string) []string) ([]string, error) {
+	before, after, err := saveChatSubscriptions(id, fn)
+	if err != nil {
+		return nil, err
+	}
+	rescheduleSubscribedTasks(before, after)
+	return after.Subscriptions, nil
+}
+
+// saveChatSubscriptions applies fn to the chat's subscription set and saves
+// it. It returns the chat before and after the change.
+func saveChatSubscriptions(id int64, fn func([]string) []string) (before, after ChatInfo, err error) {
+	wlMu.Lock()
+	defer wlMu.Unlock()
+
+	chat, ok, err := chatStore.GetChat(id)
+	if err != nil {
+		return before, after, err
+	}
+	if !ok {
+		return before, after, fmt.Errorf("chat %d is not registered", id)
+	}
+	before = chat
+	current := chat.Subscriptions
+	if current == nil {
+		current = []string{SubscribeAll}
+	}
+	chat.Subscriptions = normalizeSubscriptions(fn(append([]string{}, current...)))
+	if err := chatStore.SaveChat(chat); err != nil {
+		return before, after, err
+	}
+	return before, chat, nil
+}
+
+// rescheduleSubscribedTasks reschedules the tasks the chat started or stopped
+// receiving, but only when that adds or removes a delivery slot: the chat's
+// own slot outside the default one has no other recipients.
+func rescheduleSubscribedTasks(before, after ChatInfo) {
+	r := currentTaskRunner()
+	if r == nil || r.chatID != 0 || !after.Active {
+		return
+	}
+	TasksMu.RLock()
+	tasks := append([]Task(nil), LoadedTasks...)
+	TasksMu.RUnlock()
+
+	zone := defaultZoneName()
+	changed := map[string]bool{}
+	for _, task := range tasks {
+		if task.Paused || task.triggered() || before.WantsTask(task) == after.WantsTask(task) {
+			continue
+		}
+		slot := chatSlot(after, task, zone)
+		if slot.isDefault(task) {
+			continue
+		}
+		ids, err := recipientsForTask(task, slot)
+		if err != nil {
+			logger.L.Error("load recipients", "task", task.Name, "err", err)
+			continue
+		}
+		if len(ids) == 0 || len(ids) == 1 && ids[0] == after.ID {
+			changed[task.Name] = true
+		}
+	}
+	names, groups := groupTasksByName(tasks)
+	for _, name := range names {
+		if !changed[name] {
+			continue
+		}
+		if err := r.replaceTaskJobs(name, groups[name]); err != nil {
+			logger.L.Error("reschedule task", "task", name, "err", err)
+		}
+	}
+}
+
+// SubscribeChat adds key to the chat's subscriptions.
+func SubscribeChat(id int64, key string) ([]string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	return updateChatSubscriptions(id, func(subs []string) []string {
+		if key == SubscribeAll || key == "all" {
+			return []string{SubscribeAll}
+		}
+		return append(subs, key)
+	})
+}
+
+// UnsubscribeChat removes key from the chat's subscriptions. Unsubscribing
+// from a single key while subscribed to everything expands the wildcard into
+// all other known keys.
+func UnsubscribeChat(id int64, key string) ([]string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	return updateChatSubscriptions(id, func(subs []string) []string {
+		if key == SubscribeAll || key == "all" {
+			return []string{}
+		}
+		expanded := []string{}
+		for _, s := range subs {
+			if s == SubscribeAll {
+				expanded = append(expanded, SubscriptionKeys()...)
+				continue
+			}
+			expanded = append(expanded, s)
+		}
+		out := []string{}
+		for _, s := range expanded {
+			if s != key {
+				out = append(out, s)
+			}
+		}
+		return out
+	})
+}
+
+// recipientsForTask returns active chats subscribed to the task whose
+// delivery slot matches slot. Tasks run after their upstreams have a single
+// run and go to every subscribed chat.
+func recipientsForTask(task Task, slot deliverySlot) ([]int64, error) {
+	chats, err := ListChats()
+	if err != nil {
+		return nil, err
+	}
+	zone := defaultZoneName()
+	var ids []int64
+	for _, chat := range chats {
+		if chat.Active && chat.WantsTask(task) && (task.triggered() || chatSlot(chat, task, zone) == slot) {
+			ids = append(ids, chat.ID)
+		}
+	}
+	return ids, nil
+}
+
+// formatSubscriptions renders a subscription set for a chat message.
+func formatSubscriptions(subs []string) string {
+	if len(subs) == 0 {
+		return "📭 Подписок нет. Добавьте: /subscribe <задача|дайджест>"
+	}
+	for _, s := range subs {
+		if s == SubscribeAll {
+			return "✅ Подписка на все задачи и дайджесты"
+		}
+	}
+	return "📬 Подписки:\n" + strings.Join(subs, "\n")
+}
+
+func handleSubscribe(c tb.Context) error {
+	logger.L.Debug("command subscribe", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	key := strings.ToLower(sanitizeInput(c.Message().Payload))
+	if err := validatePayload(key); err != nil || key == "" {
+		return c.Send("Usage: /subscribe <задача|дайджест|all>\nДоступно: " + strings.Join(SubscriptionKeys(), ", "))
+	}
+	if key != "all" && !isSubscriptionKey(key) {
+		return c.Send(fmt.Sprintf("Неизвестная подписка: %s\nДоступно: %s", key, strings.Join(SubscriptionKeys(), ", ")))
+	}
+	subs, err := SubscribeChat(c.Chat().ID, key)
+	if err != nil {
+		logger.L.Error("subscribe", "chat", c.Chat().ID, "err", err)
+		return c.Send("❌ Сначала активируйте бота командой /start")
+	}
+	return c.Send(formatSubscriptions(subs))
+}
+
+func handleUnsubscribe(c tb.Context) error {
+	logger.L.Debug("command unsubscribe", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	key := strings.ToLower(sanitizeInput(c.Message().Payload))
+	if err := validatePayload(key); err != nil || key == "" {
+		return c.Send("Usage: /unsubscribe <задача|дайджест|all>")
+	}
+	if key != "all" && !isSubscriptionKey(key) {
+		return c.Send(fmt.Sprintf("Неизвестная подписка: %s", key))
+	}
+	subs, err := UnsubscribeChat(c.Chat().ID, key)
+	if err != nil {
+		logger.L.Error("unsubscribe", "chat", c.Chat().ID, "err", err)
+		return c.Send("❌ Сначала активируйте бота командой /start")
+	}
+	return c.Send(formatSubscriptions(subs))
+}
+
+func handleSubscriptions(c tb.Context) error {
+	logger.L.Debug("command subscriptions", "chat", c.Chat().ID)
+	subs, err := GetChatSubscriptions(c.Chat().ID)
+	if err != nil {
+		return c.Send("❌ Сначала активируйте бота командой /start")
+	}
+	return c.Send(formatSubscriptions(subs) + "\n\nДоступно: " + strings.Join(SubscriptionKeys(), ", "))
+}
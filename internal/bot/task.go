@@ -2,6 +2,7 @@ package bot
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 
 	"telegram-reminder/internal/logger"
 
+	"github.com/robfig/cron/v3"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -52,6 +54,8 @@ func readTasksFile(fn string) ([]Task, string, string, error) {
 		BasePrompt string `json:"base_prompt" yaml:"base_prompt"`
 		Model      string `json:"model" yaml:"model"`
 		Tasks      []Task `json:"tasks" yaml:"tasks"`
+		// GenParams are defaults for the tasks of the file.
+		GenParams `yaml:",inline"`
 	}
 	if ext == ".yaml" || ext == ".yml" {
 		if err := yaml.Unmarshal(data, &tf); err == nil && len(tf.Tasks) > 0 {
@@ -59,6 +63,7 @@ func readTasksFile(fn string) ([]Task, string, string, error) {
 				if tf.Tasks[i].Model == "" {
 					tf.Tasks[i].Model = tf.Model
 				}
+				tf.Tasks[i].GenParams = tf.Tasks[i].GenParams.inherit(tf.GenParams)
 			}
 			return tf.Tasks, tf.BasePrompt, tf.Model, nil
 		}
@@ -71,6 +76,7 @@ func readTasksFile(fn string) ([]Task, string, string, error) {
 				if tf.Tasks[i].Model == "" {
 					tf.Tasks[i].Model = tf.Model
 				}
+				tf.Tasks[i].GenParams = tf.Tasks[i].GenParams.inherit(tf.GenParams)
 			}
 			return tf.Tasks, tf.BasePrompt, tf.Model, nil
 		}
@@ -81,6 +87,14 @@ func readTasksFile(fn string) ([]Task, string, string, error) {
 	return tasks, "", "", nil
 }
 
+// taskSet is the result of reading the task configuration.
+type taskSet struct {
+	Tasks      []Task
+	BasePrompt string
+	Model      string
+	File       string // source file, empty for TASKS_JSON and built-in defaults
+}
+
 // LoadTasks reads task configuration from multiple sources in order of priority:
 // 1. TASKS_FILE environment variable (YAML/JSON file)
 // 2. TASKS_JSON environment variable (JSON string)
@@ -91,32 +105,84 @@ func readTasksFile(fn string) ([]Task, string, string, error) {
 //   - []Task: Array of loaded tasks
 //   - error: Any error that occurred during loading
 func LoadTasks() ([]Task, error) {
+	set, err := loadTaskSet()
+	if err != nil {
+		return nil, err
+	}
+	applyTaskSetConfig(set)
+	return set.Tasks, nil
+}
+
+// fileModel is the model declared by the tasks file at the last load,
+// guarded by ModelMu. A reload only overrides the runtime model when the file
+// changes it, so a model picked with /model survives prompt edits.
+var fileModel string
+
+// applyTaskSetConfig copies the file-level base prompt and model into the
+// runtime configuration.
+func applyTaskSetConfig(set taskSet) {
+	updateRuntimeConfig(func(cfg *RuntimeConfig) {
+		if set.BasePrompt != "" {
+			cfg.BasePrompt = set.BasePrompt
+		}
+		if set.Model != "" && set.Model != fileModel {
+			cfg.CurrentModel = set.Model
+		}
+		fileModel = set.Model
+	})
+}
+
+// tasksFilePath returns the file LoadTasks reads tasks from, or "" when tasks
+// come from TASKS_JSON or the built-in defaults.
+func tasksFilePath() string {
+	if fn := os.Getenv("TASKS_FILE"); fn != "" {
+		return fn
+	}
+	if os.Getenv("TASKS_JSON") != "" {
+		return ""
+	}
+	for _, fn := range []string{"tasks.yml", "tasks.yaml"} {
+		if _, err := os.Stat(fn); err == nil {
+			return fn
+		}
+	}
+	return ""
+}
+
+// loadTaskSet reads tasks without touching the runtime configuration and
+// applies changes made from Telegram on top of them.
+func loadTaskSet() (taskSet, error) {
+	set, err := loadBaseTaskSet()
+	if err != nil {
+		return set, err
+	}
+	set.Tasks = applyTaskOverlay(set.Tasks)
+	// Tasks referring to prompts or tasks that do not exist cannot run at
+	// all, so they reject the whole set.
+	if issues := checkReferences(set.Tasks); len(issues) > 0 {
+		return taskSet{}, issuesError(set.Tasks, issues)
+	}
+	return set, nil
+}
+
+// loadBaseTaskSet reads tasks from the configured source.
+func loadBaseTaskSet() (taskSet, error) {
 	if fn := os.Getenv("TASKS_FILE"); fn != "" {
 		logger.L.Debug("load tasks from file", "file", fn)
 		tasks, bp, m, err := readTasksFile(fn)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", fn, err)
-		}
-		if bp != "" || m != "" {
-			updateRuntimeConfig(func(cfg *RuntimeConfig) {
-				if bp != "" {
-					cfg.BasePrompt = bp
-				}
-				if m != "" {
-					cfg.CurrentModel = m
-				}
-			})
+			return taskSet{}, fmt.Errorf("%s: %w", fn, err)
 		}
-		return tasks, nil
+		return taskSet{Tasks: tasks, BasePrompt: bp, Model: m, File: fn}, nil
 	}
 
 	if txt := os.Getenv("TASKS_JSON"); txt != "" {
 		logger.L.Debug("load tasks from json env")
 		tasks := []Task{}
 		if err := json.Unmarshal([]byte(txt), &tasks); err != nil {
-			return nil, err
+			return taskSet{}, err
 		}
-		return tasks, nil
+		return taskSet{Tasks: tasks}, nil
 	}
 
 	for _, fn := range []string{"tasks.yml", "tasks.yaml"} {
@@ -124,19 +190,9 @@ func LoadTasks() ([]Task, error) {
 			logger.L.Debug("load tasks from local", "file", fn)
 			tasks, bp, m, err := readTasksFile(fn)
 			if err != nil {
-				return nil, err
+				return taskSet{}, err
 			}
-			if bp != "" || m != "" {
-				updateRuntimeConfig(func(cfg *RuntimeConfig) {
-					if bp != "" {
-						cfg.BasePrompt = bp
-					}
-					if m != "" {
-						cfg.CurrentModel = m
-					}
-				})
-			}
-			return tasks, nil
+			return taskSet{Tasks: tasks, BasePrompt: bp, Model: m, File: fn}, nil
 		}
 	}
 
@@ -145,10 +201,97 @@ func LoadTasks() ([]Task, error) {
 
 	lunchTime := envDefault("LUNCH_TIME", DefaultLunchTime)
 	briefTime := envDefault("BRIEF_TIME", DefaultBriefTime)
-	return []Task{
+	return taskSet{Tasks: []Task{
 		{Name: "lunch", Prompt: LunchIdeaPrompt, Time: lunchTime},
 		{Name: "brief", Prompt: DailyBriefPrompt, Time: briefTime},
-	}, nil
+	}}, nil
+}
+
+// ValidateTasks checks that task names are unique and schedules parse. All
+// problems are reported together.
+func ValidateTasks(tasks []Task) error {
+	return issuesError(tasks, checkTasks(tasks))
+}
+
+// taskIssue is a problem with the task at index. Field names the task field
+// at fault, if any.
+type taskIssue struct {
+	index int
+	field string
+	err   error
+}
+
+// issuesError joins issues into one error prefixed with task labels.
+func issuesError(tasks []Task, issues []taskIssue) error {
+	var errs []error
+	for _, is := range issues {
+		errs = append(errs, fmt.Errorf("%s: %w", taskLabel(tasks[is.index], is.index), is.err))
+	}
+	return errors.Join(errs...)
+}
+
+// checkTasks returns every problem found in tasks.
+func checkTasks(tasks []Task) []taskIssue {
+	var issues []taskIssue
+	add := func(i int, field string, err error) {
+		issues = append(issues, taskIssue{index: i, field: field, err: err})
+	}
+	seen := map[string]bool{}
+	for i, t := range tasks {
+		if t.Name != "" {
+			if seen[t.Name] {
+				add(i, "name", fmt.Errorf("duplicate task name"))
+			}
+			seen[t.Name] = true
+		}
+		if strings.TrimSpace(t.Prompt) == "" && t.Digest == "" {
+			add(i, "prompt", fmt.Errorf("empty prompt"))
+		}
+		if t.Retry != nil {
+			if err := t.Retry.Validate(); err != nil {
+				add(i, "retry", err)
+			}
+		}
+		if err := t.GenParams.Validate(); err != nil {
+			add(i, "", err)
+		}
+		if err := validateJitter(t.Jitter); err != nil {
+			add(i, "jitter", err)
+		}
+		for _, s := range t.Sinks {
+			if err := s.Validate(); err != nil {
+				add(i, "sinks", err)
+			}
+		}
+		if t.Cron != "" {
+			if _, err := cron.ParseStandard(t.Cron); err != nil {
+				add(i, "cron", fmt.Errorf("invalid cron %q: %w", t.Cron, err))
+			}
+		} else if t.Time != "" {
+			if _, err := parseClock(t.Time); err != nil {
+				add(i, "time", err)
+			}
+		}
+	}
+	return append(issues, checkReferences(tasks)...)
+}
+
+// checkReferences reports unknown placeholders, digests and upstream tasks,
+// and dependency cycles.
+func checkReferences(tasks []Task) []taskIssue {
+	var issues []taskIssue
+	for i, t := range tasks {
+		issues = append(issues, validatePrompt(t, i)...)
+	}
+	return append(issues, validateDependencies(tasks)...)
+}
+
+// taskLabel names the i-th task in error messages.
+func taskLabel(t Task, i int) string {
+	if t.Name == "" {
+		return fmt.Sprintf("task %d", i+1)
+	}
+	return t.Name
 }
 
 // FormatTasks returns a text summary of tasks with their time or cron expression.
@@ -166,7 +309,9 @@ func FormatTasks(tasks []Task) string {
 	var b strings.Builder
 	for i, t := range tasks {
 		when := t.Cron
-		if when == "" {
+		if t.triggered() {
+			when = "после " + strings.Join(t.upstreams(), ", ")
+		} else if when == "" {
 			when = t.Time
 			if when == "" {
 				when = "00:00"
@@ -176,6 +321,9 @@ func FormatTasks(tasks []Task) string {
 		if name == "" {
 			name = fmt.Sprintf("task %d", i+1)
 		}
+		if t.Paused {
+			name += " (⏸ пауза)"
+		}
 		fmt.Fprintf(&b, "%s - %s\n", when, name)
 	}
 	return strings.TrimSpace(b.String())
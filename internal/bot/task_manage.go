@@ -0,0 +1,432 @@
+package bot
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+
+	"telegram-reminder/internal/logger"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// taskOverlay holds tasks created or edited from Telegram. Overlay tasks
+// replace file tasks with the same name; deleted names hide file tasks.
+// Editing, pausing or resuming a file task stores only the changed fields, so
+// later edits of the file still apply to the rest of it.
+type taskOverlay struct {
+	Tasks   []Task               `json:"tasks"`
+	Deleted []string             `json:"deleted,omitempty"`
+	Paused  map[string]bool      `json:"paused,omitempty"`
+	Edited  map[string]taskPatch `json:"edited,omitempty"`
+}
+
+// taskPatch holds the fields of a file task changed from Telegram. Empty
+// fields keep the value from the file.
+type taskPatch struct {
+	Prompt string `json:"prompt,omitempty"`
+	Time   string `json:"time,omitempty"` // replaces the cron expression too
+}
+
+// apply overrides the fields of t set in the patch.
+func (p taskPatch) apply(t *Task) {
+	if p.Prompt != "" {
+		t.Prompt = p.Prompt
+	}
+	if p.Time != "" {
+		t.Time = p.Time
+		t.Cron = ""
+	}
+}
+
+var (
+	overlayMu   sync.RWMutex
+	overlay     taskOverlay
+	overlayPath string // empty keeps changes in memory only
+)
+
+// taskNameRx matches names usable as Telegram commands.
+var taskNameRx = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// LoadTaskOverlay reads the overlay file and persists later changes there.
+// A missing file yields an empty overlay.
+func LoadTaskOverlay(path string) error {
+	var ov taskOverlay
+	if err := loadJSONFile(path, &ov); err != nil {
+		return fmt.Errorf("load task overlay %s: %w", path, err)
+	}
+	overlayMu.Lock()
+	overlay = ov
+	overlayPath = path
+	overlayMu.Unlock()
+	return nil
+}
+
+// ResetTaskOverlay drops all overlay changes. Used in tests.
+func ResetTaskOverlay() {
+	overlayMu.Lock()
+	overlay = taskOverlay{}
+	overlayPath = ""
+	overlayMu.Unlock()
+}
+
+// replaceTask returns a copy of tasks where the task with the given name is
+// replaced by task, or removed when task is nil. A new task is appended.
+func replaceTask(tasks []Task, name string, task *Task) []Task {
+	out := make([]Task, 0, len(tasks)+1)
+	found := false
+	for _, t := range tasks {
+		if t.Name != name {
+			out = append(out, t)
+			continue
+		}
+		found = true
+		if task != nil {
+			out = append(out, *task)
+		}
+	}
+	if !found && task != nil {
+		out = append(out, *task)
+	}
+	return out
+}
+
+// applyTaskOverlay merges the overlay into tasks loaded from the file.
+func applyTaskOverlay(tasks []Task) []Task {
+	overlayMu.RLock()
+	defer overlayMu.RUnlock()
+	for _, name := range overlay.Deleted {
+		tasks = replaceTask(tasks, name, nil)
+	}
+	for i := range overlay.Tasks {
+		tasks = replaceTask(tasks, overlay.Tasks[i].Name, &overlay.Tasks[i])
+	}
+	if len(overlay.Paused) > 0 || len(overlay.Edited) > 0 {
+		tasks = append([]Task(nil), tasks...)
+		for i := range tasks {
+			if patch, ok := overlay.Edited[tasks[i].Name]; ok {
+				patch.apply(&tasks[i])
+			}
+			if paused, ok := overlay.Paused[tasks[i].Name]; ok {
+				tasks[i].Paused = paused
+			}
+		}
+	}
+	return tasks
+}
+
+// recordOverlay stores the new state of a task, or its deletion, in the overlay.
+func recordOverlay(name string, task *Task) error {
+	overlayMu.Lock()
+	defer overlayMu.Unlock()
+	return recordOverlayLocked(name, task)
+}
+
+// recordOverlayLocked is recordOverlay with overlayMu held.
+func recordOverlayLocked(name string, task *Task) error {
+	next := taskOverlay{Tasks: []Task{}, Paused: withoutTask(overlay.Paused, name), Edited: withoutTask(overlay.Edited, name)}
+	for _, t := range overlay.Tasks {
+		if t.Name != name {
+			next.Tasks = append(next.Tasks, t)
+		}
+	}
+	for _, d := range overlay.Deleted {
+		if d != name {
+			next.Deleted = append(next.Deleted, d)
+		}
+	}
+	if task != nil {
+		next.Tasks = append(next.Tasks, *task)
+	} else {
+		next.Deleted = append(next.Deleted, name)
+	}
+	return saveOverlayLocked(next)
+}
+
+// recordPaused stores the paused flag of a task.
+func recordPaused(name string, task Task) error {
+	return recordFields(name, task, func(next *taskOverlay) {
+		if next.Paused == nil {
+			next.Paused = make(map[string]bool)
+		}
+		next.Paused[name] = task.Paused
+	})
+}
+
+// recordEdit stores the fields of a task changed by patch.
+func recordEdit(name string, task Task, patch func(*taskPatch)) error {
+	return recordFields(name, task, func(next *taskOverlay) {
+		if next.Edited == nil {
+			next.Edited = make(map[string]taskPatch)
+		}
+		p := next.Edited[name]
+		patch(&p)
+		next.Edited[name] = p
+	})
+}
+
+// recordFields stores a change of some fields of a task. A task the overlay
+// already holds in full keeps being stored in full; for a file task set
+// records only the changed fields in a copy of the overlay.
+func recordFields(name string, task Task, set func(next *taskOverlay)) error {
+	overlayMu.Lock()
+	defer overlayMu.Unlock()
+
+	for _, t := range overlay.Tasks {
+		if t.Name == name {
+			return recordOverlayLocked(name, &task)
+		}
+	}
+	next := overlay
+	next.Paused = maps.Clone(overlay.Paused)
+	next.Edited = maps.Clone(overlay.Edited)
+	set(&next)
+	return saveOverlayLocked(next)
+}
+
+// withoutTask copies a per-task map of the overlay except the entry of name.
+func withoutTask[V any](m map[string]V, name string) map[string]V {
+	var out map[string]V
+	for n, v := range m {
+		if n == name {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]V)
+		}
+		out[n] = v
+	}
+	return out
+}
+
+// saveOverlayLocked persists next and makes it the current overlay.
+// overlayMu must be held.
+func saveOverlayLocked(next taskOverlay) error {
+	if overlayPath != "" {
+		if err := saveJSONFile(overlayPath, next); err != nil {
+			return err
+		}
+	}
+	overlay = next
+	return nil
+}
+
+// changeTask applies fn to the named task, validates the result, persists it
+// to the overlay and replaces the task's jobs. fn receives nil for an unknown
+// task and returns the new task, or nil to delete it.
+func changeTask(name string, fn func(current *Task) (*Task, error)) error {
+	return changeTaskWith(name, fn, func(updated *Task) error {
+		return recordOverlay(name, updated)
+	})
+}
+
+// changeTaskWith is changeTask with a custom way to persist the change.
+func changeTaskWith(name string, fn func(current *Task) (*Task, error), record func(updated *Task) error) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	TasksMu.RLock()
+	tasks := append([]Task(nil), LoadedTasks...)
+	TasksMu.RUnlock()
+
+	var current *Task
+	if t, ok := FindTask(tasks, name); ok {
+		current = &t
+	}
+	updated, err := fn(current)
+	if err != nil {
+		return err
+	}
+	tasks = replaceTask(tasks, name, updated)
+	if err := issuesError(tasks, changeIssues(tasks, name)); err != nil {
+		return err
+	}
+	if err := record(updated); err != nil {
+		return fmt.Errorf("save task overlay: %w", err)
+	}
+
+	TasksMu.Lock()
+	LoadedTasks = tasks
+	TasksMu.Unlock()
+
+	if r := currentTaskRunner(); r != nil {
+		var jobs []Task
+		if updated != nil {
+			jobs = []Task{*updated}
+		}
+		if err := r.replaceTaskJobs(name, jobs); err != nil {
+			return fmt.Errorf("reschedule task: %w", err)
+		}
+	}
+	logger.L.Info("task changed", "task", name, "deleted", updated == nil)
+	return nil
+}
+
+// changeIssues returns the problems of the named task and of the dependency
+// graph. Problems of other tasks come from the file and do not block changes
+// made from Telegram.
+func changeIssues(tasks []Task, name string) []taskIssue {
+	var out []taskIssue
+	for _, is := range checkTasks(tasks) {
+		if tasks[is.index].Name == name || is.field == "depends_on" {
+			out = append(out, is)
+		}
+	}
+	return out
+}
+
+// isReservedCommand reports whether name is a built-in bot command.
+func isReservedCommand(name string) bool {
+	for _, cmd := range builtinCommandNames() {
+		if cmd == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTask creates a daily task at the given HH:MM time.
+func AddTask(name, at, prompt string) error {
+	if !taskNameRx.MatchString(name) {
+		return fmt.Errorf("invalid task name %q: use a-z, 0-9 and _", name)
+	}
+	if isReservedCommand(name) {
+		return fmt.Errorf("name %q is taken by a bot command", name)
+	}
+	return changeTask(name, func(current *Task) (*Task, error) {
+		if current != nil {
+			return nil, fmt.Errorf("task %q already exists", name)
+		}
+		return &Task{Name: name, Prompt: prompt, Time: at}, nil
+	})
+}
+
+// editTask applies patch to an existing task, recording only the patched
+// fields for tasks from the file.
+func editTask(name string, patch func(*taskPatch)) error {
+	return changeTaskWith(name, func(current *Task) (*Task, error) {
+		if current == nil {
+			return nil, fmt.Errorf("unknown task %q", name)
+		}
+		var p taskPatch
+		patch(&p)
+		t := *current
+		p.apply(&t)
+		return &t, nil
+	}, func(updated *Task) error {
+		return recordEdit(name, *updated, patch)
+	})
+}
+
+// SetTaskPrompt replaces the prompt of a task.
+func SetTaskPrompt(name, prompt string) error {
+	return editTask(name, func(p *taskPatch) { p.Prompt = prompt })
+}
+
+// SetTaskTime moves a task to a daily HH:MM schedule.
+func SetTaskTime(name, at string) error {
+	return editTask(name, func(p *taskPatch) { p.Time = at })
+}
+
+// PauseTask stops scheduling a task without deleting it.
+func PauseTask(name string) error {
+	return setTaskPaused(name, true)
+}
+
+// ResumeTask schedules a paused task again.
+func ResumeTask(name string) error {
+	return setTaskPaused(name, false)
+}
+
+// setTaskPaused pauses or resumes a task, recording only the flag for tasks
+// from the file.
+func setTaskPaused(name string, paused bool) error {
+	return changeTaskWith(name, func(current *Task) (*Task, error) {
+		if current == nil {
+			return nil, fmt.Errorf("unknown task %q", name)
+		}
+		t := *current
+		t.Paused = paused
+		return &t, nil
+	}, func(updated *Task) error {
+		return recordPaused(name, *updated)
+	})
+}
+
+// DeleteTask removes a task and its jobs.
+func DeleteTask(name string) error {
+	return changeTask(name, func(current *Task) (*Task, error) {
+		if current == nil {
+			return nil, fmt.Errorf("unknown task %q", name)
+		}
+		return nil, nil
+	})
+}
+
+func handleAddTask(c tb.Context) error {
+	logger.L.Debug("command addtask", "chat", c.Chat().ID)
+	payload := sanitizeInput(c.Message().Payload)
+	parts := strings.SplitN(payload, " ", 3)
+	if err := validatePayload(payload); err != nil || len(parts) != 3 || strings.TrimSpace(parts[2]) == "" {
+		return c.Send("Usage: /addtask <имя> <HH:MM> <промпт>")
+	}
+	if err := AddTask(parts[0], parts[1], strings.TrimSpace(parts[2])); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	return c.Send(fmt.Sprintf("✅ Задача /%s добавлена на %s", parts[0], parts[1]))
+}
+
+func handleEditTask(c tb.Context) error {
+	logger.L.Debug("command edittask", "chat", c.Chat().ID)
+	payload := sanitizeInput(c.Message().Payload)
+	parts := strings.SplitN(payload, " ", 2)
+	if err := validatePayload(payload); err != nil || len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return c.Send("Usage: /edittask <имя> <промпт>")
+	}
+	if err := SetTaskPrompt(parts[0], strings.TrimSpace(parts[1])); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	return c.Send(fmt.Sprintf("✏️ Промпт задачи %s обновлён", parts[0]))
+}
+
+func handleSetTime(c tb.Context) error {
+	logger.L.Debug("command settime", "chat", c.Chat().ID)
+	payload := sanitizeInput(c.Message().Payload)
+	parts := strings.Fields(payload)
+	if err := validatePayload(payload); err != nil || len(parts) != 2 {
+		return c.Send("Usage: /settime <имя> <HH:MM>")
+	}
+	if err := SetTaskTime(parts[0], parts[1]); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	return c.Send(fmt.Sprintf("⏰ %s: %s", parts[0], parts[1]))
+}
+
+// handleTaskNameCommand builds handlers for commands that take only a task name.
+func handleTaskNameCommand(usage, done string, action func(string) error) func(tb.Context) error {
+	return func(c tb.Context) error {
+		logger.L.Debug("command task change", "chat", c.Chat().ID, "payload", c.Message().Payload)
+		name := sanitizeInput(c.Message().Payload)
+		if err := validatePayload(name); err != nil || name == "" || strings.ContainsAny(name, " \n\t") {
+			return c.Send(usage)
+		}
+		if err := action(name); err != nil {
+			return c.Send("❌ " + err.Error())
+		}
+		return c.Send(fmt.Sprintf(done, name))
+	}
+}
+
+func handlePauseTask(c tb.Context) error {
+	return handleTaskNameCommand("Usage: /pausetask <имя>", "⏸ Задача %s приостановлена", PauseTask)(c)
+}
+
+func handleResumeTask(c tb.Context) error {
+	return handleTaskNameCommand("Usage: /resumetask <имя>", "▶️ Задача %s возобновлена", ResumeTask)(c)
+}
+
+func handleDeleteTask(c tb.Context) error {
+	return handleTaskNameCommand("Usage: /deltask <имя>", "🗑 Задача %s удалена", DeleteTask)(c)
+}
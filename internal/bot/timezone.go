@@ -0,0 +1,349 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-reminder/internal/logger"
+
+	"github.com/go-co-op/gocron"
+	tb "gopkg.in/telebot.v3"
+)
+
+// deliverySlot identifies one scheduled run of a task. Daily tasks run at
+// Time, cron tasks use their cron expression.
+// An empty Zone means the scheduler's own location.
+type deliverySlot struct {
+	Time string
+	Zone string
+}
+
+// isDefault reports whether the slot is the task's own schedule.
+func (s deliverySlot) isDefault(task Task) bool {
+	return s == defaultSlot(task)
+}
+
+// tag returns the gocron tag of the slot job.
+func (s deliverySlot) tag(task Task) string {
+	return fmt.Sprintf("%s@%s@%s", task.Name, s.Time, s.Zone)
+}
+
+func (s deliverySlot) String() string {
+	when := s.Time
+	if when == "" {
+		when = "cron"
+	}
+	if s.Zone == "" {
+		return when
+	}
+	return when + " " + s.Zone
+}
+
+// defaultSlot returns the slot defined by the task itself.
+func defaultSlot(task Task) deliverySlot {
+	if task.Cron != "" {
+		return deliverySlot{}
+	}
+	t := task.Time
+	if t == "" {
+		t = "00:00"
+	}
+	return deliverySlot{Time: t}
+}
+
+// parseClock parses a task time given as HH:MM or HH:MM:SS.
+func parseClock(s string) (time.Time, error) {
+	if at, err := time.Parse("15:04", s); err == nil {
+		return at, nil
+	}
+	at, err := time.Parse("15:04:05", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return at, nil
+}
+
+// chatSlot returns the slot in which the chat receives the task. defaultZone
+// is the scheduler location name; chats in that zone share the default slot.
+// Per-chat times apply only to tasks with a daily time: a cron schedule keeps
+// its own days.
+func chatSlot(chat ChatInfo, task Task, defaultZone string) deliverySlot {
+	slot := defaultSlot(task)
+	if override, ok := chat.TaskTimes[task.Name]; ok && override != "" && task.Cron == "" {
+		slot.Time = override
+	}
+	if chat.Timezone != "" && chat.Timezone != defaultZone {
+		slot.Zone = chat.Timezone
+	}
+	return slot
+}
+
+// taskRunner holds the dependencies shared by scheduled task jobs. It is set
+// by ScheduleDailyMessages so that tasks can be rescheduled at runtime.
+type taskRunner struct {
+	scheduler *gocron.Scheduler
+	client    ChatCompleter
+	bot       *tb.Bot
+	chatID    int64
+}
+
+var (
+	runnerMu sync.RWMutex
+	runner   *taskRunner
+)
+
+func setTaskRunner(r *taskRunner) {
+	runnerMu.Lock()
+	runner = r
+	runnerMu.Unlock()
+}
+
+func currentTaskRunner() *taskRunner {
+	runnerMu.RLock()
+	defer runnerMu.RUnlock()
+	return runner
+}
+
+// defaultZoneName returns the scheduler location name, or "" before scheduling.
+func defaultZoneName() string {
+	if r := currentTaskRunner(); r != nil && r.scheduler != nil {
+		return r.scheduler.Location().String()
+	}
+	return ""
+}
+
+// taskSlots returns the distinct slots in which the task must run: the
+// default slot plus every slot requested by an active subscribed chat.
+// When a fixed chat ID is configured only the default slot is used.
+func taskSlots(task Task, chatID int64) ([]deliverySlot, error) {
+	slots := []deliverySlot{defaultSlot(task)}
+	if chatID != 0 {
+		return slots, nil
+	}
+	chats, err := ListChats()
+	if err != nil {
+		return slots, err
+	}
+	seen := map[deliverySlot]bool{slots[0]: true}
+	zone := defaultZoneName()
+	for _, chat := range chats {
+		if !chat.Active || !chat.WantsTask(task) {
+			continue
+		}
+		slot := chatSlot(chat, task, zone)
+		if !seen[slot] {
+			seen[slot] = true
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots[1:], func(i, j int) bool {
+		return slots[1+i].String() < slots[1+j].String()
+	})
+	return slots, nil
+}
+
+// scheduleTaskSlots schedules one job per delivery slot of the task. Paused
+// tasks get no jobs. schedulerMu must be held.
+func (r *taskRunner) scheduleTaskSlots(task Task) error {
+	if task.Paused {
+		logger.L.Debug("task paused", "task", task.Name)
+		return nil
+	}
+	if task.triggered() {
+		logger.L.Debug("task runs after upstreams", "task", task.Name, "upstreams", task.upstreams())
+		return nil
+	}
+	slots, err := taskSlots(task, r.chatID)
+	if err != nil {
+		logger.L.Error("load delivery slots", "task", task.Name, "err", err)
+	}
+	for _, slot := range slots {
+		job := createTaskJob(task, slot, r.client, r.bot, r.chatID)
+		if err := scheduleTaskAt(r.scheduler, task, slot, job); err != nil {
+			return fmt.Errorf("slot %s: %w", slot, err)
+		}
+	}
+	return nil
+}
+
+// rescheduleTask replaces all jobs of the task with freshly computed slots.
+func (r *taskRunner) rescheduleTask(task Task) error {
+	return r.replaceTaskJobs(task.Name, []Task{task})
+}
+
+// replaceTaskJobs removes every job tagged with name and schedules tasks in
+// their place. Unnamed tasks share the empty tag and are replaced together.
+func (r *taskRunner) replaceTaskJobs(name string, tasks []Task) error {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	if err := r.scheduler.RemoveByTag(name); err != nil && !errors.Is(err, gocron.ErrJobNotFoundWithTag) {
+		return err
+	}
+	for _, task := range tasks {
+		if err := r.scheduleTaskSlots(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupTasksByName groups tasks by their job tag, preserving order.
+func groupTasksByName(tasks []Task) (names []string, groups map[string][]Task) {
+	groups = map[string][]Task{}
+	for _, task := range tasks {
+		if _, ok := groups[task.Name]; !ok {
+			names = append(names, task.Name)
+		}
+		groups[task.Name] = append(groups[task.Name], task)
+	}
+	return names, groups
+}
+
+// rescheduleAllTasks recomputes delivery slots of every loaded task. It is a
+// no-op before the scheduler is running.
+func rescheduleAllTasks() {
+	r := currentTaskRunner()
+	if r == nil {
+		return
+	}
+	TasksMu.RLock()
+	tasks := append([]Task(nil), LoadedTasks...)
+	TasksMu.RUnlock()
+	names, groups := groupTasksByName(tasks)
+	for _, name := range names {
+		if err := r.replaceTaskJobs(name, groups[name]); err != nil {
+			logger.L.Error("reschedule task", "task", name, "err", err)
+		}
+	}
+}
+
+// updateChat applies fn to a registered chat and saves it.
+func updateChat(id int64, fn func(*ChatInfo) error) error {
+	wlMu.Lock()
+	defer wlMu.Unlock()
+
+	chat, ok, err := chatStore.GetChat(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("chat %d is not registered", id)
+	}
+	if err := fn(&chat); err != nil {
+		return err
+	}
+	return chatStore.SaveChat(chat)
+}
+
+// SetChatTimezone sets the IANA timezone of a chat. An empty zone resets the
+// chat to the scheduler's timezone.
+func SetChatTimezone(id int64, zone string) error {
+	if zone != "" {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return fmt.Errorf("unknown timezone %q", zone)
+		}
+		zone = loc.String()
+	}
+	if err := updateChat(id, func(c *ChatInfo) error {
+		c.Timezone = zone
+		return nil
+	}); err != nil {
+		return err
+	}
+	rescheduleAllTasks()
+	return nil
+}
+
+// SetChatTaskTime overrides the delivery time of a task for a chat. An empty
+// value removes the override.
+func SetChatTaskTime(id int64, taskName, at string) error {
+	TasksMu.RLock()
+	task, ok := FindTask(LoadedTasks, taskName)
+	TasksMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown task %q", taskName)
+	}
+	if at != "" && task.Cron != "" {
+		return fmt.Errorf("task %q runs on cron %q; its own time cannot be set per chat", taskName, task.Cron)
+	}
+	if at != "" {
+		if _, err := parseClock(at); err != nil {
+			return err
+		}
+	}
+	if err := updateChat(id, func(c *ChatInfo) error {
+		if at == "" {
+			delete(c.TaskTimes, taskName)
+			return nil
+		}
+		if c.TaskTimes == nil {
+			c.TaskTimes = map[string]string{}
+		}
+		c.TaskTimes[taskName] = at
+		return nil
+	}); err != nil {
+		return err
+	}
+	if r := currentTaskRunner(); r != nil {
+		if err := r.rescheduleTask(task); err != nil {
+			logger.L.Error("reschedule task", "task", task.Name, "err", err)
+		}
+	}
+	return nil
+}
+
+func handleTimezone(c tb.Context) error {
+	logger.L.Debug("command tz", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	zone := sanitizeInput(c.Message().Payload)
+	if err := validatePayload(zone); err != nil {
+		return c.Send("Usage: /tz <Area/City|reset>")
+	}
+	if zone == "" {
+		chat, ok, err := currentChatStore().GetChat(c.Chat().ID)
+		if err != nil || !ok {
+			return c.Send("❌ Сначала активируйте бота командой /start")
+		}
+		current := chat.Timezone
+		if current == "" {
+			current = defaultZoneName() + " (по умолчанию)"
+		}
+		return c.Send(fmt.Sprintf("🕒 Часовой пояс чата: %s\nСменить: /tz Asia/Almaty, сбросить: /tz reset", current))
+	}
+	if strings.EqualFold(zone, "reset") {
+		zone = ""
+	}
+	if err := SetChatTimezone(c.Chat().ID, zone); err != nil {
+		logger.L.Debug("set timezone", "chat", c.Chat().ID, "err", err)
+		return c.Send("❌ " + err.Error())
+	}
+	if zone == "" {
+		return c.Send("🕒 Часовой пояс сброшен на " + defaultZoneName())
+	}
+	return c.Send("🕒 Часовой пояс чата: " + zone)
+}
+
+func handleMyTime(c tb.Context) error {
+	logger.L.Debug("command mytime", "chat", c.Chat().ID, "payload", c.Message().Payload)
+	payload := sanitizeInput(c.Message().Payload)
+	parts := strings.Fields(payload)
+	if err := validatePayload(payload); err != nil || len(parts) != 2 {
+		return c.Send("Usage: /mytime <задача> <HH:MM|reset>")
+	}
+	at := parts[1]
+	if strings.EqualFold(at, "reset") {
+		at = ""
+	}
+	if err := SetChatTaskTime(c.Chat().ID, parts[0], at); err != nil {
+		logger.L.Debug("set task time", "chat", c.Chat().ID, "err", err)
+		return c.Send("❌ " + err.Error())
+	}
+	if at == "" {
+		return c.Send(fmt.Sprintf("⏰ %s: время по расписанию", parts[0]))
+	}
+	return c.Send(fmt.Sprintf("⏰ %s: %s", parts[0], at))
+}
@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Problem is an error found in a tasks file. Line is 1-based; 0 means the
+// line is unknown.
+type Problem struct {
+	Line    int
+	Task    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Task == "" {
+		return p.Message
+	}
+	return p.Task + ": " + p.Message
+}
+
+// ValidateTasksFile checks a tasks file without connecting anywhere. Besides
+// the checks done when tasks load it reports names taken by built-in
+// commands and models missing from SupportedModels. Problems are sorted by
+// line; the error is set only when the file cannot be read or parsed.
+func ValidateTasksFile(fn string) ([]Problem, error) {
+	tasks, _, model, err := readTasksFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	nodes, modelNode := taskNodes(data)
+
+	line := func(i int, field string) int {
+		if i >= len(nodes) {
+			return 0
+		}
+		if l := fieldLine(nodes[i], field); l != 0 {
+			return l
+		}
+		if field == "model" && modelNode != nil {
+			return modelNode.Line
+		}
+		return nodes[i].Line
+	}
+
+	var problems []Problem
+	for _, is := range checkTasks(tasks) {
+		problems = append(problems, Problem{line(is.index, is.field), taskLabel(tasks[is.index], is.index), is.err.Error()})
+	}
+	if model != "" && !isSupportedModel(model) && modelNode != nil {
+		problems = append(problems, Problem{Line: modelNode.Line, Message: fmt.Sprintf("unknown model %q", model)})
+	}
+	for i, t := range tasks {
+		if t.Name != "" && isReservedCommand(t.Name) {
+			problems = append(problems, Problem{line(i, "name"), t.Name, fmt.Sprintf("name clashes with the built-in command /%s", t.Name)})
+		}
+		if t.Model != "" && t.Model != model && !isSupportedModel(t.Model) {
+			problems = append(problems, Problem{line(i, "model"), taskLabel(t, i), fmt.Sprintf("unknown model %q", t.Model)})
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems, nil
+}
+
+// taskNodes returns the YAML nodes of the tasks in a tasks file, which may be
+// a list of tasks or a mapping with a tasks key, and the value node of the
+// file-level model. JSON parses as YAML, so both formats get line numbers.
+func taskNodes(data []byte) (tasks []*yaml.Node, model *yaml.Node) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	switch root.Kind {
+	case yaml.SequenceNode:
+		return root.Content, nil
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			switch key, value := root.Content[i], root.Content[i+1]; key.Value {
+			case "tasks":
+				if value.Kind == yaml.SequenceNode {
+					tasks = value.Content
+				}
+			case "model":
+				model = value
+			}
+		}
+	}
+	return tasks, model
+}
+
+// fieldLine returns the line of a field of a task mapping node, or 0 when the
+// task has no such field.
+func fieldLine(task *yaml.Node, field string) int {
+	if field == "" || task.Kind != yaml.MappingNode {
+		return 0
+	}
+	for i := 0; i+1 < len(task.Content); i += 2 {
+		if task.Content[i].Value == field {
+			return task.Content[i].Line
+		}
+	}
+	return 0
+}
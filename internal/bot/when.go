@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxReminderAhead is how far in the future a reminder may be set.
+const maxReminderAhead = 366 * 24 * time.Hour
+
+// timeUnits maps English and Russian unit words and abbreviations to their
+// duration.
+var timeUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+
+	"с": time.Second, "сек": time.Second, "секунду": time.Second, "секунды": time.Second, "секунд": time.Second,
+	"м": time.Minute, "мин": time.Minute, "минуту": time.Minute, "минуты": time.Minute, "минут": time.Minute,
+	"ч": time.Hour, "час": time.Hour, "часа": time.Hour, "часов": time.Hour,
+	"д": 24 * time.Hour, "день": 24 * time.Hour, "дня": 24 * time.Hour, "дней": 24 * time.Hour,
+	"нед": 7 * 24 * time.Hour, "неделю": 7 * 24 * time.Hour, "недели": 7 * 24 * time.Hour, "недель": 7 * 24 * time.Hour,
+}
+
+var errNoTime = errors.New("не понял время: укажите 15:30, 2026-11-01 09:00, «через 20 минут» или «in 2h»")
+
+// ParseWhen splits the time at the start of a /remind payload from the
+// reminder text. It understands "15:30" (today or, once passed, tomorrow),
+// "2026-11-01 09:00", "01.11.2026 09:00", "завтра 09:00", "tomorrow 09:00"
+// and relative times such as "in 2h", "in 20 minutes", "через 20 минут" or
+// "через час". now gives the current time and the timezone of absolute times.
+func ParseWhen(s string, now time.Time) (time.Time, string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return time.Time{}, "", errNoTime
+	}
+	at, n, err := parseWhenFields(fields, now)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	if !at.After(now) {
+		return time.Time{}, "", fmt.Errorf("время %s уже прошло", at.Format("02.01.2006 15:04"))
+	}
+	if at.Sub(now) > maxReminderAhead {
+		return time.Time{}, "", fmt.Errorf("можно напомнить не позже чем через год")
+	}
+	return at, strings.Join(fields[n:], " "), nil
+}
+
+// parseWhenFields parses the time at the start of fields and returns it with
+// the number of fields used.
+func parseWhenFields(fields []string, now time.Time) (time.Time, int, error) {
+	loc := now.Location()
+	first := strings.ToLower(fields[0])
+	switch first {
+	case "in", "через":
+		d, n, err := parseRelative(fields[1:])
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		return now.Add(d), n + 1, nil
+	case "tomorrow", "завтра":
+		if len(fields) < 2 {
+			return time.Time{}, 0, errNoTime
+		}
+		clock, err := time.Parse("15:04", fields[1])
+		if err != nil {
+			return time.Time{}, 0, errNoTime
+		}
+		d := now.AddDate(0, 0, 1)
+		return time.Date(d.Year(), d.Month(), d.Day(), clock.Hour(), clock.Minute(), 0, 0, loc), 2, nil
+	}
+
+	if len(fields) >= 2 {
+		for _, layout := range []string{"2006-01-02 15:04", "02.01.2006 15:04"} {
+			if at, err := time.ParseInLocation(layout, fields[0]+" "+fields[1], loc); err == nil {
+				return at, 2, nil
+			}
+		}
+	}
+	if clock, err := time.Parse("15:04", fields[0]); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, 1, nil
+	}
+	return time.Time{}, 0, errNoTime
+}
+
+// parseRelative parses an amount of time such as "2h", "1h30m", "20 минут",
+// "20мин", "a minute" or "час" and returns it with the number of fields used.
+func parseRelative(fields []string) (time.Duration, int, error) {
+	if len(fields) == 0 {
+		return 0, 0, errNoTime
+	}
+	tok := strings.ToLower(fields[0])
+	if tok == "полчаса" {
+		return 30 * time.Minute, 1, nil
+	}
+	if d, err := time.ParseDuration(tok); err == nil && d > 0 {
+		return d, 1, nil
+	}
+
+	digits := strings.IndexFunc(tok, func(r rune) bool { return !unicode.IsDigit(r) })
+	if digits < 0 {
+		digits = len(tok)
+	}
+	amount, unit, used := 1, tok[digits:], 1
+	if digits > 0 {
+		amount, _ = strconv.Atoi(tok[:digits])
+	}
+	if (digits > 0 && unit == "") || tok == "a" || tok == "an" {
+		if len(fields) < 2 {
+			return 0, 0, errNoTime
+		}
+		unit, used = strings.ToLower(fields[1]), 2
+	}
+	d, ok := timeUnits[unit]
+	if !ok || amount <= 0 {
+		return 0, 0, errNoTime
+	}
+	return time.Duration(amount) * d, used, nil
+}
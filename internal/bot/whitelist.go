@@ -19,43 +19,86 @@ type ChatInfo struct {
 	Username string    `json:"username"` // Username if available
 	AddedAt  time.Time `json:"added_at"`
 	Active   bool      `json:"active"` // Whether chat is active for broadcasts
+	// Subscriptions lists task names and digest types the chat receives.
+	// nil means the chat predates subscriptions and receives everything.
+	Subscriptions []string `json:"subscriptions"`
+	// Timezone is the chat's IANA timezone; empty means the scheduler's zone.
+	Timezone string `json:"timezone,omitempty"`
+	// TaskTimes overrides the HH:MM delivery time of individual tasks.
+	TaskTimes map[string]string `json:"task_times,omitempty"`
+	// Status is the moderation state; empty means approved.
+	Status string `json:"status,omitempty"`
+}
+
+// Moderation states of a chat. Only approved chats can be active.
+const (
+	ChatApproved = "approved"
+	ChatPending  = "pending"
+	ChatRejected = "rejected"
+)
+
+// State returns the moderation state of the chat.
+func (c ChatInfo) State() string {
+	if c.Status == "" {
+		return ChatApproved
+	}
+	return c.Status
 }
 
 var (
-	wlMu         sync.RWMutex
-	whitelistID  []int64             // Legacy support
-	chatRegistry map[int64]*ChatInfo // Enhanced chat management
+	wlMu        sync.RWMutex // guards chatStore and serialises read-modify-write cycles
+	whitelistID []int64      // Legacy in-memory whitelist, imported into the store once
+	chatStore   ChatStore    = NewMemoryChatStore()
 )
 
-func init() {
-	chatRegistry = make(map[int64]*ChatInfo)
-	// Migration: convert existing whitelist entries to new format
-	migrateExistingChats()
+// SetChatStore replaces the chat registry backend. Any chats still held in
+// the legacy in-memory whitelist are imported into the new store once.
+func SetChatStore(store ChatStore) error {
+	wlMu.Lock()
+	defer wlMu.Unlock()
+
+	imported, err := ImportLegacyWhitelist(store, whitelistID)
+	if err != nil {
+		return fmt.Errorf("import legacy whitelist: %w", err)
+	}
+	if imported > 0 {
+		logger.L.Info("legacy whitelist migrated", "chats", imported)
+	}
+	whitelistID = nil
+	chatStore = store
+	return nil
 }
 
-// migrateExistingChats converts legacy whitelist entries to new chat registry
-func migrateExistingChats() {
-	// This will be called during startup to migrate any existing data
-	for _, id := range whitelistID {
-		if _, exists := chatRegistry[id]; !exists {
-			chatRegistry[id] = &ChatInfo{
-				ID:      id,
-				Type:    "private", // Default assumption for legacy entries
-				Title:   fmt.Sprintf("Legacy Chat %d", id),
-				AddedAt: time.Now(),
-				Active:  true,
-			}
-		}
+// OpenChatStore creates the chat store selected by kind: "file" (JSON file at
+// filePath), "bolt" (bbolt database at dbPath) or "memory".
+func OpenChatStore(kind, filePath, dbPath string) (ChatStore, error) {
+	switch kind {
+	case "", "file":
+		return NewFileChatStore(filePath)
+	case "bolt":
+		return NewBoltChatStore(dbPath)
+	case "memory":
+		return NewMemoryChatStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown chat store %q", kind)
 	}
 }
 
-// LoadWhitelist returns the list of whitelisted chat IDs.
-// In-memory storage is used, so no errors are expected.
-func LoadWhitelist() ([]int64, error) {
+// currentChatStore returns the active chat store.
+func currentChatStore() ChatStore {
 	wlMu.RLock()
-	ids := append([]int64(nil), whitelistID...)
-	wlMu.RUnlock()
-	return ids, nil
+	defer wlMu.RUnlock()
+	return chatStore
+}
+
+// ListChats returns all registered chats ordered by the time they were added.
+func ListChats() ([]ChatInfo, error) {
+	return currentChatStore().ListChats()
+}
+
+// LoadWhitelist returns the IDs of all active chats in the order they were added.
+func LoadWhitelist() ([]int64, error) {
+	return GetActiveChats()
 }
 
 // AddChatToWhitelist adds a chat with full information to the whitelist
@@ -67,67 +110,74 @@ func AddChatToWhitelist(chat *tb.Chat) error {
 	title := getChatTitle(chat)
 	username := getChatUsername(chat)
 
-	// Check if chat already exists
-	if existing, exists := chatRegistry[chat.ID]; exists {
+	existing, exists, err := chatStore.GetChat(chat.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
 		// Update existing chat info
 		existing.Type = chatType
 		existing.Title = title
 		existing.Username = username
 		existing.Active = true
+		existing.Status = ChatApproved
+		if err := chatStore.SaveChat(existing); err != nil {
+			return err
+		}
 		logger.L.Info("chat updated", "id", chat.ID, "type", chatType, "title", title)
 		return nil
 	}
 
 	// Add new chat
-	chatInfo := &ChatInfo{
-		ID:       chat.ID,
-		Type:     chatType,
-		Title:    title,
-		Username: username,
-		AddedAt:  time.Now(),
-		Active:   true,
+	chatInfo := ChatInfo{
+		ID:            chat.ID,
+		Type:          chatType,
+		Title:         title,
+		Username:      username,
+		AddedAt:       time.Now(),
+		Active:        true,
+		Subscriptions: DefaultSubscriptions(),
+		Status:        ChatApproved,
 	}
-
-	chatRegistry[chat.ID] = chatInfo
-
-	// Legacy support - add to old whitelist array
-	for _, v := range whitelistID {
-		if v == chat.ID {
-			logger.L.Info("chat added to registry", "id", chat.ID, "type", chatType, "title", title)
-			return nil
-		}
+	if err := chatStore.SaveChat(chatInfo); err != nil {
+		return err
 	}
-	whitelistID = append(whitelistID, chat.ID)
 
 	logger.L.Info("chat added", "id", chat.ID, "type", chatType, "title", title)
 	return nil
 }
 
 // AddIDToWhitelist stores the chat ID if it is not already present (legacy support)
-// Duplicate IDs are ignored silently.
+// Duplicate IDs are ignored silently; a previously removed chat is reactivated.
 func AddIDToWhitelist(id int64) error {
 	wlMu.Lock()
-	for _, v := range whitelistID {
-		if v == id {
-			wlMu.Unlock()
+	defer wlMu.Unlock()
+
+	existing, exists, err := chatStore.GetChat(id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if existing.Active {
 			logger.L.Debug("whitelist exists", "id", id)
 			return nil
 		}
+		existing.Active = true
+		existing.Status = ChatApproved
+		return chatStore.SaveChat(existing)
 	}
-	whitelistID = append(whitelistID, id)
 
-	// Add to new registry if not exists
-	if _, exists := chatRegistry[id]; !exists {
-		chatRegistry[id] = &ChatInfo{
-			ID:      id,
-			Type:    "private", // Default assumption for legacy
-			Title:   fmt.Sprintf("Chat %d", id),
-			AddedAt: time.Now(),
-			Active:  true,
-		}
+	if err := chatStore.SaveChat(ChatInfo{
+		ID:            id,
+		Type:          "private", // Default assumption for legacy
+		Title:         fmt.Sprintf("Chat %d", id),
+		AddedAt:       time.Now(),
+		Active:        true,
+		Subscriptions: DefaultSubscriptions(),
+		Status:        ChatApproved,
+	}); err != nil {
+		return err
 	}
-
-	wlMu.Unlock()
 	logger.L.Debug("whitelist add", "id", id)
 	return nil
 }
@@ -138,10 +188,11 @@ func RemoveIDFromWhitelist(id int64) error {
 	return DeactivateChat(id)
 }
 
-// ResetWhitelist clears the in-memory list. Used in tests.
+// ResetWhitelist replaces the chat store with an empty in-memory one. Used in tests.
 func ResetWhitelist() {
 	wlMu.Lock()
 	whitelistID = nil
+	chatStore = NewMemoryChatStore()
 	wlMu.Unlock()
 }
 
@@ -160,69 +211,94 @@ func FormatWhitelist(ids []int64) string {
 
 // FormatChatList returns a formatted list of all registered chats with details
 func FormatChatList() string {
-	wlMu.RLock()
-	defer wlMu.RUnlock()
+	chats, err := ListChats()
+	if err != nil {
+		logger.L.Error("list chats", "err", err)
+		return "❌ Не удалось загрузить список чатов"
+	}
+
+	if len(chats) == 0 {
+		return "📭 Список чатов пуст"
+	}
 
-	if len(chatRegistry) == 0 {
+	var active, pending, rejected []ChatInfo
+	for _, chat := range chats {
+		switch {
+		case chat.State() == ChatPending:
+			pending = append(pending, chat)
+		case chat.State() == ChatRejected:
+			rejected = append(rejected, chat)
+		case chat.Active:
+			active = append(active, chat)
+		}
+	}
+	if len(active) == 0 && len(pending) == 0 && len(rejected) == 0 {
 		return "📭 Список чатов пуст"
 	}
 
 	var result strings.Builder
 	result.WriteString("📋 Активные чаты:\n\n")
-
-	for _, chat := range chatRegistry {
-		if !chat.Active {
-			continue
+	for _, chat := range active {
+		writeChatEntry(&result, chat)
+	}
+	if len(pending) > 0 {
+		result.WriteString("⏳ Ожидают одобрения:\n\n")
+		for _, chat := range pending {
+			writeChatEntry(&result, chat)
 		}
-
-		var icon string
-		switch chat.Type {
-		case "private":
-			icon = "👤"
-		case "group":
-			icon = "👥"
-		case "supergroup":
-			icon = "🏢"
-		case "channel":
-			icon = "📢"
-		default:
-			icon = "💬"
+	}
+	if len(rejected) > 0 {
+		result.WriteString("🚫 Отклонены:\n\n")
+		for _, chat := range rejected {
+			writeChatEntry(&result, chat)
 		}
+	}
 
-		title := chat.Title
-		if title == "" {
-			title = fmt.Sprintf("Chat %d", chat.ID)
-		}
+	return result.String()
+}
 
-		result.WriteString(fmt.Sprintf("%s %s\n", icon, title))
-		result.WriteString(fmt.Sprintf("   ID: <code>%d</code>\n", chat.ID))
-		result.WriteString(fmt.Sprintf("   Тип: %s\n", getChatTypeRussian(chat.Type)))
-		if chat.Username != "" {
-			result.WriteString(fmt.Sprintf("   @%s\n", chat.Username))
-		}
-		result.WriteString(fmt.Sprintf("   Добавлен: %s\n\n", chat.AddedAt.Format("02.01.2006 15:04")))
+func writeChatEntry(result *strings.Builder, chat ChatInfo) {
+	var icon string
+	switch chat.Type {
+	case "private":
+		icon = "👤"
+	case "group":
+		icon = "👥"
+	case "supergroup":
+		icon = "🏢"
+	case "channel":
+		icon = "📢"
+	default:
+		icon = "💬"
 	}
 
-	return result.String()
+	title := chat.Title
+	if title == "" {
+		title = fmt.Sprintf("Chat %d", chat.ID)
+	}
+
+	result.WriteString(fmt.Sprintf("%s %s\n", icon, title))
+	result.WriteString(fmt.Sprintf("   ID: <code>%d</code>\n", chat.ID))
+	result.WriteString(fmt.Sprintf("   Тип: %s\n", getChatTypeRussian(chat.Type)))
+	if chat.Username != "" {
+		result.WriteString(fmt.Sprintf("   @%s\n", chat.Username))
+	}
+	result.WriteString(fmt.Sprintf("   Добавлен: %s\n\n", chat.AddedAt.Format("02.01.2006 15:04")))
 }
 
 // GetActiveChats returns all active chat IDs for broadcasting
 func GetActiveChats() ([]int64, error) {
-	wlMu.RLock()
-	defer wlMu.RUnlock()
+	chats, err := ListChats()
+	if err != nil {
+		return nil, err
+	}
 
 	var activeIDs []int64
-	for _, chat := range chatRegistry {
+	for _, chat := range chats {
 		if chat.Active {
 			activeIDs = append(activeIDs, chat.ID)
 		}
 	}
-
-	// Fallback to legacy whitelist if registry is empty
-	if len(activeIDs) == 0 {
-		return append([]int64(nil), whitelistID...), nil
-	}
-
 	return activeIDs, nil
 }
 
@@ -279,28 +355,23 @@ func DeactivateChat(id int64) error {
 	wlMu.Lock()
 	defer wlMu.Unlock()
 
-	if chat, exists := chatRegistry[id]; exists {
-		chat.Active = false
-		logger.L.Info("chat deactivated", "id", id, "title", chat.Title)
+	chat, exists, err := chatStore.GetChat(id)
+	if err != nil {
+		return err
 	}
-
-	// Also remove from legacy whitelist
-	out := whitelistID[:0]
-	for _, v := range whitelistID {
-		if v != id {
-			out = append(out, v)
-		}
+	if !exists || !chat.Active {
+		return nil
 	}
-	whitelistID = out
-
+	chat.Active = false
+	if err := chatStore.SaveChat(chat); err != nil {
+		return err
+	}
+	logger.L.Info("chat deactivated", "id", id, "title", chat.Title)
 	return nil
 }
 
 // GetChatStats returns statistics about registered chats
 func GetChatStats() map[string]int {
-	wlMu.RLock()
-	defer wlMu.RUnlock()
-
 	stats := map[string]int{
 		"total":      0,
 		"active":     0,
@@ -308,13 +379,27 @@ func GetChatStats() map[string]int {
 		"group":      0,
 		"supergroup": 0,
 		"channel":    0,
+		"pending":    0,
+		"rejected":   0,
+	}
+
+	chats, err := ListChats()
+	if err != nil {
+		logger.L.Error("list chats", "err", err)
+		return stats
 	}
 
-	for _, chat := range chatRegistry {
+	for _, chat := range chats {
 		stats["total"]++
 		if chat.Active {
 			stats["active"]++
 		}
+		switch chat.State() {
+		case ChatPending:
+			stats["pending"]++
+		case ChatRejected:
+			stats["rejected"]++
+		}
 		stats[chat.Type]++
 	}
 
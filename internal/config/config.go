@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Environment variable names
@@ -20,10 +21,76 @@ const (
 	EnvOpenAIToolChoice      = "OPENAI_TOOL_CHOICE"
 	EnvOpenAIServiceTier     = "OPENAI_SERVICE_TIER"
 	EnvOpenAIReasoningEffort = "OPENAI_REASONING_EFFORT"
+	EnvChatStore             = "CHAT_STORE"
+	EnvWhitelistFile         = "WHITELIST_FILE"
+	EnvChatDBFile            = "CHAT_DB_FILE"
+	EnvDefaultSubscriptions  = "DEFAULT_SUBSCRIPTIONS"
+	EnvTimezone              = "TIMEZONE"
+	EnvAdminIDs              = "ADMIN_IDS"
+	EnvAdminsFile            = "ADMINS_FILE"
+	EnvAccessPolicy          = "ACCESS_POLICY"
+	EnvRequireApproval       = "REQUIRE_APPROVAL"
+	EnvTasksReloadInterval   = "TASKS_RELOAD_INTERVAL"
+	EnvTasksOverlayFile      = "TASKS_OVERLAY_FILE"
+	EnvRunStateFile          = "RUN_STATE_FILE"
+	EnvCatchUpWindow         = "CATCH_UP_WINDOW"
+	EnvHistoryFile           = "HISTORY_FILE"
+	EnvHistoryLimit          = "HISTORY_LIMIT"
+	EnvPromptEnvAllowlist    = "PROMPT_ENV_ALLOWLIST"
+	EnvRunLock               = "RUN_LOCK"
+	EnvRunLockFile           = "RUN_LOCK_FILE"
+	EnvMaxConcurrentJobs     = "MAX_CONCURRENT_JOBS"
+	EnvJobLimitMode          = "JOB_LIMIT_MODE"
+	EnvRemindersFile         = "REMINDERS_FILE"
+	EnvAlertsFile            = "ALERTS_FILE"
+	EnvAlertInterval         = "ALERT_INTERVAL"
+	EnvAlertHysteresis       = "ALERT_HYSTERESIS"
+	EnvSMTPHost              = "SMTP_HOST"
+	EnvSMTPPort              = "SMTP_PORT"
+	EnvSMTPUsername          = "SMTP_USERNAME"
+	EnvSMTPPassword          = "SMTP_PASSWORD"
+	EnvSMTPFrom              = "SMTP_FROM"
 )
 
 const DefaultBlockchainAPI = "https://api.blockchain.info/stats"
 
+// Chat registry defaults
+const (
+	DefaultChatStore     = "file"
+	DefaultWhitelistFile = "whitelist.json"
+	DefaultChatDBFile    = "chats.db"
+	DefaultAdminsFile    = "admins.json"
+	DefaultAccessPolicy  = "whitelist_admins"
+	DefaultTasksOverlay  = "tasks_overlay.json"
+	DefaultRunStateFile  = "run_state.json"
+	DefaultHistoryFile   = "history.json"
+	DefaultHistoryLimit  = 200
+	DefaultPromptEnv     = "EXCHANGE_API,CHART_PATH"
+	DefaultRunLock       = "file"
+	DefaultRunLockFile   = "bot.lock"
+	DefaultMaxJobs       = 3
+	DefaultJobLimitMode  = "queue"
+	DefaultRemindersFile = "reminders.json"
+	DefaultAlertsFile    = "alerts.json"
+	DefaultSMTPPort      = 587
+)
+
+// DefaultAlertInterval is how often BLOCKCHAIN_API is polled for /alert.
+const DefaultAlertInterval = 5 * time.Minute
+
+// DefaultAlertHysteresis is how far, in percent of the threshold, a value has
+// to move back before an alert can fire again.
+const DefaultAlertHysteresis = 1.0
+
+// DefaultTasksReloadInterval is how often the tasks file is polled for changes.
+const DefaultTasksReloadInterval = 30 * time.Second
+
+// DefaultCatchUpWindow is how far back missed runs are caught up on startup.
+const DefaultCatchUpWindow = time.Hour
+
+// DefaultTimezone is the scheduler timezone used when TIMEZONE is unset.
+const DefaultTimezone = "Europe/Moscow"
+
 // Config holds environment configuration values.
 type Config struct {
 	TelegramToken         string
@@ -37,6 +104,35 @@ type Config struct {
 	OpenAIToolChoice      string
 	OpenAIServiceTier     string
 	OpenAIReasoningEffort string
+	ChatStore             string // "file", "bolt" or "memory"
+	WhitelistFile         string
+	ChatDBFile            string
+	DefaultSubscriptions  []string      // Subscriptions given to new chats; "*" means everything
+	Timezone              string        // IANA timezone of the scheduler
+	AdminIDs              []int64       // Bootstrap admins (Telegram user IDs)
+	AdminsFile            string        // Where admins promoted with /promote are stored
+	AccessPolicy          string        // "open", "whitelist" or "whitelist_admins"
+	RequireApproval       bool          // New chats wait for admin approval after /start
+	TasksReloadInterval   time.Duration // How often the tasks file is checked for changes; 0 disables
+	TasksOverlayFile      string        // Tasks created or edited from Telegram
+	RunStateFile          string        // Last successful run of every task slot
+	CatchUpWindow         time.Duration // Missed runs within this window are run on startup; 0 disables
+	HistoryFile           string        // Persistent history of task runs
+	HistoryLimit          int           // Number of task runs kept in the history
+	PromptEnvAllowlist    []string      // Environment variables prompt templates may read
+	RunLock               string        // "file" or "none"; elects the replica that runs the bot
+	RunLockFile           string        // Lock file shared by replicas on one host
+	MaxConcurrentJobs     int           // Scheduled runs executing at once; 0 means no limit
+	JobLimitMode          string        // "queue" or "skip" when MaxConcurrentJobs is reached
+	RemindersFile         string        // Pending /remind reminders
+	AlertsFile            string        // /alert thresholds per chat
+	AlertInterval         time.Duration // How often alerts are checked; 0 disables the poller
+	AlertHysteresis       float64       // Percent of the threshold a value must move back to re-arm an alert
+	SMTPHost              string        // Mail server of email sinks; empty disables them
+	SMTPPort              int           // Mail server port
+	SMTPUsername          string        // Optional SMTP login
+	SMTPPassword          string        // Optional SMTP password
+	SMTPFrom              string        // Sender address of email sinks
 }
 
 // Load reads environment variables and validates them.
@@ -57,6 +153,29 @@ func Load() (Config, error) {
 	toolChoice := os.Getenv(EnvOpenAIToolChoice)
 	serviceTier := os.Getenv(EnvOpenAIServiceTier)
 	reasoningEffort := os.Getenv(EnvOpenAIReasoningEffort)
+	chatStore := envOr(EnvChatStore, DefaultChatStore)
+	whitelistFile := envOr(EnvWhitelistFile, DefaultWhitelistFile)
+	chatDBFile := envOr(EnvChatDBFile, DefaultChatDBFile)
+	defaultSubs := splitList(envOr(EnvDefaultSubscriptions, "*"))
+	timezone := envOr(EnvTimezone, DefaultTimezone)
+	adminsFile := envOr(EnvAdminsFile, DefaultAdminsFile)
+	accessPolicy := envOr(EnvAccessPolicy, DefaultAccessPolicy)
+	requireApprovalStr := os.Getenv(EnvRequireApproval)
+	tasksOverlayFile := envOr(EnvTasksOverlayFile, DefaultTasksOverlay)
+	runStateFile := envOr(EnvRunStateFile, DefaultRunStateFile)
+	historyFile := envOr(EnvHistoryFile, DefaultHistoryFile)
+	historyLimitStr := os.Getenv(EnvHistoryLimit)
+	catchUpWindowStr := envOr(EnvCatchUpWindow, DefaultCatchUpWindow.String())
+	reloadIntervalStr := envOr(EnvTasksReloadInterval, DefaultTasksReloadInterval.String())
+	runLock := envOr(EnvRunLock, DefaultRunLock)
+	runLockFile := envOr(EnvRunLockFile, DefaultRunLockFile)
+	maxJobsStr := os.Getenv(EnvMaxConcurrentJobs)
+	jobLimitMode := envOr(EnvJobLimitMode, DefaultJobLimitMode)
+	remindersFile := envOr(EnvRemindersFile, DefaultRemindersFile)
+	alertsFile := envOr(EnvAlertsFile, DefaultAlertsFile)
+	alertIntervalStr := envOr(EnvAlertInterval, DefaultAlertInterval.String())
+	alertHysteresisStr := os.Getenv(EnvAlertHysteresis)
+	smtpPortStr := os.Getenv(EnvSMTPPort)
 
 	if telegramToken == "" || openaiKey == "" {
 		return cfg, fmt.Errorf("missing required env vars")
@@ -94,6 +213,95 @@ func Load() (Config, error) {
 	if enableWebSearchStr != "" {
 		enableWebSearch = enableWebSearchStr == "1" || strings.ToLower(enableWebSearchStr) == "true"
 	}
+	requireApproval := requireApprovalStr == "1" || strings.ToLower(requireApprovalStr) == "true"
+
+	var adminIDs []int64
+	for _, item := range splitList(os.Getenv(EnvAdminIDs)) {
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid ADMIN_IDS: %w", err)
+		}
+		adminIDs = append(adminIDs, id)
+	}
+
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return cfg, fmt.Errorf("invalid TIMEZONE: %w", err)
+	}
+
+	reloadInterval, err := time.ParseDuration(reloadIntervalStr)
+	if err != nil || reloadInterval < 0 {
+		return cfg, fmt.Errorf("invalid TASKS_RELOAD_INTERVAL: %q", reloadIntervalStr)
+	}
+
+	catchUpWindow, err := time.ParseDuration(catchUpWindowStr)
+	if err != nil || catchUpWindow < 0 {
+		return cfg, fmt.Errorf("invalid CATCH_UP_WINDOW: %q", catchUpWindowStr)
+	}
+
+	alertInterval, err := time.ParseDuration(alertIntervalStr)
+	if err != nil || alertInterval < 0 {
+		return cfg, fmt.Errorf("invalid ALERT_INTERVAL: %q", alertIntervalStr)
+	}
+
+	alertHysteresis := DefaultAlertHysteresis
+	if alertHysteresisStr != "" {
+		v, err := strconv.ParseFloat(alertHysteresisStr, 64)
+		if err != nil || v < 0 || v >= 100 {
+			return cfg, fmt.Errorf("invalid ALERT_HYSTERESIS: %q (want a percentage from 0 to 100)", alertHysteresisStr)
+		}
+		alertHysteresis = v
+	}
+
+	smtpPort := DefaultSMTPPort
+	if smtpPortStr != "" {
+		v, err := strconv.Atoi(smtpPortStr)
+		if err != nil || v <= 0 || v > 65535 {
+			return cfg, fmt.Errorf("invalid SMTP_PORT: %q", smtpPortStr)
+		}
+		smtpPort = v
+	}
+
+	historyLimit := DefaultHistoryLimit
+	if historyLimitStr != "" {
+		v, err := strconv.Atoi(historyLimitStr)
+		if err != nil || v <= 0 {
+			return cfg, fmt.Errorf("invalid HISTORY_LIMIT: %q", historyLimitStr)
+		}
+		historyLimit = v
+	}
+
+	switch accessPolicy {
+	case "open", "whitelist", "whitelist_admins":
+	default:
+		return cfg, fmt.Errorf("invalid ACCESS_POLICY: %q (want open, whitelist or whitelist_admins)", accessPolicy)
+	}
+
+	switch chatStore {
+	case "file", "bolt", "memory":
+	default:
+		return cfg, fmt.Errorf("invalid CHAT_STORE: %q (want file, bolt or memory)", chatStore)
+	}
+
+	switch runLock {
+	case "file", "none":
+	default:
+		return cfg, fmt.Errorf("invalid RUN_LOCK: %q (want file or none)", runLock)
+	}
+
+	switch jobLimitMode {
+	case "queue", "skip":
+	default:
+		return cfg, fmt.Errorf("invalid JOB_LIMIT_MODE: %q (want queue or skip)", jobLimitMode)
+	}
+
+	maxJobs := DefaultMaxJobs
+	if maxJobsStr != "" {
+		v, err := strconv.Atoi(maxJobsStr)
+		if err != nil || v < 0 {
+			return cfg, fmt.Errorf("invalid MAX_CONCURRENT_JOBS: %q", maxJobsStr)
+		}
+		maxJobs = v
+	}
 
 	if toolChoice == "" {
 		toolChoice = "auto"
@@ -118,11 +326,59 @@ func Load() (Config, error) {
 		OpenAIToolChoice:      toolChoice,
 		OpenAIServiceTier:     serviceTier,
 		OpenAIReasoningEffort: reasoningEffort,
+		ChatStore:             chatStore,
+		WhitelistFile:         whitelistFile,
+		ChatDBFile:            chatDBFile,
+		DefaultSubscriptions:  defaultSubs,
+		Timezone:              timezone,
+		AdminIDs:              adminIDs,
+		AdminsFile:            adminsFile,
+		AccessPolicy:          accessPolicy,
+		RequireApproval:       requireApproval,
+		TasksReloadInterval:   reloadInterval,
+		TasksOverlayFile:      tasksOverlayFile,
+		RunStateFile:          runStateFile,
+		CatchUpWindow:         catchUpWindow,
+		HistoryFile:           historyFile,
+		HistoryLimit:          historyLimit,
+		PromptEnvAllowlist:    splitList(envOr(EnvPromptEnvAllowlist, DefaultPromptEnv)),
+		RunLock:               runLock,
+		RunLockFile:           runLockFile,
+		MaxConcurrentJobs:     maxJobs,
+		JobLimitMode:          jobLimitMode,
+		RemindersFile:         remindersFile,
+		AlertsFile:            alertsFile,
+		AlertInterval:         alertInterval,
+		AlertHysteresis:       alertHysteresis,
+		SMTPHost:              os.Getenv(EnvSMTPHost),
+		SMTPPort:              smtpPort,
+		SMTPUsername:          os.Getenv(EnvSMTPUsername),
+		SMTPPassword:          os.Getenv(EnvSMTPPassword),
+		SMTPFrom:              os.Getenv(EnvSMTPFrom),
 	}
 
 	return cfg, nil
 }
 
+// envOr returns the environment variable value or def if it is unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// splitList splits a comma separated value into trimmed, non-empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 // validateTelegramChatID validates that the chat ID is within Telegram's valid range
 func validateTelegramChatID(chatID int64) error {
 	// Telegram chat IDs are typically:
@@ -58,6 +58,7 @@ const (
 	DigestServiceName = "digest_service"
 	DigestHandlerName = "digest_handler"
 	ErrorHandlerName  = "error_handler"
+	ChatScoperName    = "chat_scoper"
 	AIClientName      = "ai_client"
 )
 
@@ -132,7 +133,16 @@ func (sb *ServiceBuilder) BuildDigestHandler() (*handlers.DigestHandler, error)
 		return nil, ErrInvalidServiceType{ServiceName: ErrorHandlerName, ExpectedType: "handlers.ErrorHandler"}
 	}
 
-	digestHandler := handlers.NewDigestHandler(digestServiceTyped, errorHandlerTyped)
+	chatScoper, exists := sb.container.GetService(ChatScoperName)
+	if !exists {
+		return nil, ErrServiceNotFound{ServiceName: ChatScoperName}
+	}
+	chatScoperTyped, ok := chatScoper.(handlers.ChatScoper)
+	if !ok {
+		return nil, ErrInvalidServiceType{ServiceName: ChatScoperName, ExpectedType: "handlers.ChatScoper"}
+	}
+
+	digestHandler := handlers.NewDigestHandler(digestServiceTyped, errorHandlerTyped, chatScoperTyped)
 	sb.container.RegisterService(DigestHandlerName, digestHandler)
 
 	return digestHandler, nil
@@ -18,6 +18,7 @@ type DigestConfig struct {
 	Type        DigestType
 	Name        string
 	CommandName string
+	Placeholder string // Name used to reference the prompt from tasks.yml, e.g. {CryptoDigestPrompt}
 	Prompt      string
 }
 
@@ -28,42 +29,49 @@ func GetDigestConfigs() map[DigestType]DigestConfig {
 			Type:        CryptoDigest,
 			Name:        "Криптовалютный дайджест",
 			CommandName: "crypto",
+			Placeholder: "CryptoDigestPrompt",
 			Prompt:      getCryptoDigestPrompt(),
 		},
 		TechDigest: {
 			Type:        TechDigest,
 			Name:        "Технологический дайджест",
 			CommandName: "tech",
+			Placeholder: "TechDigestPrompt",
 			Prompt:      getTechDigestPrompt(),
 		},
 		RealEstateDigest: {
 			Type:        RealEstateDigest,
 			Name:        "Дайджест недвижимости",
 			CommandName: "realestate",
+			Placeholder: "RealEstateDigestPrompt",
 			Prompt:      getRealEstateDigestPrompt(),
 		},
 		BusinessDigest: {
 			Type:        BusinessDigest,
 			Name:        "Бизнес-дайджест",
 			CommandName: "business",
+			Placeholder: "BusinessDigestPrompt",
 			Prompt:      getBusinessDigestPrompt(),
 		},
 		InvestmentDigest: {
 			Type:        InvestmentDigest,
 			Name:        "Инвестиционный дайджест",
 			CommandName: "investment",
+			Placeholder: "InvestmentDigestPrompt",
 			Prompt:      getInvestmentDigestPrompt(),
 		},
 		StartupDigest: {
 			Type:        StartupDigest,
 			Name:        "Стартап-дайджест",
 			CommandName: "startup",
+			Placeholder: "StartupDigestPrompt",
 			Prompt:      getStartupDigestPrompt(),
 		},
 		GlobalDigest: {
 			Type:        GlobalDigest,
 			Name:        "Глобальный дайджест",
 			CommandName: "global",
+			Placeholder: "GlobalDigestPrompt",
 			Prompt:      getGlobalDigestPrompt(),
 		},
 	}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"context"
+	"time"
 
 	"telegram-reminder/internal/domain"
 	"telegram-reminder/internal/logger"
+	"telegram-reminder/internal/prompt"
 	"telegram-reminder/internal/services"
 
 	tb "gopkg.in/telebot.v3"
@@ -14,13 +16,15 @@ import (
 type DigestHandler struct {
 	digestService *services.DigestService
 	errorHandler  ErrorHandler
+	chatScoper    ChatScoper
 }
 
 // NewDigestHandler creates a new digest handler
-func NewDigestHandler(digestService *services.DigestService, errorHandler ErrorHandler) *DigestHandler {
+func NewDigestHandler(digestService *services.DigestService, errorHandler ErrorHandler, chatScoper ChatScoper) *DigestHandler {
 	return &DigestHandler{
 		digestService: digestService,
 		errorHandler:  errorHandler,
+		chatScoper:    chatScoper,
 	}
 }
 
@@ -29,6 +33,12 @@ type ErrorHandler interface {
 	HandleOpenAIError(err error, model string) string
 }
 
+// ChatScoper describes a chat for prompt templates: its {chat_*} values and
+// the timezone of dates, nil for the default one.
+type ChatScoper interface {
+	ChatScope(chat *tb.Chat) (prompt.Chat, *time.Location)
+}
+
 // TelegramSender defines interface for sending Telegram messages
 type TelegramSender interface {
 	Send(what interface{}, opts ...interface{}) error
@@ -43,10 +53,13 @@ func (h *DigestHandler) HandleDigest(digestType domain.DigestType) func(tb.Conte
 		model := getCurrentModel()
 
 		// Generate digest
+		chat, loc := h.chatScoper.ChatScope(c.Chat())
 		req := services.DigestRequest{
-			Type:   digestType,
-			Model:  model,
-			ChatID: c.Chat().ID,
+			Type:     digestType,
+			Model:    model,
+			ChatID:   c.Chat().ID,
+			Chat:     chat,
+			Location: loc,
 		}
 
 		resp, err := h.digestService.GenerateDigest(context.Background(), req)
@@ -0,0 +1,216 @@
+// Package prompt renders prompt templates shared by scheduled tasks and
+// digests. Templates use text/template syntax; the legacy {name} placeholders
+// keep working and are translated before rendering.
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Chat describes the chat a prompt is rendered for. Type is the Telegram chat
+// type: private, group, supergroup or channel.
+type Chat struct {
+	ID    int64
+	Title string
+	Type  string
+}
+
+// Data holds the values available to a prompt.
+type Data struct {
+	// Now is the render time; zero means time.Now().
+	Now time.Time
+	// Location is the timezone of dates in the prompt; nil means the default
+	// location.
+	Location *time.Location
+	Model    string
+	Task     string
+	Chat     Chat
+	// Vars are extra values available as {name} and {{var "name"}}.
+	Vars map[string]string
+	// Partials are prompt fragments pasted in place of {name} before the
+	// template is parsed, so they may use template syntax themselves.
+	Partials map[string]string
+}
+
+// DefaultEnvAllowlist lists the environment variables readable by env when no
+// allowlist is configured.
+var DefaultEnvAllowlist = []string{"EXCHANGE_API", "CHART_PATH"}
+
+var (
+	mu              sync.RWMutex
+	defaultLocation = time.Local
+	envAllowlist    = allowSet(DefaultEnvAllowlist)
+)
+
+// SetDefaultLocation sets the timezone used when Data.Location is nil.
+func SetDefaultLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.Local
+	}
+	mu.Lock()
+	defaultLocation = loc
+	mu.Unlock()
+}
+
+// SetEnvAllowlist sets the environment variables templates may read with env.
+func SetEnvAllowlist(names []string) {
+	mu.Lock()
+	envAllowlist = allowSet(names)
+	mu.Unlock()
+}
+
+func allowSet(names []string) map[string]bool {
+	set := map[string]bool{}
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			set[n] = true
+		}
+	}
+	return set
+}
+
+// legacyRx matches template actions, which are kept, and legacy {name}
+// placeholders.
+var legacyRx = regexp.MustCompile(`(?s)\{\{.*?\}\}|\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Builtins are the legacy placeholder names always available.
+var Builtins = []string{"date", "time", "weekday", "model", "task"}
+
+// Placeholders returns the distinct legacy {name} placeholders in text,
+// sorted. Placeholders inside template actions are ignored.
+func Placeholders(text string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range legacyRx.FindAllStringSubmatch(text, -1) {
+		if m[1] != "" && !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// weekdays are Russian weekday names indexed by time.Weekday.
+var weekdays = [...]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"}
+
+// months are Russian month names in the genitive case, as used in dates.
+var months = [...]string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+// view is the dot of a prompt template.
+type view struct {
+	Now       time.Time
+	Date      string
+	Time      string
+	Weekday   string
+	IsWeekend bool
+	Model     string
+	Task      string
+	Chat      Chat
+	Vars      map[string]string
+}
+
+// Render expands partials, translates legacy placeholders and executes the
+// template. Unknown legacy placeholders are left as is.
+//
+// Besides the fields of the dot (.Date, .Time, .Weekday, .IsWeekend, .Model,
+// .Task, .Chat.Title, .Chat.Type, .Vars, .Now) templates can call:
+//
+//	weekday t   Russian weekday name of t
+//	month t     Russian month name of t in the genitive case
+//	ruDate t    t as "17 октября 2026"
+//	var name    value of a variable or built-in placeholder
+//	env NAME    environment variable from the allowlist
+func Render(text string, data Data) (string, error) {
+	mu.RLock()
+	loc, allowed := defaultLocation, envAllowlist
+	mu.RUnlock()
+	if data.Location != nil {
+		loc = data.Location
+	}
+	now := data.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.In(loc)
+
+	v := view{
+		Now:       now,
+		Date:      now.Format("2006-01-02"),
+		Time:      now.Format("15:04"),
+		Weekday:   weekdays[now.Weekday()],
+		IsWeekend: now.Weekday() == time.Saturday || now.Weekday() == time.Sunday,
+		Model:     data.Model,
+		Task:      data.Task,
+		Chat:      data.Chat,
+		Vars:      map[string]string{},
+	}
+	for k, val := range data.Vars {
+		v.Vars[k] = val
+	}
+	vars := map[string]string{"date": v.Date, "time": v.Time, "weekday": v.Weekday, "model": v.Model, "task": v.Task}
+	for k, val := range data.Vars {
+		vars[k] = val
+	}
+
+	text = replaceLegacy(text, func(name, m string) string {
+		if p, ok := data.Partials[name]; ok {
+			return p
+		}
+		return m
+	})
+	text = replaceLegacy(text, func(name, m string) string {
+		if _, ok := vars[name]; ok {
+			return fmt.Sprintf("{{var %q}}", name)
+		}
+		return m
+	})
+
+	funcs := template.FuncMap{
+		"weekday": func(t time.Time) string { return weekdays[t.Weekday()] },
+		"month":   func(t time.Time) string { return months[t.Month()-1] },
+		"ruDate": func(t time.Time) string {
+			return fmt.Sprintf("%d %s %d", t.Day(), months[t.Month()-1], t.Year())
+		},
+		"var": func(name string) (string, error) {
+			val, ok := vars[name]
+			if !ok {
+				return "", fmt.Errorf("unknown variable %q", name)
+			}
+			return val, nil
+		},
+		"env": func(name string) (string, error) {
+			if !allowed[name] {
+				return "", fmt.Errorf("environment variable %s is not allowed", name)
+			}
+			return os.Getenv(name), nil
+		},
+	}
+	tmpl, err := template.New("prompt").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, v); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// replaceLegacy calls fn for every legacy placeholder in text with its name
+// and the matched text, leaving template actions untouched.
+func replaceLegacy(text string, fn func(name, m string) string) string {
+	return legacyRx.ReplaceAllStringFunc(text, func(m string) string {
+		if strings.HasPrefix(m, "{{") {
+			return m
+		}
+		return fn(m[1:len(m)-1], m)
+	})
+}
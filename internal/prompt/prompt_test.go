@@ -0,0 +1,94 @@
+package prompt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	msk, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("tzdata: %v", err)
+	}
+	// 22:30 UTC on Saturday is already Sunday in Moscow.
+	now := time.Date(2024, 3, 9, 22, 30, 0, 0, time.UTC)
+	data := Data{
+		Now:      now,
+		Location: msk,
+		Model:    "gpt-4.1",
+		Task:     "brief",
+		Chat:     Chat{ID: -100, Title: "Команда", Type: "supergroup"},
+		Vars:     map[string]string{"exchange_api": "https://rates"},
+		Partials: map[string]string{"base_prompt": "База на {date}. {{if .IsWeekend}}Выходной.{{end}}"},
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"legacy", "{date} {model} {exchange_api}", "2024-03-10 gpt-4.1 https://rates"},
+		{"unknown legacy kept", `{unknown} {"json": 1}`, `{unknown} {"json": 1}`},
+		{"partial", "{base_prompt}", "База на 2024-03-10. Выходной."},
+		{"fields", "{{.Date}} {{.Time}} {{.Weekday}} {{.Task}}", "2024-03-10 01:30 воскресенье brief"},
+		{"russian dates", "{{ruDate .Now}}, {{weekday (.Now.AddDate 0 0 1)}}, {{month .Now}}", "10 марта 2024, понедельник, марта"},
+		{"chat", `{{if eq .Chat.Type "private"}}лично{{else}}для «{{.Chat.Title}}»{{end}}`, "для «Команда»"},
+		{"var", `{{var "exchange_api"}}`, "https://rates"},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.text, data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderDefaultLocation(t *testing.T) {
+	msk, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("tzdata: %v", err)
+	}
+	SetDefaultLocation(msk)
+	t.Cleanup(func() { SetDefaultLocation(nil) })
+
+	got, err := Render("{date}", Data{Now: time.Date(2024, 3, 9, 22, 30, 0, 0, time.UTC)})
+	if err != nil || got != "2024-03-10" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestRenderEnvAllowlist(t *testing.T) {
+	t.Setenv("CHART_PATH", "/tmp/chart.png")
+	t.Setenv("OPENAI_API_KEY", "secret")
+	SetEnvAllowlist([]string{"CHART_PATH"})
+	t.Cleanup(func() { SetEnvAllowlist(DefaultEnvAllowlist) })
+
+	if got, err := Render(`{{env "CHART_PATH"}}`, Data{}); err != nil || got != "/tmp/chart.png" {
+		t.Errorf("allowed env: got %q, %v", got, err)
+	}
+	if _, err := Render(`{{env "OPENAI_API_KEY"}}`, Data{}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected disallowed env error, got %v", err)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	for _, text := range []string{"{{if .Date}}", `{{var "nope"}}`, "{{.Nope}}"} {
+		if _, err := Render(text, Data{}); err == nil {
+			t.Errorf("%q: expected error", text)
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	got := Placeholders("{date} {{.Date}} {{if true}}{x}{{end}} {date} {CryptoDigestPrompt} {output:lots}")
+	want := []string{"CryptoDigestPrompt", "date", "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
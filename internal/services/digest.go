@@ -6,6 +6,7 @@ import (
 
 	"telegram-reminder/internal/domain"
 	"telegram-reminder/internal/logger"
+	"telegram-reminder/internal/prompt"
 )
 
 // AIClient defines the interface for AI completion services
@@ -32,6 +33,8 @@ type DigestRequest struct {
 	Type         domain.DigestType
 	Model        string
 	ChatID       int64
+	Chat         prompt.Chat
+	Location     *time.Location // timezone of dates in the prompt; nil for the default
 	TemplateName string
 }
 
@@ -57,11 +60,15 @@ func (s *DigestService) GenerateDigest(ctx context.Context, req DigestRequest) (
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	// Apply template to prompt
-	prompt := s.applyTemplate(config.Prompt, req.Model, req.TemplateName)
+	// Render the prompt template
+	text, err := prompt.Render(config.Prompt, prompt.Data{Model: req.Model, Task: string(req.Type), Chat: req.Chat, Location: req.Location})
+	if err != nil {
+		logger.L.Error("digest prompt render failed", "type", req.Type, "error", err)
+		return nil, err
+	}
 
 	// Generate completion
-	content, err := s.generateCompletion(ctx, prompt, req.Model)
+	content, err := s.generateCompletion(ctx, text, req.Model)
 	if err != nil {
 		logger.L.Error("digest generation failed", "type", req.Type, "model", req.Model, "error", err)
 		return &DigestResponse{
@@ -88,49 +95,6 @@ func (s *DigestService) generateCompletion(ctx context.Context, prompt, model st
 	return s.aiClient.EnhancedSystemCompletion(ctx, prompt, model)
 }
 
-// applyTemplate applies template variables to the prompt
-func (s *DigestService) applyTemplate(prompt, model, templateName string) string {
-	// Apply template substitutions
-	vars := map[string]string{
-		"date":  time.Now().Format("2006-01-02"),
-		"model": model,
-	}
-
-	result := prompt
-	for key, value := range vars {
-		// Simple string replacement - in production, use a proper template engine
-		result = replaceAll(result, "{"+key+"}", value)
-	}
-
-	return result
-}
-
-// Simple string replacement function
-func replaceAll(s, old, new string) string {
-	for {
-		replaced := replace(s, old, new)
-		if replaced == s {
-			break
-		}
-		s = replaced
-	}
-	return s
-}
-
-func replace(s, old, new string) string {
-	// Simple implementation - in production use strings.ReplaceAll
-	if len(old) == 0 {
-		return s
-	}
-
-	for i := 0; i <= len(s)-len(old); i++ {
-		if s[i:i+len(old)] == old {
-			return s[:i] + new + s[i+len(old):]
-		}
-	}
-	return s
-}
-
 // Errors
 var (
 	ErrUnknownDigestType = NewServiceError("unknown digest type")
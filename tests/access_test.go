@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestAccessMiddleware(t *testing.T) {
+	botpkg.ResetWhitelist()
+	botpkg.ResetAdmins()
+	t.Cleanup(func() {
+		botpkg.ResetWhitelist()
+		botpkg.ResetAdmins()
+		botpkg.SetAccessPolicy(botpkg.AccessOpen)
+	})
+	botpkg.SetBootstrapAdmins([]int64{1})
+	if err := botpkg.AddIDToWhitelist(-100); err != nil {
+		t.Fatalf("whitelist: %v", err)
+	}
+
+	mw := botpkg.AccessMiddleware()
+	cases := []struct {
+		policy  botpkg.AccessPolicy
+		user    int64
+		chat    *tb.Chat
+		allowed bool
+	}{
+		{botpkg.AccessOpen, 2, &tb.Chat{ID: -200, Type: tb.ChatGroup}, true},
+		{botpkg.AccessWhitelist, 2, &tb.Chat{ID: -100, Type: tb.ChatGroup}, true},
+		{botpkg.AccessWhitelist, 2, &tb.Chat{ID: -200, Type: tb.ChatGroup}, false},
+		{botpkg.AccessWhitelist, 1, &tb.Chat{ID: 1, Type: tb.ChatPrivate}, false},
+		{botpkg.AccessWhitelistAdmins, 1, &tb.Chat{ID: 1, Type: tb.ChatPrivate}, true},
+		{botpkg.AccessWhitelistAdmins, 1, &tb.Chat{ID: -200, Type: tb.ChatGroup}, false},
+		{botpkg.AccessWhitelistAdmins, 2, &tb.Chat{ID: 2, Type: tb.ChatPrivate}, false},
+	}
+	for _, tc := range cases {
+		botpkg.SetAccessPolicy(tc.policy)
+		called := false
+		h := mw(func(c tb.Context) error { called = true; return nil })
+		ctx := newAdminCtx(tc.user, "/chat hi")
+		ctx.msg.Chat = tc.chat
+		if err := h(ctx); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+		if called != tc.allowed {
+			t.Errorf("%s user %d chat %d: allowed=%v, want %v", tc.policy, tc.user, tc.chat.ID, called, tc.allowed)
+		}
+	}
+}
+
+func TestAccessMiddlewareRefusalRateLimited(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(func() {
+		botpkg.ResetWhitelist()
+		botpkg.SetAccessPolicy(botpkg.AccessOpen)
+	})
+	botpkg.SetAccessPolicy(botpkg.AccessWhitelist)
+
+	h := botpkg.AccessMiddleware()(func(c tb.Context) error { return nil })
+	first := newAdminCtx(5, "/search news")
+	if err := h(first); err != nil || first.sent == nil {
+		t.Fatalf("expected refusal, got %v (err %v)", first.sent, err)
+	}
+	second := newAdminCtx(5, "/search news")
+	if err := h(second); err != nil || second.sent != nil {
+		t.Errorf("expected silent drop, got %v (err %v)", second.sent, err)
+	}
+}
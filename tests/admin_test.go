@@ -0,0 +1,111 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+type adminCtx struct {
+	tb.Context
+	msg  *tb.Message
+	sent interface{}
+}
+
+func (c *adminCtx) Message() *tb.Message   { return c.msg }
+func (c *adminCtx) Sender() *tb.User       { return c.msg.Sender }
+func (c *adminCtx) Chat() *tb.Chat         { return c.msg.Chat }
+func (c *adminCtx) Callback() *tb.Callback { return nil }
+func (c *adminCtx) Send(what interface{}, opts ...interface{}) error {
+	c.sent = what
+	return nil
+}
+
+func newAdminCtx(userID int64, text string) *adminCtx {
+	return &adminCtx{msg: &tb.Message{
+		Text:   text,
+		Sender: &tb.User{ID: userID},
+		Chat:   &tb.Chat{ID: userID, Type: tb.ChatPrivate},
+	}}
+}
+
+func TestPermissionMiddleware(t *testing.T) {
+	botpkg.ResetAdmins()
+	t.Cleanup(botpkg.ResetAdmins)
+	botpkg.SetBootstrapAdmins([]int64{1})
+
+	b, err := tb.NewBot(tb.Settings{Offline: true})
+	if err != nil {
+		t.Fatalf("new bot: %v", err)
+	}
+	b.Use(botpkg.PermissionMiddleware())
+	called := 0
+	handler := func(c tb.Context) error { called++; return c.Send("ok") }
+	b.Handle("/remove", handler)
+	b.Handle("/model", handler)
+	b.Handle("/ping", handler)
+
+	cases := []struct {
+		user    int64
+		cmd     string
+		text    string
+		allowed bool
+	}{
+		{2, "/remove", "/remove 5", false},
+		{2, "/remove", "/remove@billion_bot 5", false},
+		{1, "/remove", "/remove 5", true},
+		{2, "/model", "/model", true},
+		{2, "/model", "/model o3", false},
+		{1, "/model", "/model o3", true},
+		{2, "/ping", "/ping", true},
+		{2, "/model", "/model\no3", false},
+		{2, "/remove", "/remove\t5", false},
+		{2, "/remove", "/remove@billion_bot\n5", false},
+		{1, "/model", "/model\to3", true},
+	}
+	for _, tc := range cases {
+		called = 0
+		ctx := newAdminCtx(tc.user, tc.text)
+		if err := b.Trigger(tc.cmd, ctx); err != nil {
+			t.Fatalf("trigger %q: %v", tc.text, err)
+		}
+		if (called == 1) != tc.allowed {
+			t.Errorf("user %d %q: allowed=%v, want %v (reply %v)", tc.user, tc.text, called == 1, tc.allowed, ctx.sent)
+		}
+	}
+}
+
+func TestPromoteAdminPersists(t *testing.T) {
+	botpkg.ResetAdmins()
+	t.Cleanup(botpkg.ResetAdmins)
+	path := filepath.Join(t.TempDir(), "admins.json")
+
+	if err := botpkg.LoadAdmins(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err := botpkg.PromoteAdmin(7); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+
+	botpkg.ResetAdmins()
+	if err := botpkg.LoadAdmins(path); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if botpkg.RoleOf(7) != botpkg.RoleAdmin {
+		t.Fatalf("promoted admin not persisted: %v", botpkg.RoleOf(7))
+	}
+
+	botpkg.SetBootstrapAdmins([]int64{1})
+	if err := botpkg.DemoteAdmin(1); err == nil {
+		t.Error("expected error demoting a bootstrap admin")
+	}
+	if err := botpkg.DemoteAdmin(7); err != nil {
+		t.Fatalf("demote: %v", err)
+	}
+	if botpkg.IsAdmin(7) {
+		t.Error("user still admin after demote")
+	}
+}
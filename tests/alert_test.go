@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestParseAlert(t *testing.T) {
+	cases := []struct {
+		in     string
+		metric string
+		op     string
+		value  float64
+	}{
+		{"btc > 70000", "btc", ">", 70000},
+		{"BTC>$70,000", "btc", ">", 70000},
+		{"price < 65k", "btc", "<", 65000},
+		{"hashrate < 6e11", "hashrate", "<", 6e11},
+		{"hashrate > 650b", "hashrate", ">", 650e9},
+		{"tx > 400k", "tx", ">", 400000},
+	}
+	for _, tc := range cases {
+		a, err := botpkg.ParseAlert(tc.in)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if a.Metric != tc.metric || a.Op != tc.op || a.Threshold != tc.value {
+			t.Errorf("%q: got %s %s %v", tc.in, a.Metric, a.Op, a.Threshold)
+		}
+	}
+	for _, in := range []string{"", "btc", "btc = 70000", "eth > 3000", "btc > cheap", "btc > -5"} {
+		if _, err := botpkg.ParseAlert(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestAlertHysteresis(t *testing.T) {
+	botpkg.ResetAlerts()
+	t.Cleanup(botpkg.ResetAlerts)
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	if err := botpkg.LoadAlerts(path); err != nil {
+		t.Fatal(err)
+	}
+	botpkg.SetAlertHysteresis(1)
+
+	var mu sync.Mutex
+	var price float64
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, `{"market_price_usd":%f,"n_tx":1,"hash_rate":1}`, price)
+	}))
+	defer api.Close()
+
+	var sent []string
+	tg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			mu.Lock()
+			sent = append(sent, r.URL.Path)
+			mu.Unlock()
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":5}}}`))
+	}))
+	defer tg.Close()
+	b, err := tb.NewBot(tb.Settings{URL: tg.URL, Token: "t", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := botpkg.ParseAlert("btc > 70000")
+	if _, err := botpkg.AddAlert(5, 7, a); err != nil {
+		t.Fatal(err)
+	}
+	// 69900 is back under the threshold but within the 1% margin, so the
+	// alert stays disarmed until the price drops to 69000.
+	steps := []struct {
+		price float64
+		sent  int
+	}{{69000, 0}, {70500, 1}, {69900, 1}, {70600, 1}, {69000, 1}, {71000, 2}, {72000, 2}}
+	for i, st := range steps {
+		mu.Lock()
+		price = st.price
+		mu.Unlock()
+		if err := botpkg.CheckAlerts(context.Background(), b, api.URL); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		n := len(sent)
+		mu.Unlock()
+		if n != st.sent {
+			t.Fatalf("step %d (price %.0f): %d notifications, want %d", i, st.price, n, st.sent)
+		}
+	}
+
+	botpkg.ResetAlerts()
+	if err := botpkg.LoadAlerts(path); err != nil {
+		t.Fatal(err)
+	}
+	list := botpkg.ChatAlerts(5)
+	if len(list) != 1 || list[0].Armed || list[0].Fired.IsZero() {
+		t.Fatalf("alert state not persisted: %+v", list)
+	}
+	if err := botpkg.RemoveAlert(6, list[0].ID); err == nil {
+		t.Error("alert removed from another chat")
+	}
+	if err := botpkg.RemoveAlert(5, list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchBlockchainStatsStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	if _, err := botpkg.FetchBlockchainStats(context.Background(), srv.URL); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestChatApprovalWorkflow(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+
+	chat := &tb.Chat{ID: -300, Type: tb.ChatGroup, Title: "Newcomers"}
+	requested, err := botpkg.RequestChatApproval(chat)
+	if err != nil || !requested {
+		t.Fatalf("request: requested=%v err=%v", requested, err)
+	}
+	if again, _ := botpkg.RequestChatApproval(chat); again {
+		t.Error("pending chat should not be requested twice")
+	}
+	if ids, _ := botpkg.GetActiveChats(); len(ids) != 0 {
+		t.Errorf("pending chat must not receive broadcasts: %v", ids)
+	}
+	if stats := botpkg.GetChatStats(); stats["pending"] != 1 || stats["active"] != 0 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+	if list := botpkg.FormatChatList(); !strings.Contains(list, "Ожидают одобрения") || !strings.Contains(list, "Newcomers") {
+		t.Errorf("pending chat missing from list:\n%s", list)
+	}
+
+	if _, err := botpkg.ApproveChat(-300); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if ids, _ := botpkg.GetActiveChats(); len(ids) != 1 || ids[0] != -300 {
+		t.Errorf("approved chat not active: %v", ids)
+	}
+
+	if _, err := botpkg.RejectChat(-300); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if ids, _ := botpkg.GetActiveChats(); len(ids) != 0 {
+		t.Errorf("rejected chat still active: %v", ids)
+	}
+	if stats := botpkg.GetChatStats(); stats["rejected"] != 1 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+	if list := botpkg.FormatChatList(); !strings.Contains(list, "Отклонены") {
+		t.Errorf("rejected chat missing from list:\n%s", list)
+	}
+}
+
+func TestRejectedChatCannotRequestAgain(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+
+	chat := &tb.Chat{ID: -301, Type: tb.ChatGroup, Title: "Spammers"}
+	if _, err := botpkg.RequestChatApproval(chat); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if _, err := botpkg.RejectChat(-301); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	requested, err := botpkg.RequestChatApproval(chat)
+	if requested || !errors.Is(err, botpkg.ErrChatRejected) {
+		t.Errorf("rejected chat filed a new request: requested=%v err=%v", requested, err)
+	}
+	if stats := botpkg.GetChatStats(); stats["rejected"] != 1 || stats["pending"] != 0 {
+		t.Errorf("rejection not kept: %v", stats)
+	}
+
+	// An admin can still change their mind.
+	if _, err := botpkg.ApproveChat(-301); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if ids, _ := botpkg.GetActiveChats(); len(ids) != 1 || ids[0] != -301 {
+		t.Errorf("approved chat not active: %v", ids)
+	}
+}
+
+func TestApproveUnknownChat(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	if _, err := botpkg.ApproveChat(42); err == nil {
+		t.Error("expected error for unregistered chat")
+	}
+}
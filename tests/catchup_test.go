@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+func writeRunState(t *testing.T, runs map[string]time.Time) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "run_state.json")
+	data, _ := json.Marshal(map[string]interface{}{"last_runs": runs})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := botpkg.LoadRunState(path); err != nil {
+		t.Fatalf("load run state: %v", err)
+	}
+}
+
+func TestFindMissedRuns(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Cleanup(botpkg.ResetRunState)
+	t.Setenv("TASKS_JSON", `[
+		{"name":"land_price","prompt":"p","time":"09:00"},
+		{"name":"quiet","prompt":"p","time":"09:00","catch_up":false},
+		{"name":"fresh","prompt":"p","time":"09:00"}
+	]`)
+	botpkg.ScheduleDailyMessages(gocron.NewScheduler(time.UTC), nil, nil, 0)
+
+	now := time.Date(2024, 3, 10, 9, 5, 0, 0, time.UTC)
+	yesterday := time.Date(2024, 3, 9, 9, 0, 0, 0, time.UTC)
+	writeRunState(t, map[string]time.Time{
+		"land_price@09:00@": yesterday,
+		"quiet@09:00@":      yesterday,
+	})
+
+	missed := botpkg.FindMissedRuns(now, time.Hour)
+	if len(missed) != 1 || missed[0].Task != "land_price" {
+		t.Fatalf("unexpected missed runs: %+v", missed)
+	}
+	if want := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC); !missed[0].Scheduled.Equal(want) {
+		t.Errorf("scheduled = %v, want %v", missed[0].Scheduled, want)
+	}
+
+	if got := botpkg.FindMissedRuns(now, 2*time.Minute); len(got) != 0 {
+		t.Errorf("slot outside the grace window was caught up: %+v", got)
+	}
+
+	writeRunState(t, map[string]time.Time{"land_price@09:00@": now.Add(-5 * time.Minute)})
+	if got := botpkg.FindMissedRuns(now, time.Hour); len(got) != 0 {
+		t.Errorf("slot that already ran was caught up: %+v", got)
+	}
+}
+
+func TestCaughtUpOutputFeedsDependentsUndecorated(t *testing.T) {
+	resetPipelineState(t)
+	t.Cleanup(botpkg.ResetRunState)
+	at := time.Now().UTC().Add(-time.Minute).Format("15:04")
+	t.Setenv("TASKS_JSON", `[
+		{"name":"lots","prompt":"lots","time":"`+at+`"},
+		{"name":"top","prompt":"top of {output:lots}","depends_on":["lots"]}
+	]`)
+	_, _, stop := schedulePipeline(t)
+	defer stop()
+	writeRunState(t, map[string]time.Time{"lots@" + at + "@": time.Now().Add(-48 * time.Hour)})
+
+	botpkg.CatchUpMissedRuns(time.Hour)
+
+	lots := waitForRun(t, "lots")
+	if lots.Output != "out(lots)" {
+		t.Errorf("history keeps the delay note: %q", lots.Output)
+	}
+	if top := waitForRun(t, "top"); top.Output != "out(top of out(lots))" {
+		t.Errorf("dependent got %q", top.Output)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestMigrateChatKeepsSettings(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+
+	if err := botpkg.AddChatToWhitelist(&tb.Chat{ID: -10, Type: tb.ChatGroup, Title: "Team"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := botpkg.UnsubscribeChat(-10, "all"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if _, err := botpkg.SubscribeChat(-10, "crypto"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if err := botpkg.MigrateChat(-10, -10010); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ids, _ := botpkg.GetActiveChats()
+	if len(ids) != 1 || ids[0] != -10010 {
+		t.Fatalf("expected only the supergroup to be active, got %v", ids)
+	}
+	subs, err := botpkg.GetChatSubscriptions(-10010)
+	if err != nil || len(subs) != 1 || subs[0] != "crypto" {
+		t.Errorf("subscriptions not migrated: %v (err %v)", subs, err)
+	}
+	if _, err := botpkg.GetChatSubscriptions(-10); err == nil {
+		t.Error("old chat should be gone")
+	}
+}
+
+func TestMigrateChatMergesIntoExistingEntry(t *testing.T) {
+	botpkg.ResetWhitelist()
+	botpkg.ResetReminders()
+	botpkg.ResetAlerts()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Cleanup(botpkg.ResetReminders)
+	t.Cleanup(botpkg.ResetAlerts)
+
+	store := botpkg.NewMemoryChatStore()
+	if err := store.SaveChat(botpkg.ChatInfo{ID: -10010, Type: "supergroup", Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := botpkg.SetChatStore(store); err != nil {
+		t.Fatal(err)
+	}
+	if err := botpkg.AddChatToWhitelist(&tb.Chat{ID: -10, Type: tb.ChatGroup, Title: "Team"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := botpkg.SetChatTimezone(-10, "Asia/Almaty"); err != nil {
+		t.Fatalf("set tz: %v", err)
+	}
+	if _, err := botpkg.AddReminder(-10, 1, "standup", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("add reminder: %v", err)
+	}
+	a, _ := botpkg.ParseAlert("btc > 100000")
+	if _, err := botpkg.AddAlert(-10, 1, a); err != nil {
+		t.Fatalf("add alert: %v", err)
+	}
+
+	if err := botpkg.MigrateChat(-10, -10010); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	chats, _ := botpkg.ListChats()
+	if len(chats) != 1 || chats[0].ID != -10010 {
+		t.Fatalf("expected only the supergroup entry, got %+v", chats)
+	}
+	if chats[0].Timezone != "Asia/Almaty" || chats[0].Subscriptions == nil || chats[0].Status != botpkg.ChatApproved {
+		t.Errorf("old settings not merged: %+v", chats[0])
+	}
+	if got := botpkg.ChatReminders(-10010); len(got) != 1 || len(botpkg.ChatReminders(-10)) != 0 {
+		t.Errorf("reminders not migrated: %+v", got)
+	}
+	if got := botpkg.ChatAlerts(-10010); len(got) != 1 || len(botpkg.ChatAlerts(-10)) != 0 {
+		t.Errorf("alerts not migrated: %+v", got)
+	}
+}
+
+func TestMigrateUnknownChat(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	if err := botpkg.MigrateChat(1, 2); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClassifyTelegramErrors(t *testing.T) {
+	eh := botpkg.NewErrorHandler()
+	for _, err := range []error{
+		tb.ErrBlockedByUser,
+		tb.ErrKickedFromGroup,
+		tb.ErrKickedFromSuperGroup,
+		fmt.Errorf("send: %w", tb.ErrUserIsDeactivated),
+		tb.ErrChatNotFound,
+	} {
+		if !eh.IsChatUnavailable(err) {
+			t.Errorf("%v should mark chat unavailable", err)
+		}
+	}
+	for _, err := range []error{errors.New("timeout"), tb.ErrTooLarge} {
+		if eh.IsChatUnavailable(err) {
+			t.Errorf("%v should not mark chat unavailable", err)
+		}
+	}
+	if _, ok := eh.MigratedChatID(tb.ErrBlockedByUser); ok {
+		t.Error("blocked error is not a migration")
+	}
+}
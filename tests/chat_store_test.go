@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+)
+
+func TestFileChatStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "whitelist.json")
+
+	store, err := botpkg.NewFileChatStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	chat := botpkg.ChatInfo{ID: -100, Type: "group", Title: "Team", AddedAt: time.Now(), Active: true}
+	if err := store.SaveChat(chat); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reopened, err := botpkg.NewFileChatStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, ok, err := reopened.GetChat(-100)
+	if err != nil || !ok {
+		t.Fatalf("chat not persisted: ok=%v err=%v", ok, err)
+	}
+	if got.Title != "Team" || got.Type != "group" || !got.Active {
+		t.Errorf("unexpected chat: %+v", got)
+	}
+}
+
+func TestFileChatStoreEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "whitelist.json")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	store, err := botpkg.NewFileChatStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	chats, err := store.ListChats()
+	if err != nil || len(chats) != 0 {
+		t.Fatalf("expected empty store, got %v (err %v)", chats, err)
+	}
+}
+
+func TestFileChatStoreLegacyImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "whitelist.json")
+	if err := os.WriteFile(path, []byte("[1, 2]"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	store, err := botpkg.NewFileChatStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	chats, _ := store.ListChats()
+	if len(chats) != 2 || !chats[0].Active {
+		t.Fatalf("legacy ids not imported: %+v", chats)
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) == 0 || data[0] != '{' {
+		t.Errorf("legacy file not rewritten: %s", data)
+	}
+}
+
+func TestBoltChatStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chats.db")
+	store, err := botpkg.NewBoltChatStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := store.SaveChat(botpkg.ChatInfo{ID: 7, Type: "private", Active: true}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	store, err = botpkg.NewBoltChatStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+	if _, ok, _ := store.GetChat(7); !ok {
+		t.Fatal("chat not persisted")
+	}
+	if err := store.DeleteChat(7); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if chats, _ := store.ListChats(); len(chats) != 0 {
+		t.Errorf("expected empty store, got %+v", chats)
+	}
+}
+
+func TestSetChatStoreImportsLegacyWhitelist(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+
+	store, err := botpkg.NewFileChatStore(filepath.Join(t.TempDir(), "whitelist.json"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := botpkg.ImportLegacyWhitelist(store, []int64{5}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if n, err := botpkg.ImportLegacyWhitelist(store, []int64{5, 6}); err != nil || n != 1 {
+		t.Fatalf("expected one new import, got %d (err %v)", n, err)
+	}
+	if err := botpkg.SetChatStore(store); err != nil {
+		t.Fatalf("set store: %v", err)
+	}
+	ids, err := botpkg.GetActiveChats()
+	if err != nil {
+		t.Fatalf("active chats: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("unexpected active chats: %v", ids)
+	}
+}
+
+func TestFileChatStoreConcurrentWhitelist(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+
+	path := filepath.Join(t.TempDir(), "whitelist.json")
+	store, err := botpkg.NewFileChatStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := botpkg.SetChatStore(store); err != nil {
+		t.Fatalf("set store: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			if err := botpkg.AddIDToWhitelist(id); err != nil {
+				t.Errorf("add %d: %v", id, err)
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	reopened, err := botpkg.NewFileChatStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	chats, _ := reopened.ListChats()
+	if len(chats) != 20 {
+		t.Errorf("expected 20 persisted chats, got %d", len(chats))
+	}
+}
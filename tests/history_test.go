@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+func TestLoadHistoryTrimsAndFilters(t *testing.T) {
+	t.Cleanup(botpkg.ResetHistory)
+	base := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	var runs []botpkg.TaskRunRecord
+	for i, name := range []string{"a", "b", "a", "b", "a"} {
+		runs = append(runs, botpkg.TaskRunRecord{
+			Task:     name,
+			Started:  base.Add(time.Duration(i) * time.Hour),
+			Finished: base.Add(time.Duration(i)*time.Hour + time.Second),
+			Status:   botpkg.RunOK,
+			Attempt:  1,
+		})
+	}
+	path := filepath.Join(t.TempDir(), "history.json")
+	data, _ := json.Marshal(map[string]interface{}{"runs": runs})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := botpkg.LoadHistory(path, 3); err != nil {
+		t.Fatalf("load history: %v", err)
+	}
+
+	all := botpkg.TaskHistory("", 10)
+	if len(all) != 3 {
+		t.Fatalf("history not trimmed to the limit: %d runs", len(all))
+	}
+	if !all[0].Started.Equal(runs[4].Started) {
+		t.Errorf("history is not newest first: %+v", all)
+	}
+	if got := botpkg.TaskHistory("a", 10); len(got) != 2 {
+		t.Errorf("expected 2 runs of a, got %d", len(got))
+	}
+	last, ok := botpkg.LastRun("b")
+	if !ok || !last.Started.Equal(runs[3].Started) {
+		t.Errorf("unexpected last run of b: %+v", last)
+	}
+	if _, ok := botpkg.LastRun("missing"); ok {
+		t.Error("last run of an unknown task found")
+	}
+}
+
+func TestScheduledRunsAreRecorded(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	botpkg.ResetHistory()
+	t.Cleanup(botpkg.ResetHistory)
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := botpkg.LoadHistory(path, 0); err != nil {
+		t.Fatalf("load history: %v", err)
+	}
+	t.Setenv("TASKS_JSON", `[{"name":"good","prompt":"p","time":"09:00"},{"name":"bad","prompt":"fail","time":"09:00"}]`)
+
+	client, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		data, _ := json.Marshal(req["messages"])
+		if strings.Contains(string(data), "fail") {
+			http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"answer"}}]}`))
+	})
+	defer srv.Close()
+
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, client, nil, 0)
+	s.StartAsync()
+	defer s.Stop()
+	s.RunAll()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(botpkg.TaskHistory("", 10)) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	good, ok := botpkg.LastRun("good")
+	if !ok || good.Status != botpkg.RunOK || good.Output != "answer" || good.ResponseLength != len("answer") {
+		t.Errorf("unexpected run of good: %+v", good)
+	}
+	bad, ok := botpkg.LastRun("bad")
+	if !ok || bad.Status != botpkg.RunFailed || bad.Error == "" {
+		t.Errorf("unexpected run of bad: %+v", bad)
+	}
+	if line := botpkg.FormatRun(bad); !strings.Contains(line, "❌") || !strings.Contains(line, "bad") {
+		t.Errorf("unexpected history line: %q", line)
+	}
+
+	if err := botpkg.LoadHistory(path, 0); err != nil {
+		t.Fatalf("reload history: %v", err)
+	}
+	if len(botpkg.TaskHistory("", 10)) != 2 {
+		t.Error("history was not persisted")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+// scheduleBlocked schedules three tasks with a client that holds every
+// request until release is closed and reports the peak concurrency.
+func scheduleBlocked(t *testing.T) (peak *int32, release chan struct{}, stop func()) {
+	// The same slots run in every test; forget earlier claims.
+	botpkg.ResetRunLock()
+	t.Setenv("TASKS_JSON", `[{"name":"a","prompt":"p","time":"09:00"},{"name":"b","prompt":"p","time":"09:00"},{"name":"c","prompt":"p","time":"09:00"}]`)
+	peak = new(int32)
+	var active int32
+	release = make(chan struct{})
+	client, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(peak)
+			if n <= p || atomic.CompareAndSwapInt32(peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&active, -1)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"answer"}}]}`))
+	})
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, client, nil, 0)
+	s.StartAsync()
+	s.RunAll()
+	return peak, release, func() {
+		s.Stop()
+		srv.Close()
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobLimitQueue(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	botpkg.ResetHistory()
+	t.Cleanup(botpkg.ResetHistory)
+	botpkg.SetJobLimits(1, botpkg.JobLimitQueue)
+	t.Cleanup(func() { botpkg.SetJobLimits(0, botpkg.JobLimitQueue) })
+
+	peak, release, stop := scheduleBlocked(t)
+	defer stop()
+
+	waitFor(t, "two queued runs", func() bool { return botpkg.QueueDepth() == 2 })
+	if n := botpkg.RunningJobs(); n != 1 {
+		t.Errorf("expected one running job, got %d", n)
+	}
+	close(release)
+	waitFor(t, "all runs", func() bool { return len(botpkg.TaskHistory("", 10)) == 3 })
+	if *peak != 1 {
+		t.Errorf("expected at most one concurrent request, got %d", *peak)
+	}
+	if botpkg.QueueDepth() != 0 {
+		t.Errorf("queue not drained: %d", botpkg.QueueDepth())
+	}
+}
+
+func TestJobLimitSkip(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	botpkg.ResetHistory()
+	t.Cleanup(botpkg.ResetHistory)
+	botpkg.SetJobLimits(1, botpkg.JobLimitSkip)
+	t.Cleanup(func() { botpkg.SetJobLimits(0, botpkg.JobLimitQueue) })
+
+	_, release, stop := scheduleBlocked(t)
+	defer stop()
+
+	skipped := func() int {
+		n := 0
+		for _, r := range botpkg.TaskHistory("", 10) {
+			if r.Status == botpkg.RunSkipped {
+				n++
+			}
+		}
+		return n
+	}
+	waitFor(t, "two skipped runs", func() bool { return skipped() == 2 })
+	if botpkg.QueueDepth() != 0 {
+		t.Errorf("skip mode must not queue, depth %d", botpkg.QueueDepth())
+	}
+	close(release)
+	waitFor(t, "the running job", func() bool { return len(botpkg.TaskHistory("", 10)) == 3 })
+}
+
+func TestValidateTaskJitter(t *testing.T) {
+	err := botpkg.ValidateTasks([]botpkg.Task{{Name: "a", Prompt: "p", Time: "09:00", Jitter: "soon"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid jitter") {
+		t.Errorf("expected jitter error, got %v", err)
+	}
+	if err := botpkg.ValidateTasks([]botpkg.Task{{Name: "a", Prompt: "p", Time: "09:00", Jitter: "90s"}}); err != nil {
+		t.Errorf("valid jitter rejected: %v", err)
+	}
+}
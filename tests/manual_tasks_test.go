@@ -23,6 +23,8 @@ func (t *taskCtx) Send(what interface{}, opts ...interface{}) error {
 	return nil
 }
 
+func (t *taskCtx) Chat() *tb.Chat { return &tb.Chat{ID: 1, Type: tb.ChatPrivate} }
+
 func TestRegisterTaskCommands(t *testing.T) {
 	// Create mock server
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestTaskParamsInheritFileDefaults(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "tasks.yml")
+	data := `model: gpt-4.1
+max_tokens: 800
+temperature: 0.5
+web_search: false
+tasks:
+  - name: long
+    prompt: p
+    time: "09:00"
+    max_tokens: 2000
+    web_search: true
+  - name: short
+    prompt: p
+    time: "10:00"
+`
+	if err := os.WriteFile(fn, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TASKS_FILE", fn)
+	t.Setenv("TASKS_OVERLAY_FILE", filepath.Join(t.TempDir(), "overlay.json"))
+
+	tasks, err := botpkg.LoadTasks()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	long, _ := botpkg.FindTask(tasks, "long")
+	short, _ := botpkg.FindTask(tasks, "short")
+	if long.MaxTokens != 2000 || !*long.WebSearch || *long.Temperature != 0.5 {
+		t.Errorf("unexpected params of long: %+v", long.GenParams)
+	}
+	if short.MaxTokens != 800 || *short.WebSearch || *short.Temperature != 0.5 {
+		t.Errorf("unexpected params of short: %+v", short.GenParams)
+	}
+}
+
+func TestTaskCompletionUsesTaskParams(t *testing.T) {
+	botpkg.ResetRuntimeConfig()
+	t.Cleanup(botpkg.ResetRuntimeConfig)
+
+	var req openai.ChatCompletionRequest
+	client, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	})
+	defer srv.Close()
+
+	temp := float32(0.2)
+	off := false
+	task := botpkg.Task{Name: "short", Prompt: "p", GenParams: botpkg.GenParams{
+		MaxTokens: 150, Temperature: &temp, WebSearch: &off, ReasoningEffort: "low",
+	}}
+	if _, err := botpkg.TaskCompletion(context.Background(), client, task, "p", "gpt-4o"); err != nil {
+		t.Fatalf("completion: %v", err)
+	}
+	if req.MaxTokens != 150 || req.Temperature != 0.2 || len(req.Tools) != 0 || req.ReasoningEffort != "low" {
+		t.Errorf("unexpected request: max_tokens %d, temperature %v, tools %d, effort %q",
+			req.MaxTokens, req.Temperature, len(req.Tools), req.ReasoningEffort)
+	}
+
+	if _, err := botpkg.TaskCompletion(context.Background(), client, botpkg.Task{Name: "plain", Prompt: "p"}, "p", "gpt-4o"); err != nil {
+		t.Fatalf("completion: %v", err)
+	}
+	if req.MaxTokens != botpkg.GetMaxTokens() || req.Temperature != botpkg.DefaultTemperature || len(req.Tools) != 1 {
+		t.Errorf("runtime defaults not used: max_tokens %d, temperature %v, tools %d", req.MaxTokens, req.Temperature, len(req.Tools))
+	}
+}
+
+func TestValidateTaskParams(t *testing.T) {
+	hot := float32(3)
+	cases := []struct {
+		params botpkg.GenParams
+		want   string
+	}{
+		{botpkg.GenParams{Temperature: &hot}, "temperature"},
+		{botpkg.GenParams{MaxTokens: -1}, "max_tokens"},
+		{botpkg.GenParams{ReasoningEffort: "extreme"}, "reasoning_effort"},
+		{botpkg.GenParams{ToolChoice: "sometimes"}, "tool_choice"},
+	}
+	for _, tc := range cases {
+		err := botpkg.ValidateTasks([]botpkg.Task{{Name: "a", Prompt: "p", Time: "09:00", GenParams: tc.params}})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: expected %s error, got %v", tc.params, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+func TestValidateTasksDependencies(t *testing.T) {
+	cases := []struct {
+		name  string
+		tasks []botpkg.Task
+		want  string
+	}{
+		{"cycle", []botpkg.Task{
+			{Name: "a", Prompt: "p", DependsOn: []string{"b"}},
+			{Name: "b", Prompt: "{output:c}"},
+			{Name: "c", Prompt: "p", Time: "10:00", DependsOn: []string{"a"}},
+		}, "dependency cycle: a -> b -> c -> a"},
+		{"self", []botpkg.Task{{Name: "a", Prompt: "{output:a}", Time: "10:00"}}, "dependency cycle: a -> a"},
+		{"unknown", []botpkg.Task{{Name: "a", Prompt: "{output:missing}"}}, `unknown upstream task "missing"`},
+	}
+	for _, tc := range cases {
+		err := botpkg.ValidateTasks(tc.tasks)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	ok := []botpkg.Task{
+		{Name: "lots", Prompt: "p", Time: "13:00"},
+		{Name: "top", Prompt: "{output:lots}"},
+		{Name: "digest", Prompt: "{output:lots} {output:top}", Time: "19:30"},
+	}
+	if err := botpkg.ValidateTasks(ok); err != nil {
+		t.Errorf("valid pipeline rejected: %v", err)
+	}
+}
+
+func TestLoadTasksRejectsCycle(t *testing.T) {
+	t.Setenv("TASKS_JSON", `[{"name":"a","prompt":"{output:b}"},{"name":"b","prompt":"{output:a}"}]`)
+	if _, err := botpkg.LoadTasks(); err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+}
+
+// schedulePipeline schedules the loaded tasks with a client that answers
+// every prompt with "out(<prompt>)" and records the prompts it received.
+func schedulePipeline(t *testing.T) (*sync.Map, *gocron.Scheduler, func()) {
+	var prompts sync.Map
+	client, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[0].Content
+		prompts.Store(prompt, true)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]string{"content": "out(" + prompt + ")"}}},
+		})
+	})
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, client, nil, 0)
+	s.StartAsync()
+	return &prompts, s, func() {
+		s.Stop()
+		srv.Close()
+	}
+}
+
+func waitForRun(t *testing.T, task string) botpkg.TaskRunRecord {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if run, ok := botpkg.LastRun(task); ok {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("task %s did not run", task)
+	return botpkg.TaskRunRecord{}
+}
+
+func resetPipelineState(t *testing.T) {
+	botpkg.ResetWhitelist()
+	botpkg.ResetHistory()
+	botpkg.ResetPipelines()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Cleanup(botpkg.ResetHistory)
+	t.Cleanup(botpkg.ResetPipelines)
+}
+
+func TestDependentTaskRunsAfterUpstream(t *testing.T) {
+	resetPipelineState(t)
+	t.Setenv("TASKS_JSON", `[
+		{"name":"lots","prompt":"lots","time":"13:00"},
+		{"name":"top","prompt":"top of {output:lots}","depends_on":["lots"]}
+	]`)
+	_, s, stop := schedulePipeline(t)
+	defer stop()
+
+	tasks, _ := botpkg.LoadTasks()
+	if !strings.Contains(botpkg.FormatTasks(tasks), "после lots - top") {
+		t.Errorf("dependent task not shown as triggered:\n%s", botpkg.FormatTasks(tasks))
+	}
+
+	s.RunAll()
+
+	top := waitForRun(t, "top")
+	if top.Status != botpkg.RunOK || top.Output != "out(top of out(lots))" {
+		t.Errorf("unexpected dependent run: %+v", top)
+	}
+}
+
+func TestScheduledTaskOmitsMissingUpstream(t *testing.T) {
+	resetPipelineState(t)
+	t.Setenv("TASKS_JSON", `[
+		{"name":"lots","prompt":"lots","time":"13:00","paused":true},
+		{"name":"news","prompt":"news","time":"14:00","paused":true},
+		{"name":"digest","prompt":"sum\nLots: {output:lots}\nNews: {output:news}","time":"19:30"}
+	]`)
+	// The latest news runs failed or belong to another timezone slot; the
+	// digest must use the last successful run of the default slot.
+	base := time.Now().Add(-3 * time.Hour)
+	runs := []botpkg.TaskRunRecord{
+		{Task: "news", Started: base, Finished: base, Status: botpkg.RunOK, Output: "old news", SlotTime: "14:00"},
+		{Task: "news", Started: base.Add(time.Hour), Finished: base.Add(time.Hour), Status: botpkg.RunFailed, SlotTime: "14:00"},
+		{Task: "news", Started: base.Add(2 * time.Hour), Finished: base.Add(2 * time.Hour), Status: botpkg.RunOK, Output: "tokyo", SlotTime: "14:00", SlotZone: "Asia/Tokyo"},
+		{Task: "lots", Started: base.Add(2 * time.Hour), Finished: base.Add(2 * time.Hour), Status: botpkg.RunSkipped, SlotTime: "13:00"},
+	}
+	path := filepath.Join(t.TempDir(), "history.json")
+	data, _ := json.Marshal(map[string]interface{}{"runs": runs})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := botpkg.LoadHistory(path, 0); err != nil {
+		t.Fatalf("load history: %v", err)
+	}
+	_, s, stop := schedulePipeline(t)
+	defer stop()
+
+	s.RunAll()
+
+	run := waitForRun(t, "digest")
+	if run.Status != botpkg.RunOK || run.Output != "out(sum\nNews: old news)" {
+		t.Errorf("unexpected run: %+v", run)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	botpkg "telegram-reminder/internal/bot"
+	"telegram-reminder/internal/domain"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestValidateTasksPlaceholders(t *testing.T) {
+	tasks := []botpkg.Task{
+		{Name: "ok", Prompt: "{CryptoDigestPrompt} {date} {base_prompt}"},
+		{Name: "typo", Prompt: "{CryptoDigestPromt}"},
+		{Name: "bad_digest", Digest: "weather"},
+	}
+	err := botpkg.ValidateTasks(tasks)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"typo: unknown placeholder {CryptoDigestPromt}", `bad_digest: unknown digest "weather"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "ok:") {
+		t.Errorf("known placeholders reported: %v", err)
+	}
+}
+
+func TestLoadTasksRejectsUnknownPlaceholder(t *testing.T) {
+	t.Setenv("TASKS_JSON", `[{"name":"a","prompt":"{NoSuchPrompt}"}]`)
+	if _, err := botpkg.LoadTasks(); err == nil || !strings.Contains(err.Error(), "{NoSuchPrompt}") {
+		t.Fatalf("expected unknown placeholder error, got %v", err)
+	}
+}
+
+func TestDigestPromptsInTasks(t *testing.T) {
+	var prompts []string
+	client, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Messages[0].Content)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"resp"}}]}`))
+	})
+	defer srv.Close()
+
+	botpkg.TasksMu.Lock()
+	botpkg.LoadedTasks = []botpkg.Task{
+		{Name: "crypto_am", Prompt: "{CryptoDigestPrompt}"},
+		{Name: "tech_pm", Digest: "tech", Prompt: "Только про ИИ."},
+	}
+	botpkg.TasksMu.Unlock()
+	t.Cleanup(func() {
+		botpkg.TasksMu.Lock()
+		botpkg.LoadedTasks = nil
+		botpkg.TasksMu.Unlock()
+	})
+
+	b, err := tb.NewBot(tb.Settings{Offline: true})
+	if err != nil {
+		t.Fatalf("new bot: %v", err)
+	}
+	botpkg.RegisterTaskCommands(b, client)
+	for _, cmd := range []string{"/crypto_am", "/tech_pm"} {
+		if err := b.Trigger(cmd, &recordCtx{}); err != nil {
+			t.Fatalf("trigger %s: %v", cmd, err)
+		}
+	}
+
+	configs := domain.GetDigestConfigs()
+	if len(prompts) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(prompts))
+	}
+	if prompts[0] != configs[domain.CryptoDigest].Prompt {
+		t.Errorf("digest placeholder not expanded: %.80q", prompts[0])
+	}
+	if !strings.HasPrefix(prompts[1], strings.TrimSpace(configs[domain.TechDigest].Prompt)) || !strings.HasSuffix(prompts[1], "\n\nТолько про ИИ.") {
+		t.Errorf("digest task prompt not built: %.80q", prompts[1])
+	}
+
+	chat := botpkg.ChatInfo{Subscriptions: []string{"tech"}}
+	if !chat.WantsTask(botpkg.Task{Name: "tech_pm", Digest: "tech"}) {
+		t.Error("chat subscribed to tech does not want the tech digest task")
+	}
+}
+
+func TestDigestCommandChatScopeMatchesTasks(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	chat := &tb.Chat{ID: -7, Type: tb.ChatSuperGroup, Title: "Team"}
+	if err := botpkg.AddChatToWhitelist(chat); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := botpkg.SetChatTimezone(chat.ID, "Asia/Almaty"); err != nil {
+		t.Fatalf("set tz: %v", err)
+	}
+
+	scope, loc := botpkg.ChatScopeAdapter{}.ChatScope(chat)
+	if scope.Type != "supergroup" || scope.Title != "Team" || scope.ID != chat.ID {
+		t.Errorf("unexpected chat scope: %+v", scope)
+	}
+	if loc == nil || loc.String() != "Asia/Almaty" {
+		t.Errorf("digest ignores the chat timezone: %v", loc)
+	}
+}
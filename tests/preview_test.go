@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	botpkg "telegram-reminder/internal/bot"
+)
+
+func loadPreviewTasks(t *testing.T, tasks string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("TASKS_JSON", tasks)
+	t.Setenv("TASKS_OVERLAY_FILE", filepath.Join(dir, "overlay.json"))
+	t.Setenv("HISTORY_FILE", filepath.Join(dir, "history.json"))
+	t.Setenv("TIMEZONE", "UTC")
+	botpkg.ResetHistory()
+	t.Cleanup(botpkg.ResetHistory)
+	if err := botpkg.LoadForPreview(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+}
+
+func TestPreviewTaskPromptOnly(t *testing.T) {
+	loadPreviewTasks(t, `[{"name":"brief","prompt":"Сводка для {{.Task}}","time":"09:00","model":"gpt-4o"}]`)
+
+	p, err := botpkg.PreviewTask(context.Background(), nil, "brief")
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if p.Prompt != "Сводка для brief" || p.Model != "gpt-4o" || p.Output != "" {
+		t.Errorf("unexpected preview: %+v", p)
+	}
+	if _, err := botpkg.PreviewTask(context.Background(), nil, "missing"); err == nil {
+		t.Error("expected error for unknown task")
+	}
+}
+
+func TestPreviewTaskCallsModelWithoutRecording(t *testing.T) {
+	loadPreviewTasks(t, `[{"name":"brief","prompt":"Сводка","time":"09:00"}]`)
+
+	var got string
+	client, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		got = req.Messages[0].Content
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ответ"}}]}`))
+	})
+	defer srv.Close()
+
+	p, err := botpkg.PreviewTask(context.Background(), client, "brief")
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if got != "Сводка" || p.Output != "ответ" {
+		t.Errorf("unexpected preview: prompt %q, %+v", got, p)
+	}
+	if _, ok := botpkg.LastRun("brief"); ok {
+		t.Error("preview was recorded in the history")
+	}
+}
+
+func TestPreviewDigest(t *testing.T) {
+	loadPreviewTasks(t, `[{"name":"brief","prompt":"Сводка","time":"09:00"}]`)
+
+	p, err := botpkg.PreviewTask(context.Background(), nil, "crypto")
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if strings.TrimSpace(p.Prompt) == "" || strings.Contains(p.Prompt, "{{") {
+		t.Errorf("digest prompt not rendered: %q", p.Prompt)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+func jobTags(s *gocron.Scheduler) []string {
+	var tags []string
+	for _, j := range s.Jobs() {
+		tags = append(tags, j.Tags()[0])
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func TestReloadTasksReplacesChangedJobs(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+
+	path := filepath.Join(t.TempDir(), "tasks.yml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write(`tasks:
+  - name: keep
+    prompt: p
+    time: "09:00"
+  - name: edit
+    prompt: p
+    time: "10:00"
+  - name: drop
+    prompt: p
+    time: "11:00"
+`)
+	t.Setenv("TASKS_FILE", path)
+
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, nil, nil, 0)
+	var keepJob *gocron.Job
+	for _, j := range s.Jobs() {
+		if j.Tags()[0] == "keep" {
+			keepJob = j
+		}
+	}
+
+	write(`tasks:
+  - name: keep
+    prompt: p
+    time: "09:00"
+  - name: edit
+    prompt: p2
+    time: "10:30"
+  - name: fresh
+    prompt: p
+    time: "12:00"
+`)
+	diff, err := botpkg.ReloadTasks()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "fresh" ||
+		len(diff.Changed) != 1 || diff.Changed[0] != "edit" ||
+		len(diff.Removed) != 1 || diff.Removed[0] != "drop" {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+
+	tags := jobTags(s)
+	want := []string{"edit", "fresh", "keep"}
+	if len(tags) != len(want) {
+		t.Fatalf("jobs after reload: %v, want %v", tags, want)
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Errorf("jobs after reload: %v, want %v", tags, want)
+		}
+	}
+	for _, j := range s.Jobs() {
+		if j.Tags()[0] == "keep" && j != keepJob {
+			t.Error("unchanged task was rescheduled")
+		}
+	}
+
+	botpkg.TasksMu.RLock()
+	edited, _ := botpkg.FindTask(botpkg.LoadedTasks, "edit")
+	botpkg.TasksMu.RUnlock()
+	if edited.Prompt != "p2" {
+		t.Errorf("LoadedTasks not swapped: %+v", edited)
+	}
+}
+
+func TestReloadTasksKeepsScheduleOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.yml")
+	if err := os.WriteFile(path, []byte("tasks:\n  - name: a\n    prompt: p\n    time: \"09:00\"\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("TASKS_FILE", path)
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, nil, nil, 0)
+
+	for _, broken := range []string{
+		"tasks: [",
+		"tasks:\n  - name: a\n    prompt: p\n    time: \"25:99\"\n",
+		"tasks:\n  - name: a\n    prompt: p\n    cron: \"not a cron\"\n",
+		"tasks:\n  - name: a\n    prompt: p\n  - name: a\n    prompt: q\n",
+	} {
+		if err := os.WriteFile(path, []byte(broken), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := botpkg.ReloadTasks(); err == nil {
+			t.Errorf("expected error for %q", broken)
+		}
+	}
+	if tags := jobTags(s); len(tags) != 1 || tags[0] != "a" {
+		t.Errorf("schedule changed after failed reload: %v", tags)
+	}
+	botpkg.TasksMu.RLock()
+	defer botpkg.TasksMu.RUnlock()
+	if len(botpkg.LoadedTasks) != 1 || botpkg.LoadedTasks[0].Time != "09:00" {
+		t.Errorf("tasks changed after failed reload: %+v", botpkg.LoadedTasks)
+	}
+}
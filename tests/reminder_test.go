@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestParseWhen(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*3600)
+	now := time.Date(2026, 10, 17, 14, 0, 0, 0, msk)
+	cases := []struct {
+		in   string
+		at   time.Time
+		text string
+	}{
+		{"15:30 позвонить маме", time.Date(2026, 10, 17, 15, 30, 0, 0, msk), "позвонить маме"},
+		{"09:00 зарядка", time.Date(2026, 10, 18, 9, 0, 0, 0, msk), "зарядка"},
+		{"2026-11-01 09:00 налоги", time.Date(2026, 11, 1, 9, 0, 0, 0, msk), "налоги"},
+		{"01.11.2026 09:00 налоги", time.Date(2026, 11, 1, 9, 0, 0, 0, msk), "налоги"},
+		{"завтра 10:15 стендап", time.Date(2026, 10, 18, 10, 15, 0, 0, msk), "стендап"},
+		{"in 2h deploy", now.Add(2 * time.Hour), "deploy"},
+		{"in 1h30m deploy", now.Add(90 * time.Minute), "deploy"},
+		{"in 20 minutes tea", now.Add(20 * time.Minute), "tea"},
+		{"in an hour tea", now.Add(time.Hour), "tea"},
+		{"через 20 минут чай", now.Add(20 * time.Minute), "чай"},
+		{"через 20мин чай", now.Add(20 * time.Minute), "чай"},
+		{"через 2 часа обед", now.Add(2 * time.Hour), "обед"},
+		{"через час обед", now.Add(time.Hour), "обед"},
+		{"через полчаса обед", now.Add(30 * time.Minute), "обед"},
+		{"через 3 дня отчёт", now.Add(72 * time.Hour), "отчёт"},
+	}
+	for _, tc := range cases {
+		at, text, err := botpkg.ParseWhen(tc.in, now)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !at.Equal(tc.at) || text != tc.text {
+			t.Errorf("%q: got %s %q, want %s %q", tc.in, at, text, tc.at, tc.text)
+		}
+	}
+
+	for _, in := range []string{"", "завтра", "позвонить маме", "через", "через 5 лет", "2026-01-01 09:00 прошлое", "in 2 years"} {
+		if _, _, err := botpkg.ParseWhen(in, now); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestRemindersPersist(t *testing.T) {
+	botpkg.ResetReminders()
+	t.Cleanup(botpkg.ResetReminders)
+	path := filepath.Join(t.TempDir(), "reminders.json")
+	if err := botpkg.LoadReminders(path); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	r1, err := botpkg.AddReminder(5, 7, "позвонить", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := botpkg.AddReminder(6, 7, "чужой чат", at); err != nil {
+		t.Fatal(err)
+	}
+	r3, _ := botpkg.AddReminder(5, 7, "раньше", at.Add(-time.Minute))
+
+	botpkg.ResetReminders()
+	if err := botpkg.LoadReminders(path); err != nil {
+		t.Fatal(err)
+	}
+	list := botpkg.ChatReminders(5)
+	if len(list) != 2 || list[0].ID != r3.ID || list[1].ID != r1.ID || !list[1].At.Equal(at) {
+		t.Fatalf("unexpected reminders after reload: %+v", list)
+	}
+	if err := botpkg.CancelReminder(6, r1.ID); err == nil {
+		t.Error("reminder cancelled from another chat")
+	}
+	if err := botpkg.CancelReminder(5, r1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := botpkg.AddReminder(5, 7, "новое", at); r.ID <= r3.ID {
+		t.Errorf("reminder ID reused: %d", r.ID)
+	}
+}
+
+func TestReminderFires(t *testing.T) {
+	botpkg.ResetReminders()
+	t.Cleanup(botpkg.ResetReminders)
+	botpkg.ResetRunLock()
+	t.Cleanup(botpkg.ResetRunLock)
+
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			sent = append(sent, string(body))
+			mu.Unlock()
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":5}}}`))
+	}))
+	defer srv.Close()
+	b, err := tb.NewBot(tb.Settings{URL: srv.URL, Token: "t", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Overdue after downtime: sent at once when the scheduler starts.
+	if _, err := botpkg.AddReminder(5, 7, "просрочено", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.StartReminders(s, b)
+	s.StartAsync()
+	defer s.Stop()
+	if _, err := botpkg.AddReminder(5, 7, "скоро", time.Now().Add(800*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "both reminders", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 2
+	})
+	mu.Lock()
+	all := strings.Join(sent, "\n")
+	mu.Unlock()
+	if !strings.Contains(all, "просрочено") || !strings.Contains(all, "С опозданием") || !strings.Contains(all, "скоро") {
+		t.Errorf("unexpected messages: %s", all)
+	}
+	waitFor(t, "store cleanup", func() bool { return len(botpkg.ChatReminders(5)) == 0 })
+}
+
+func TestParseRecurrence(t *testing.T) {
+	now := time.Date(2026, 10, 17, 14, 0, 0, 0, time.UTC)
+	cases := []struct {
+		in, cron, desc, text string
+	}{
+		{"every Monday at 9 standup", "0 9 * * 1", "по понедельникам в 09:00", "standup"},
+		{"каждый будний день в 8:30 зарядка", "30 8 * * 1-5", "по будням в 08:30", "зарядка"},
+		{"по будням в 8:30 зарядка", "30 8 * * 1-5", "по будням в 08:30", "зарядка"},
+		{"every weekday at 8:30am gym", "30 8 * * 1-5", "по будням в 08:30", "gym"},
+		{"по выходным в 10 уборка", "0 10 * * 0,6", "по выходным в 10:00", "уборка"},
+		{"каждую пятницу в 7 вечера отчёт", "0 19 * * 5", "по пятницам в 19:00", "отчёт"},
+		{"по понедельникам и четвергам в 19:00 бег", "0 19 * * 1,4", "по понедельникам, четвергам в 19:00", "бег"},
+		{"every mon,wed at 6pm run", "0 18 * * 1,3", "по понедельникам, средам в 18:00", "run"},
+		{"каждый день в 21:15 таблетки", "15 21 * * *", "каждый день в 21:15", "таблетки"},
+		{"daily at 11 pm lights", "0 23 * * *", "каждый день в 23:00", "lights"},
+		{"1st of every month at 10 rent", "0 10 1 * *", "1-го числа каждого месяца в 10:00", "rent"},
+		{"1 числа каждого месяца в 9:00 аренда", "0 9 1 * *", "1-го числа каждого месяца в 09:00", "аренда"},
+		{"каждое 15 число в 12:00 счета", "0 12 15 * *", "15-го числа каждого месяца в 12:00", "счета"},
+		{"every month on the 20th at 9 invoice", "0 9 20 * *", "20-го числа каждого месяца в 09:00", "invoice"},
+		{"каждые 2 часа вода", "0 */2 * * *", "каждые 2 ч", "вода"},
+		{"every hour stretch", "0 * * * *", "каждый час", "stretch"},
+	}
+	for _, tc := range cases {
+		rec, text, err := botpkg.ParseRecurrence(tc.in, now)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if rec.Cron != tc.cron || rec.Desc != tc.desc || text != tc.text {
+			t.Errorf("%q: got %q %q %q", tc.in, rec.Cron, rec.Desc, text)
+		}
+	}
+
+	ambiguous := []struct {
+		in    string
+		crons []string
+	}{
+		{"каждый день в 7 зарядка", []string{"0 7 * * *", "0 19 * * *"}},
+		{"every Monday standup", []string{"0 9 * * 1", "0 13 * * 1", "0 18 * * 1"}},
+		{"every month at 10 rent", []string{"0 10 1 * *", "0 10 17 * *"}},
+	}
+	for _, tc := range ambiguous {
+		_, text, err := botpkg.ParseRecurrence(tc.in, now)
+		var amb *botpkg.AmbiguousRecurrence
+		if !errors.As(err, &amb) {
+			t.Errorf("%q: expected ambiguity, got %v", tc.in, err)
+			continue
+		}
+		var crons []string
+		for _, o := range amb.Options {
+			crons = append(crons, o.Cron)
+		}
+		if strings.Join(crons, "|") != strings.Join(tc.crons, "|") || text == "" {
+			t.Errorf("%q: options %v, text %q", tc.in, crons, text)
+		}
+	}
+
+	for _, in := range []string{"15:30 позвонить", "через 20 минут чай", "по делам"} {
+		if _, _, err := botpkg.ParseRecurrence(in, now); !errors.Is(err, botpkg.ErrNotRecurring) {
+			t.Errorf("%q: expected ErrNotRecurring, got %v", in, err)
+		}
+	}
+	for _, in := range []string{"каждые 3 дня полив", "every 15 minutes", "каждый день в 25:00 x", "every blue moon"} {
+		_, _, err := botpkg.ParseRecurrence(in, now)
+		var amb *botpkg.AmbiguousRecurrence
+		if err == nil || errors.Is(err, botpkg.ErrNotRecurring) || errors.As(err, &amb) {
+			t.Errorf("%q: expected parse error, got %v", in, err)
+		}
+	}
+}
+
+func TestRecurringReminder(t *testing.T) {
+	botpkg.ResetReminders()
+	t.Cleanup(botpkg.ResetReminders)
+	path := filepath.Join(t.TempDir(), "reminders.json")
+	if err := botpkg.LoadReminders(path); err != nil {
+		t.Fatal(err)
+	}
+	msk, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip(err)
+	}
+	rec := botpkg.Recurrence{Cron: "0 9 * * 1", Desc: "по понедельникам в 09:00"}
+	r, err := botpkg.AddRecurringReminder(5, 7, "планёрка", rec, msk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := botpkg.NextRecurrence(rec.Cron, msk, time.Now())
+	if !r.Recurring() || !r.At.Equal(want) || r.At.In(msk).Weekday() != time.Monday || r.At.In(msk).Hour() != 9 {
+		t.Fatalf("unexpected next run %s", r.At.In(msk))
+	}
+
+	botpkg.ResetReminders()
+	if err := botpkg.LoadReminders(path); err != nil {
+		t.Fatal(err)
+	}
+	list := botpkg.ChatReminders(5)
+	if len(list) != 1 || list[0].Cron != rec.Cron || list[0].Schedule != rec.Desc || list[0].Zone != "Europe/Moscow" {
+		t.Fatalf("unexpected reminders after reload: %+v", list)
+	}
+}
+
+func TestReminderButtons(t *testing.T) {
+	botpkg.ResetReminders()
+	t.Cleanup(botpkg.ResetReminders)
+	botpkg.ResetRunLock()
+	t.Cleanup(botpkg.ResetRunLock)
+	path := filepath.Join(t.TempDir(), "reminders.json")
+	if err := botpkg.LoadReminders(path); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			sent = append(sent, string(body))
+			mu.Unlock()
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":5}}}`))
+	}))
+	defer srv.Close()
+	b, err := tb.NewBot(tb.Settings{URL: srv.URL, Token: "t", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if _, err := botpkg.AddReminder(5, 7, "полить цветы", due); err != nil {
+		t.Fatal(err)
+	}
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.StartReminders(s, b)
+	s.StartAsync()
+	defer s.Stop()
+	waitFor(t, "reminder", func() bool { return len(botpkg.ChatReminders(5)) == 0 })
+	mu.Lock()
+	body := strings.Join(sent, "\n")
+	mu.Unlock()
+	for _, want := range []string{"reminder_done", "reminder_snooze", "1:10m", "1:1h", "1:tomorrow"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("reminder buttons lack %q: %s", want, body)
+		}
+	}
+
+	// The buttons keep working after a restart.
+	s.Stop()
+	botpkg.ResetReminders()
+	if err := botpkg.LoadReminders(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := botpkg.SnoozeReminder(6, 1, botpkg.SnoozeTomorrow, time.Now()); err == nil {
+		t.Error("reminder snoozed from another chat")
+	}
+	r, err := botpkg.SnoozeReminder(5, 1, botpkg.SnoozeTomorrow, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.At.Equal(due.AddDate(0, 0, 1)) || r.Text != "полить цветы" {
+		t.Errorf("snoozed to %s %q, want %s", r.At, r.Text, due.AddDate(0, 0, 1))
+	}
+	if list := botpkg.ChatReminders(5); len(list) != 1 || list[0].ID != r.ID {
+		t.Errorf("snoozed reminder not stored: %+v", list)
+	}
+	if _, err := botpkg.CompleteReminder(5, 1); err == nil {
+		t.Error("buttons must work only once")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+)
+
+func TestRetryPolicyNextDelay(t *testing.T) {
+	timeout := errors.New("request timeout")
+	p := &botpkg.RetryPolicy{MaxAttempts: 3, BaseDelay: "10s", Deadline: "1m"}
+
+	if d, ok := p.NextDelay(timeout, 1, 0); !ok || d != 10*time.Second {
+		t.Errorf("first retry: %v %v", d, ok)
+	}
+	if d, ok := p.NextDelay(timeout, 2, 15*time.Second); !ok || d != 20*time.Second {
+		t.Errorf("second retry: %v %v", d, ok)
+	}
+	if _, ok := p.NextDelay(timeout, 3, 0); ok {
+		t.Error("attempts exhausted, expected no retry")
+	}
+	if _, ok := p.NextDelay(timeout, 2, 45*time.Second); ok {
+		t.Error("retry past deadline, expected no retry")
+	}
+	if _, ok := p.NextDelay(errors.New("invalid api key"), 1, 0); ok {
+		t.Error("non-retryable error, expected no retry")
+	}
+
+	var none *botpkg.RetryPolicy
+	if _, ok := none.NextDelay(timeout, 1, 0); ok {
+		t.Error("nil policy must not retry")
+	}
+
+	fallback := &botpkg.RetryPolicy{MaxAttempts: 2}
+	if d, ok := fallback.NextDelay(errors.New("rate_limit exceeded"), 1, 0); !ok || d != time.Minute {
+		t.Errorf("default delay for rate limit: %v %v", d, ok)
+	}
+}
+
+func TestValidateTasksRetryPolicy(t *testing.T) {
+	valid := []botpkg.Task{{Name: "a", Prompt: "p", Retry: &botpkg.RetryPolicy{MaxAttempts: 3, BaseDelay: "30s", Deadline: "2h"}}}
+	if err := botpkg.ValidateTasks(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, p := range []botpkg.RetryPolicy{
+		{MaxAttempts: 0},
+		{MaxAttempts: 2, BaseDelay: "soon"},
+		{MaxAttempts: 2, Deadline: "-1h"},
+	} {
+		p := p
+		if err := botpkg.ValidateTasks([]botpkg.Task{{Name: "a", Prompt: "p", Retry: &p}}); err == nil {
+			t.Errorf("expected error for %+v", p)
+		}
+	}
+}
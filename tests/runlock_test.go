@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+func TestFileRunLockLeadership(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.lock")
+	a, err := botpkg.NewFileRunLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := botpkg.NewFileRunLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if ok, err := a.TryLead(); !ok || err != nil {
+		t.Fatalf("first instance should lead: %v %v", ok, err)
+	}
+	if ok, err := b.TryLead(); ok || err != nil {
+		t.Fatalf("second instance should wait: %v %v", ok, err)
+	}
+	if ok, _ := a.TryLead(); !ok {
+		t.Error("leader lost the lock")
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := b.TryLead(); !ok || err != nil {
+		t.Fatalf("second instance should take over: %v %v", ok, err)
+	}
+}
+
+func TestFileRunLockClaims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.lock")
+	a, _ := botpkg.NewFileRunLock(path)
+	b, _ := botpkg.NewFileRunLock(path)
+
+	if ok, err := a.Claim("brief@09:00@@2024-03-10T06:00:00Z"); !ok || err != nil {
+		t.Fatalf("first claim: %v %v", ok, err)
+	}
+	if ok, err := b.Claim("brief@09:00@@2024-03-10T06:00:00Z"); ok || err != nil {
+		t.Fatalf("second claim of the same run: %v %v", ok, err)
+	}
+	if ok, _ := b.Claim("brief@09:00@@2024-03-11T06:00:00Z"); !ok {
+		t.Error("claim of the next run rejected")
+	}
+}
+
+func TestScheduledRunIsClaimedOnce(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	botpkg.ResetHistory()
+	t.Cleanup(botpkg.ResetHistory)
+	lock, err := botpkg.NewFileRunLock(filepath.Join(t.TempDir(), "bot.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	botpkg.SetRunLock(lock)
+	t.Cleanup(botpkg.ResetRunLock)
+	t.Setenv("TASKS_JSON", `[{"name":"brief","prompt":"p","time":"09:00"}]`)
+
+	var calls int32
+	client, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"answer"}}]}`))
+	})
+	defer srv.Close()
+
+	// Both firings must fall into the same minute.
+	if time.Now().Second() == 59 {
+		time.Sleep(time.Second)
+	}
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, client, nil, 0)
+	s.StartAsync()
+	defer s.Stop()
+	s.RunAll()
+	s.RunAll()
+
+	waitForRun(t, "brief")
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected one run, got %d", n)
+	}
+}
+
+func TestFileRunLockUnwritableDir(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root ignores directory permissions")
+	}
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o555); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(dir, 0o755) })
+	_, err := botpkg.NewFileRunLock(filepath.Join(dir, "bot.lock"))
+	if err == nil || !strings.Contains(err.Error(), "must be writable") {
+		t.Fatalf("err = %v, want a hint about permissions", err)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+)
+
+func TestWebhookSinkFormats(t *testing.T) {
+	var mu sync.Mutex
+	bodies := map[string]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		bodies[r.URL.Path] = body
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	text := `<b>BTC</b> растёт &amp; <a href="https://x.io/btc">график</a>`
+	msg := botpkg.SinkMessage{Task: "crypto", Time: time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), Text: text}
+	for _, format := range []string{"json", "slack", "discord"} {
+		sink, err := botpkg.NewSink(botpkg.SinkConfig{Type: "webhook", URL: srv.URL + "/" + format, Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Deliver(context.Background(), msg); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+	}
+	if b := bodies["/json"]; b["task"] != "crypto" || b["text"] != "BTC растёт & график (https://x.io/btc)" || b["html"] != text {
+		t.Errorf("unexpected json payload: %v", b)
+	}
+	if b := bodies["/slack"]; b["text"] != "*crypto*\n*BTC* растёт &amp; <https://x.io/btc|график>" {
+		t.Errorf("unexpected slack payload: %v", b)
+	}
+	if b := bodies["/discord"]; b["content"] != "**crypto**\n**BTC** растёт & [график](https://x.io/btc)" {
+		t.Errorf("unexpected discord payload: %v", b)
+	}
+}
+
+func TestArchiveSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := botpkg.NewSink(botpkg.SinkConfig{Type: "archive", Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	for i, text := range []string{"<i>утро</i>", "вечер<br>ночь"} {
+		msg := botpkg.SinkMessage{Task: "brief", Time: day.Add(time.Duration(i) * 10 * time.Hour), Text: text}
+		if err := sink.Deliver(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "brief", "2026-10-17.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# brief — 17.10.2026\n\n## 09:00\n\n*утро*\n\n## 19:00\n\nвечер\nночь\n\n"
+	if string(data) != want {
+		t.Errorf("unexpected archive:\n%s", data)
+	}
+}
+
+func TestValidateTaskSinks(t *testing.T) {
+	bad := []botpkg.SinkConfig{
+		{Type: "ftp"},
+		{Type: "webhook", URL: "not a url"},
+		{Type: "webhook", URL: "https://example.com", Format: "teams"},
+		{Type: "email"},
+		{Type: "email", To: []string{"nobody"}},
+		{Type: "archive"},
+	}
+	for _, s := range bad {
+		err := botpkg.ValidateTasks([]botpkg.Task{{Name: "a", Prompt: "p", Time: "09:00", Sinks: []botpkg.SinkConfig{s}}})
+		if err == nil {
+			t.Errorf("%+v: expected an error", s)
+		}
+	}
+	good := []botpkg.SinkConfig{
+		{Type: "webhook", URL: "https://hooks.slack.com/services/x", Format: "slack"},
+		{Type: "email", To: []string{"me@example.com"}},
+		{Type: "archive", Dir: "archive"},
+	}
+	if err := botpkg.ValidateTasks([]botpkg.Task{{Name: "a", Prompt: "p", Time: "09:00", Sinks: good}}); err != nil {
+		t.Errorf("valid sinks rejected: %v", err)
+	}
+}
+
+func TestScheduledRunSinks(t *testing.T) {
+	resetPipelineState(t)
+	botpkg.ResetRunLock()
+	t.Cleanup(botpkg.ResetRunLock)
+
+	release := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/broken" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		<-release
+	}))
+	defer hook.Close()
+	dir := t.TempDir()
+	tasks, _ := json.Marshal([]botpkg.Task{{
+		Name: "digest", Prompt: "p", Time: "09:00",
+		Sinks: []botpkg.SinkConfig{
+			{Type: "webhook", Name: "slow", URL: hook.URL + "/slow"},
+			{Type: "webhook", Name: "broken", URL: hook.URL + "/broken"},
+			{Type: "archive", Dir: dir},
+		},
+	}})
+	t.Setenv("TASKS_JSON", string(tasks))
+
+	_, s, stop := schedulePipeline(t)
+	defer stop()
+	s.RunAll()
+
+	// The run is recorded while the slow webhook is still waiting.
+	run := waitForRun(t, "digest")
+	if run.Status != botpkg.RunOK || len(run.Sinks) != 0 {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	close(release)
+	waitFor(t, "sink results", func() bool {
+		run, _ := botpkg.LastRun("digest")
+		return len(run.Sinks) == 3
+	})
+	run, _ = botpkg.LastRun("digest")
+	if run.Sinks[0].Sink != "slow" || run.Sinks[0].Error != "" ||
+		run.Sinks[1].Sink != "broken" || !strings.Contains(run.Sinks[1].Error, "500") ||
+		run.Sinks[2].Sink != "archive" || run.Sinks[2].Error != "" {
+		t.Errorf("unexpected sink results: %+v", run.Sinks)
+	}
+	if line := botpkg.FormatRun(run); !strings.Contains(line, "broken ❌") || !strings.Contains(line, "slow ✅") {
+		t.Errorf("history line lacks sink results: %s", line)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "digest")); len(entries) != 1 {
+		t.Errorf("archive not written: %v", entries)
+	}
+}
+
+func TestCatchUpSinksGetUndecoratedText(t *testing.T) {
+	resetPipelineState(t)
+	botpkg.ResetRunLock()
+	t.Cleanup(botpkg.ResetRunLock)
+	t.Cleanup(botpkg.ResetRunState)
+
+	dir := t.TempDir()
+	at := time.Now().UTC().Add(-2 * time.Minute).Truncate(time.Minute)
+	tasks, _ := json.Marshal([]botpkg.Task{{
+		Name: "digest", Prompt: "<b>p</b>", Time: at.Format("15:04"),
+		Sinks: []botpkg.SinkConfig{{Type: "archive", Dir: dir}},
+	}})
+	t.Setenv("TASKS_JSON", string(tasks))
+	_, _, stop := schedulePipeline(t)
+	defer stop()
+	writeRunState(t, map[string]time.Time{"digest@" + at.Format("15:04") + "@": at.Add(-24 * time.Hour)})
+
+	botpkg.CatchUpMissedRuns(time.Hour)
+
+	waitFor(t, "sink results", func() bool {
+		run, _ := botpkg.LastRun("digest")
+		return len(run.Sinks) == 1
+	})
+	run, _ := botpkg.LastRun("digest")
+	if !run.Delayed {
+		t.Fatalf("run not delivered as delayed: %+v", run)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "digest", at.Format("2006-01-02")+".md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "С опозданием") || !strings.Contains(string(data), "out(**p**)") {
+		t.Errorf("archive got the Telegram text:\n%s", data)
+	}
+}
+
+// fakeSMTP accepts one message and records the envelope and the data.
+func fakeSMTP(t *testing.T) (host string, port int, got func() (from string, rcpt []string, data string)) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	var mu sync.Mutex
+	var envFrom, body string
+	var envRcpt []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = fmt.Fprintf(conn, "%s\r\n", s) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			upper := strings.ToUpper(cmd)
+			mu.Lock()
+			switch {
+			case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(upper, "MAIL FROM:"):
+				envFrom = cmd[len("MAIL FROM:"):]
+				reply("250 OK")
+			case strings.HasPrefix(upper, "RCPT TO:"):
+				envRcpt = append(envRcpt, cmd[len("RCPT TO:"):])
+				reply("250 OK")
+			case upper == "DATA":
+				reply("354 go on")
+				var sb strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					sb.WriteString(l)
+				}
+				body = sb.String()
+				reply("250 queued")
+			case upper == "QUIT":
+				reply("221 bye")
+				mu.Unlock()
+				return
+			default:
+				reply("250 OK")
+			}
+			mu.Unlock()
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, func() (string, []string, string) {
+		<-done
+		mu.Lock()
+		defer mu.Unlock()
+		return envFrom, envRcpt, body
+	}
+}
+
+func TestEmailSinkEnvelope(t *testing.T) {
+	host, port, got := fakeSMTP(t)
+	botpkg.SetSMTPConfig(botpkg.SMTPConfig{Host: host, Port: port, From: "Billion Bot <bot@example.com>"})
+	t.Cleanup(func() { botpkg.SetSMTPConfig(botpkg.SMTPConfig{}) })
+
+	sink, err := botpkg.NewSink(botpkg.SinkConfig{Type: "email", To: []string{"Ann <ann@example.com>", "bob@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := botpkg.SinkMessage{Task: "brief", Time: time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), Text: "<b>итоги</b>"}
+	if err := sink.Deliver(context.Background(), msg); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	from, rcpt, data := got()
+	if from != "<bot@example.com>" {
+		t.Errorf("MAIL FROM = %q", from)
+	}
+	if want := []string{"<ann@example.com>", "<bob@example.com>"}; !reflect.DeepEqual(rcpt, want) {
+		t.Errorf("RCPT TO = %q, want %q", rcpt, want)
+	}
+	if !strings.Contains(data, "To: \"Ann\" <ann@example.com>, <bob@example.com>\r\n") ||
+		!strings.Contains(data, "From: \"Billion Bot\" <bot@example.com>\r\n") {
+		t.Errorf("headers lack display names:\n%s", data)
+	}
+}
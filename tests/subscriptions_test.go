@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+func TestSubscribeUnsubscribe(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	botpkg.TasksMu.Lock()
+	botpkg.LoadedTasks = []botpkg.Task{{Name: "land_price", Prompt: "p"}, {Name: "crypto_am", Prompt: "{CryptoDigestPrompt}"}}
+	botpkg.TasksMu.Unlock()
+
+	if err := botpkg.AddIDToWhitelist(1); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	subs, err := botpkg.GetChatSubscriptions(1)
+	if err != nil || !reflect.DeepEqual(subs, []string{botpkg.SubscribeAll}) {
+		t.Fatalf("expected wildcard default, got %v (err %v)", subs, err)
+	}
+
+	subs, err = botpkg.UnsubscribeChat(1, "land_price")
+	if err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	for _, s := range subs {
+		if s == "land_price" || s == botpkg.SubscribeAll {
+			t.Fatalf("land_price still subscribed: %v", subs)
+		}
+	}
+
+	subs, err = botpkg.UnsubscribeChat(1, "all")
+	if err != nil || len(subs) != 0 {
+		t.Fatalf("expected no subscriptions, got %v (err %v)", subs, err)
+	}
+	subs, err = botpkg.SubscribeChat(1, "crypto")
+	if err != nil || !reflect.DeepEqual(subs, []string{"crypto"}) {
+		t.Fatalf("unexpected subscriptions: %v (err %v)", subs, err)
+	}
+}
+
+func TestDefaultSubscriptionsForNewChats(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	botpkg.SetDefaultSubscriptions([]string{"Crypto", "tech", "crypto"})
+	t.Cleanup(func() { botpkg.SetDefaultSubscriptions([]string{botpkg.SubscribeAll}) })
+
+	if err := botpkg.AddIDToWhitelist(2); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	subs, err := botpkg.GetChatSubscriptions(2)
+	if err != nil || !reflect.DeepEqual(subs, []string{"crypto", "tech"}) {
+		t.Fatalf("unexpected default subscriptions: %v (err %v)", subs, err)
+	}
+}
+
+func TestSubscribeUnknownChat(t *testing.T) {
+	botpkg.ResetWhitelist()
+	if _, err := botpkg.SubscribeChat(404, "crypto"); err == nil {
+		t.Fatal("expected error for unregistered chat")
+	}
+}
+
+func TestChatWantsTask(t *testing.T) {
+	crypto := botpkg.Task{Name: "crypto_pm", Prompt: "{CryptoDigestPrompt}"}
+	land := botpkg.Task{Name: "land_price", Prompt: "{base_prompt}"}
+
+	legacy := botpkg.ChatInfo{ID: 1}
+	if !legacy.WantsTask(crypto) || !legacy.WantsTask(land) {
+		t.Error("chat without subscriptions should receive everything")
+	}
+
+	byDigest := botpkg.ChatInfo{ID: 2, Subscriptions: []string{"crypto"}}
+	if !byDigest.WantsTask(crypto) || byDigest.WantsTask(land) {
+		t.Error("digest subscription should match only crypto tasks")
+	}
+
+	byName := botpkg.ChatInfo{ID: 3, Subscriptions: []string{"land_price"}}
+	if byName.WantsTask(crypto) || !byName.WantsTask(land) {
+		t.Error("task subscription should match only the named task")
+	}
+
+	none := botpkg.ChatInfo{ID: 4, Subscriptions: []string{}}
+	if none.WantsTask(crypto) || none.WantsTask(land) {
+		t.Error("empty subscription set should match nothing")
+	}
+}
+
+func TestSubscribeReschedulesOnlyChangedSlots(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Setenv("TASKS_JSON", `[{"name":"a","prompt":"p","time":"09:00"},{"name":"b","prompt":"p","time":"09:00"}]`)
+
+	for _, id := range []int64{1, 2} {
+		if err := botpkg.AddIDToWhitelist(id); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		if err := botpkg.SetChatTimezone(id, "Asia/Almaty"); err != nil {
+			t.Fatalf("set tz: %v", err)
+		}
+		if _, err := botpkg.UnsubscribeChat(id, "all"); err != nil {
+			t.Fatalf("unsubscribe: %v", err)
+		}
+	}
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, nil, nil, 0)
+	jobsOf := func(name string) []*gocron.Job {
+		var out []*gocron.Job
+		for _, j := range s.Jobs() {
+			if j.Tags()[0] == name {
+				out = append(out, j)
+			}
+		}
+		return out
+	}
+	b := jobsOf("b")
+
+	// The first Almaty recipient of a adds its slot; b keeps its job.
+	if _, err := botpkg.SubscribeChat(1, "a"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if got := jobsOf("a"); len(got) != 2 {
+		t.Fatalf("expected the Almaty slot of a, got %d jobs", len(got))
+	}
+	if got := jobsOf("b"); len(got) != 1 || got[0] != b[0] {
+		t.Error("b was rescheduled by a subscription to a")
+	}
+
+	// A second recipient of an existing slot changes no jobs.
+	a := jobsOf("a")
+	if _, err := botpkg.SubscribeChat(2, "a"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, err := botpkg.UnsubscribeChat(1, "a"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if got := jobsOf("a"); !sameJobs(got, a) {
+		t.Error("a was rescheduled although its slots did not change")
+	}
+
+	// The last recipient leaving removes the slot.
+	if _, err := botpkg.UnsubscribeChat(2, "all"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if got := jobsOf("a"); len(got) != 1 {
+		t.Errorf("expected only the default slot of a, got %d jobs", len(got))
+	}
+}
+
+// sameJobs reports whether got and want hold the same jobs in any order.
+func sameJobs(got, want []*gocron.Job) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := map[*gocron.Job]bool{}
+	for _, j := range want {
+		seen[j] = true
+	}
+	for _, j := range got {
+		if !seen[j] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+func TestManageTasksAtRuntime(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Cleanup(botpkg.ResetTaskOverlay)
+
+	overlay := filepath.Join(t.TempDir(), "overlay.json")
+	if err := botpkg.LoadTaskOverlay(overlay); err != nil {
+		t.Fatalf("load overlay: %v", err)
+	}
+	t.Setenv("TASKS_JSON", `[{"name":"base","prompt":"p","time":"09:00"}]`)
+
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, nil, nil, 0)
+
+	if err := botpkg.AddTask("water", "10:30", "Напомни выпить воды"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := botpkg.AddTask("water", "11:00", "again"); err == nil {
+		t.Error("expected duplicate error")
+	}
+	if err := botpkg.AddTask("start", "11:00", "p"); err == nil {
+		t.Error("expected reserved name error")
+	}
+	if err := botpkg.AddTask("bad", "25:00", "p"); err == nil {
+		t.Error("expected invalid time error")
+	}
+	if tags := jobTags(s); len(tags) != 2 || tags[1] != "water" {
+		t.Fatalf("unexpected jobs: %v", tags)
+	}
+
+	if err := botpkg.PauseTask("base"); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if tags := jobTags(s); len(tags) != 1 || tags[0] != "water" {
+		t.Errorf("paused task still scheduled: %v", tags)
+	}
+	botpkg.TasksMu.RLock()
+	list := botpkg.FormatTasks(botpkg.LoadedTasks)
+	botpkg.TasksMu.RUnlock()
+	if !strings.Contains(list, "base (⏸ пауза)") {
+		t.Errorf("paused state not shown:\n%s", list)
+	}
+
+	if err := botpkg.SetTaskTime("water", "12:15"); err != nil {
+		t.Fatalf("settime: %v", err)
+	}
+	if err := botpkg.DeleteTask("nope"); err == nil {
+		t.Error("expected unknown task error")
+	}
+
+	// The overlay survives a restart: reloading from scratch keeps the changes.
+	botpkg.ResetTaskOverlay()
+	if err := botpkg.LoadTaskOverlay(overlay); err != nil {
+		t.Fatalf("reload overlay: %v", err)
+	}
+	tasks, err := botpkg.LoadTasks()
+	if err != nil {
+		t.Fatalf("load tasks: %v", err)
+	}
+	base, _ := botpkg.FindTask(tasks, "base")
+	water, ok := botpkg.FindTask(tasks, "water")
+	if !base.Paused || !ok || water.Time != "12:15" {
+		t.Errorf("overlay not applied: %+v", tasks)
+	}
+
+	if err := botpkg.DeleteTask("base"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	tasks, _ = botpkg.LoadTasks()
+	if _, ok := botpkg.FindTask(tasks, "base"); ok {
+		t.Error("deleted file task is back after reload")
+	}
+	if _, err := os.Stat(overlay); err != nil {
+		t.Errorf("overlay file not written: %v", err)
+	}
+}
+
+func TestPausedFileTaskFollowsFileEdits(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Cleanup(botpkg.ResetTaskOverlay)
+
+	overlay := filepath.Join(t.TempDir(), "overlay.json")
+	if err := botpkg.LoadTaskOverlay(overlay); err != nil {
+		t.Fatalf("load overlay: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "tasks.yml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("tasks:\n  - name: news\n    prompt: old\n    time: \"09:00\"\n")
+	t.Setenv("TASKS_FILE", path)
+
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, nil, nil, 0)
+
+	if err := botpkg.PauseTask("news"); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	write("tasks:\n  - name: news\n    prompt: new\n    time: \"10:00\"\n")
+	if _, err := botpkg.ReloadTasks(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	botpkg.TasksMu.RLock()
+	news, _ := botpkg.FindTask(botpkg.LoadedTasks, "news")
+	botpkg.TasksMu.RUnlock()
+	if news.Prompt != "new" || news.Time != "10:00" || !news.Paused {
+		t.Errorf("file edit not applied to paused task: %+v", news)
+	}
+	if tags := jobTags(s); len(tags) != 0 {
+		t.Errorf("paused task scheduled: %v", tags)
+	}
+
+	if err := botpkg.ResumeTask("news"); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	write("tasks:\n  - name: news\n    prompt: newer\n    time: \"10:00\"\n")
+	if _, err := botpkg.ReloadTasks(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	botpkg.TasksMu.RLock()
+	news, _ = botpkg.FindTask(botpkg.LoadedTasks, "news")
+	botpkg.TasksMu.RUnlock()
+	if news.Prompt != "newer" || news.Paused {
+		t.Errorf("resumed task does not follow the file: %+v", news)
+	}
+	if tags := jobTags(s); len(tags) != 1 || tags[0] != "news" {
+		t.Errorf("resumed task not scheduled: %v", tags)
+	}
+}
+
+func TestEditedFileTaskFollowsFileEdits(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Cleanup(botpkg.ResetTaskOverlay)
+
+	overlay := filepath.Join(t.TempDir(), "overlay.json")
+	if err := botpkg.LoadTaskOverlay(overlay); err != nil {
+		t.Fatalf("load overlay: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "tasks.yml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("tasks:\n  - name: news\n    prompt: old\n    cron: \"0 9 * * 1\"\n  - name: broken\n    prompt: p\n    cron: \"bad\"\n")
+	t.Setenv("TASKS_FILE", path)
+
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, nil, nil, 0)
+
+	// An invalid task in the file does not block changes to other tasks.
+	if err := botpkg.AddTask("water", "10:30", "p"); err != nil {
+		t.Fatalf("add next to a broken file task: %v", err)
+	}
+	if err := botpkg.SetTaskTime("news", "08:00"); err != nil {
+		t.Fatalf("settime: %v", err)
+	}
+	write("tasks:\n  - name: news\n    prompt: new\n    cron: \"0 9 * * 1\"\n    temperature: 0.3\n")
+	if _, err := botpkg.ReloadTasks(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	botpkg.TasksMu.RLock()
+	news, _ := botpkg.FindTask(botpkg.LoadedTasks, "news")
+	botpkg.TasksMu.RUnlock()
+	if news.Prompt != "new" || news.Time != "08:00" || news.Cron != "" || news.Temperature == nil {
+		t.Errorf("file edit not applied to a task with a changed time: %+v", news)
+	}
+
+	if err := botpkg.SetTaskPrompt("news", "mine"); err != nil {
+		t.Fatalf("edittask: %v", err)
+	}
+	data, err := os.ReadFile(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "temperature") || !strings.Contains(string(data), `"mine"`) || !strings.Contains(string(data), `"08:00"`) {
+		t.Errorf("overlay holds more than the changed fields:\n%s", data)
+	}
+}
@@ -25,6 +25,8 @@ func (r *recordCtx) Send(what interface{}, opts ...interface{}) error {
 	return nil
 }
 
+func (r *recordCtx) Chat() *tb.Chat { return &tb.Chat{ID: 1, Type: tb.ChatPrivate} }
+
 func TestRegisterTaskCommandsTemplate(t *testing.T) {
 	// Create mock server that records prompts
 	prompts := []string{}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	botpkg "telegram-reminder/internal/bot"
+
+	"github.com/go-co-op/gocron"
+)
+
+func TestScheduleDailyMessagesPerChatTimezone(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Setenv("TASKS_JSON", `[{"name":"digest","prompt":"p","time":"09:00"}]`)
+
+	botpkg.TasksMu.Lock()
+	botpkg.LoadedTasks = []botpkg.Task{{Name: "digest", Prompt: "p", Time: "09:00"}}
+	botpkg.TasksMu.Unlock()
+
+	for _, id := range []int64{1, 2, 3} {
+		if err := botpkg.AddIDToWhitelist(id); err != nil {
+			t.Fatalf("add %d: %v", id, err)
+		}
+	}
+	if err := botpkg.SetChatTimezone(2, "Asia/Almaty"); err != nil {
+		t.Fatalf("set tz: %v", err)
+	}
+	if err := botpkg.SetChatTaskTime(3, "digest", "10:30"); err != nil {
+		t.Fatalf("set time: %v", err)
+	}
+	if err := botpkg.SetChatTimezone(4, "Asia/Almaty"); err == nil {
+		t.Fatal("expected error for unregistered chat")
+	}
+	if err := botpkg.SetChatTimezone(1, "Mars/Olympus"); err == nil {
+		t.Fatal("expected error for unknown timezone")
+	}
+
+	loc, _ := time.LoadLocation("Europe/Moscow")
+	s := gocron.NewScheduler(loc)
+	s.CustomTime(fakeTime{onNow: func(l *time.Location) time.Time {
+		return time.Date(2024, 1, 1, 5, 0, 0, 0, loc).In(l)
+	}})
+
+	botpkg.ScheduleDailyMessages(s, nil, nil, 0)
+	s.StartAsync()
+	s.Stop()
+
+	var times []string
+	for _, job := range s.Jobs() {
+		times = append(times, job.NextRun().In(loc).Format("15:04"))
+	}
+	sort.Strings(times)
+	// The Almaty chat gets its own slot at 09:00 local time.
+	almaty, _ := time.LoadLocation("Asia/Almaty")
+	want := []string{
+		time.Date(2024, 1, 1, 9, 0, 0, 0, almaty).In(loc).Format("15:04"),
+		"09:00",
+		"10:30",
+	}
+	sort.Strings(want)
+	if len(times) != len(want) {
+		t.Fatalf("expected %d slot jobs, got %v", len(want), times)
+	}
+	for i := range want {
+		if times[i] != want[i] {
+			t.Fatalf("unexpected slots %v, want %v", times, want)
+		}
+	}
+}
+
+func TestScheduleDailyMessagesFixedChatSingleSlot(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Setenv("TASKS_JSON", `[{"name":"digest","prompt":"p","time":"09:00"}]`)
+
+	if err := botpkg.AddIDToWhitelist(1); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := botpkg.SetChatTimezone(1, "Asia/Almaty"); err != nil {
+		t.Fatalf("set tz: %v", err)
+	}
+
+	s := gocron.NewScheduler(time.UTC)
+	botpkg.ScheduleDailyMessages(s, nil, nil, 42)
+	if len(s.Jobs()) != 1 {
+		t.Fatalf("expected a single job with fixed CHAT_ID, got %d", len(s.Jobs()))
+	}
+}
+
+func TestZoneSlotKeepsSeconds(t *testing.T) {
+	botpkg.ResetWhitelist()
+	t.Cleanup(botpkg.ResetWhitelist)
+	t.Setenv("TASKS_JSON", `[{"name":"digest","prompt":"p","time":"09:00:30"},{"name":"weekly","prompt":"p","cron":"0 9 * * 1"}]`)
+
+	if err := botpkg.AddIDToWhitelist(1); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := botpkg.SetChatTimezone(1, "Asia/Almaty"); err != nil {
+		t.Fatalf("set tz: %v", err)
+	}
+	s := gocron.NewScheduler(time.UTC)
+	s.CustomTime(fakeTime{onNow: func(l *time.Location) time.Time {
+		return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).In(l)
+	}})
+	botpkg.ScheduleDailyMessages(s, nil, nil, 0)
+	if err := botpkg.SetChatTaskTime(1, "weekly", "10:00"); err == nil {
+		t.Fatal("expected /mytime to be rejected for a cron task")
+	}
+	s.StartAsync()
+	s.Stop()
+
+	almaty, _ := time.LoadLocation("Asia/Almaty")
+	var found bool
+	for _, job := range s.Jobs() {
+		if len(job.Tags()) == 0 || job.Tags()[0] != "digest" {
+			continue
+		}
+		if len(job.Tags()) != 2 {
+			t.Fatalf("expected name and slot tags, got %v", job.Tags())
+		}
+		if next := job.NextRun().In(almaty); next.Format("15:04:05") == "09:00:30" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected the Almaty slot to run at 09:00:30 local time")
+	}
+}
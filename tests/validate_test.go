@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	botpkg "telegram-reminder/internal/bot"
+)
+
+func TestValidateTasksFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.yml")
+	data := `model: gpt-4.1
+tasks:
+  - name: chat
+    time: "09:00"
+    prompt: hi
+  - name: gis
+    cron: "* * *"
+    prompt: "{CryptoDigestPrompt} {dat}"
+  - name: gis
+    time: "25:00"
+    model: gpt-9
+    prompt: ""
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	problems, err := botpkg.ValidateTasksFile(path)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	var got []int
+	for _, p := range problems {
+		got = append(got, p.Line)
+	}
+	// name clash, cron, placeholder, duplicate, time, model, empty prompt
+	want := []int{3, 7, 8, 9, 10, 11, 12}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problem lines = %v, want %v: %v", got, want, problems)
+	}
+}
+
+func TestValidateTasksFileOK(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := os.WriteFile(path, []byte(`[{"name":"land_price","time":"09:00","prompt":"{date}"}]`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	problems, err := botpkg.ValidateTasksFile(path)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("unexpected problems: %v, %v", problems, err)
+	}
+}
+
+func TestValidateTasksFileRegisteredCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.yml")
+	data := `tasks:
+  - name: webdoc
+    time: "09:00"
+    prompt: hi
+  - name: crypto
+    time: "10:00"
+    prompt: hi
+  - name: land_price
+    time: "11:00"
+    model: o3
+    prompt: hi
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	problems, err := botpkg.ValidateTasksFile(path)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	var got []int
+	for _, p := range problems {
+		got = append(got, p.Line)
+	}
+	// /webdoc and the /crypto digest are registered commands; o3 is supported.
+	if want := []int{2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("problem lines = %v, want %v: %v", got, want, problems)
+	}
+}